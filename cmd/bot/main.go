@@ -4,10 +4,17 @@ import (
 	"log"
 	"telegram-ai-bot/internal/bot"
 	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/dashboard"
 	"telegram-ai-bot/internal/database"
 	"telegram-ai-bot/internal/localization"
 	"telegram-ai-bot/internal/payments"
+	"telegram-ai-bot/internal/pricing"
+	"telegram-ai-bot/internal/referral"
+	"telegram-ai-bot/internal/safety"
+	"telegram-ai-bot/internal/server"
 	"telegram-ai-bot/internal/services"
+	"telegram-ai-bot/internal/wallet"
+	"time"
 
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -20,6 +27,12 @@ func main() {
 	models := config.LoadModels("models.json")
 	templates := config.LoadTemplates("templates/templates.json")
 	styles := config.LoadStyles("styles.json")
+	tiers := config.LoadTiers("tiers.json")
+	features := pricing.LoadFeatures("pricing.json")
+	walletConfig := wallet.Load("wallet.json")
+	freeCredits := config.LoadFreeCreditPolicy("free_credits.json")
+	referralRules := referral.LoadRules("referral.json")
+	safetyPolicy := safety.LoadPolicy("safety.json")
 	localizer := localization.New("locales")
 	dbClient := database.NewClient(cfg)
 
@@ -36,11 +49,25 @@ func main() {
 	api.Debug = false
 	log.Printf("INFO: Authorized on account %s", api.Self.UserName)
 
+	referrals := referral.NewProgram(api, dbClient, localizer, walletConfig, referralRules, cfg.AdminTelegramIDs)
+
 	// PERBAIKAN: Inisialisasi paymentHandler sebelum handler utama
-	paymentHandler := payments.NewPaymentHandler(api, dbClient, localizer, cfg.PaymentProviderToken, cfg.ManualPaymentInfo, "internal/payments/packages.json", "bmac_packages.json")
+	paymentHandler := payments.NewPaymentHandler(api, dbClient, localizer, cfg.PaymentProviderToken, cfg.ManualPaymentInfo, "internal/payments/packages.json", "bmac_packages.json", "internal/payments/subscription.json", "internal/payments/manual_packages.json", cfg.RefundMaxSpentPercent, cfg.AdminTelegramIDs, cfg.BMACWebhookSecret, referrals)
+	paymentHandler.StartSubscriptionWatcher(time.Hour)
 
 	// PERBAIKAN: paymentHandler diberikan sebagai argumen saat membuat handler utama
-	handler := bot.NewHandler(api, dbClient, localizer, providers, models, templates, styles, replicateClient, cfg, paymentHandler)
+	handler := bot.NewHandler(api, dbClient, localizer, providers, models, templates, styles, replicateClient, cfg, paymentHandler, tiers, features, walletConfig, freeCredits, referrals, safety.NewChecker(safetyPolicy, replicateClient))
+	handler.StartFreeCreditScheduler()
+
+	// Server HTTP untuk webhook eksternal dan dashboard admin (hanya jalan jika ada route terdaftar)
+	srv := server.New(cfg.HTTPListenAddr)
+	if paymentHandler.BMACEnabled() {
+		srv.Handle("/webhooks/bmac", paymentHandler.BMACWebhookHandler())
+	}
+	if dash := dashboard.New(dbClient, models, cfg); dash.Enabled() {
+		srv.Handle("/admin/", dash.Handler())
+	}
+	srv.Start()
 
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"telegram-ai-bot/internal/config"
+)
+
+// loadAllModels membaca models.json apa adanya (termasuk model yang disabled),
+// berbeda dengan config.LoadModels yang hanya mengembalikan model aktif.
+func loadAllModels(file string) ([]config.Model, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var models []config.Model
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func findExisting(models []config.Model, id, replicateID string) *config.Model {
+	for i := range models {
+		if id != "" && models[i].ID == id {
+			return &models[i]
+		}
+	}
+	for i := range models {
+		if replicateID != "" && models[i].ReplicateID == replicateID {
+			return &models[i]
+		}
+	}
+	return nil
+}
+
+// diffModels mengembalikan perbedaan yang berasal dari skema (bukan field kurasi
+// manual seperti name, cost, tier, description).
+func diffModels(existing, generated config.Model) []string {
+	var lines []string
+	field := func(name string, old, new interface{}) {
+		if !reflect.DeepEqual(old, new) {
+			lines = append(lines, fmt.Sprintf("~ %s: %v -> %v", name, old, new))
+		}
+	}
+	field("accepts_image_input", existing.AcceptsImageInput, generated.AcceptsImageInput)
+	field("accepts_multiple_images", existing.AcceptsMultipleImages, generated.AcceptsMultipleImages)
+	field("image_parameter_name", existing.ImageParameterName, generated.ImageParameterName)
+	field("configurable_aspect_ratio", existing.ConfigurableAspectRatio, generated.ConfigurableAspectRatio)
+	field("configurable_num_outputs", existing.ConfigurableNumOutputs, generated.ConfigurableNumOutputs)
+
+	oldParams := make(map[string]config.Parameter)
+	for _, p := range existing.Parameters {
+		oldParams[p.Name] = p
+	}
+	newParams := make(map[string]config.Parameter)
+	for _, p := range generated.Parameters {
+		newParams[p.Name] = p
+	}
+
+	for _, p := range generated.Parameters {
+		old, ok := oldParams[p.Name]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("+ parameter %s (%s)", p.Name, p.Type))
+			continue
+		}
+		prefix := "~ parameter " + p.Name + "."
+		if old.Type != p.Type {
+			lines = append(lines, fmt.Sprintf("%stype: %s -> %s", prefix, old.Type, p.Type))
+		}
+		if config.FormatParamValue(old.Default) != config.FormatParamValue(p.Default) {
+			lines = append(lines, fmt.Sprintf("%sdefault: %s -> %s", prefix, formatOptional(old.Default), formatOptional(p.Default)))
+		}
+		if !sameBound(old.Min, p.Min) {
+			lines = append(lines, fmt.Sprintf("%smin: %s -> %s", prefix, formatBound(old.Min), formatBound(p.Min)))
+		}
+		if !sameBound(old.Max, p.Max) {
+			lines = append(lines, fmt.Sprintf("%smax: %s -> %s", prefix, formatBound(old.Max), formatBound(p.Max)))
+		}
+		if old.MaxLength != p.MaxLength {
+			lines = append(lines, fmt.Sprintf("%smax_length: %d -> %d", prefix, old.MaxLength, p.MaxLength))
+		}
+		if strings.Join(old.Options, "|") != strings.Join(p.Options, "|") {
+			lines = append(lines, fmt.Sprintf("%soptions: [%s] -> [%s]", prefix, strings.Join(old.Options, ", "), strings.Join(p.Options, ", ")))
+		}
+	}
+	for _, p := range existing.Parameters {
+		if _, ok := newParams[p.Name]; !ok {
+			lines = append(lines, fmt.Sprintf("- parameter %s (no longer in schema)", p.Name))
+		}
+	}
+	return lines
+}
+
+// mergeModel mengambil hasil generate lalu mempertahankan field kurasi dari entri lama
+// (nama, biaya termasuk pricing, tier, deskripsi, label parameter yang sudah diterjemahkan, dll).
+func mergeModel(existing, generated config.Model) config.Model {
+	merged := generated
+	merged.ID = existing.ID
+	merged.Name = existing.Name
+	merged.Type = existing.Type
+	merged.Description = existing.Description
+	merged.Tier = existing.Tier
+	merged.Cost = existing.Cost
+	merged.DiamondCost = existing.DiamondCost
+	merged.Enabled = existing.Enabled
+	merged.ShowTemplates = existing.ShowTemplates
+	merged.Pricing = existing.Pricing
+
+	oldParams := make(map[string]config.Parameter)
+	for _, p := range existing.Parameters {
+		oldParams[p.Name] = p
+	}
+	for i, p := range merged.Parameters {
+		old, ok := oldParams[p.Name]
+		if !ok {
+			continue
+		}
+		merged.Parameters[i].Label = old.Label
+		if old.Description != "" {
+			merged.Parameters[i].Description = old.Description
+		}
+		merged.Parameters[i].OptionLabels = old.OptionLabels
+	}
+	return merged
+}
+
+func sameBound(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func formatBound(v *float64) string {
+	if v == nil {
+		return "none"
+	}
+	return config.FormatParamValue(*v)
+}
+
+func formatOptional(v interface{}) string {
+	if v == nil {
+		return "none"
+	}
+	return config.FormatParamValue(v)
+}
@@ -0,0 +1,121 @@
+// Command modelgen membuat entri models.json dari OpenAPI schema sebuah versi model Replicate.
+//
+// Ambil schema-nya dulu, misalnya:
+//
+//	curl -s -H "Authorization: Bearer $REPLICATE_API_TOKEN" \
+//	  https://api.replicate.com/v1/models/black-forest-labs/flux-schnell > flux-schnell.json
+//
+// Lalu:
+//
+//	go run ./cmd/modelgen -schema flux-schnell.json -id flux-schnell -tier basic -cost 1
+//	go run ./cmd/modelgen -schema flux-schnell.json -id flux-schnell -diff
+//	go run ./cmd/modelgen -schema flux-schnell.json -id flux-schnell -merge
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	schemaFile := flag.String("schema", "", "path ke file JSON (model, version, atau openapi_schema mentah)")
+	modelsFile := flag.String("models", "models.json", "path ke models.json untuk -diff / -merge")
+	id := flag.String("id", "", "id model di models.json (default: nama model Replicate)")
+	replicateID := flag.String("replicate-id", "", "owner/name di Replicate (default: dari file schema)")
+	name := flag.String("name", "", "nama tampilan model")
+	modelType := flag.String("type", "image", "tipe model: image atau video")
+	tier := flag.String("tier", "basic", "tier model: basic, standard, premium")
+	cost := flag.Int("cost", 1, "biaya credit per gambar")
+	diamondCost := flag.Int("diamond-cost", 0, "biaya diamond (untuk model video)")
+	skip := flag.String("skip", "", "daftar parameter tambahan yang tidak ditampilkan, dipisah koma")
+	diffMode := flag.Bool("diff", false, "tampilkan perbedaan dengan entri yang sudah ada di models.json")
+	mergeMode := flag.Bool("merge", false, "cetak entri hasil generate dengan field kurasi dari entri yang sudah ada")
+	flag.Parse()
+
+	if *schemaFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	data, err := ioutil.ReadFile(*schemaFile)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read schema file %s: %v", *schemaFile, err)
+	}
+	schema, meta, err := parseVersionFile(data)
+	if err != nil {
+		log.Fatalf("FATAL: Could not parse schema file %s: %v", *schemaFile, err)
+	}
+
+	opts := GenerateOptions{
+		ID:          *id,
+		ReplicateID: *replicateID,
+		Name:        *name,
+		Type:        *modelType,
+		Tier:        *tier,
+		Cost:        *cost,
+		DiamondCost: *diamondCost,
+	}
+	if *skip != "" {
+		opts.Skip = strings.Split(*skip, ",")
+	}
+	if opts.ReplicateID == "" && meta.Owner != "" && meta.Name != "" {
+		opts.ReplicateID = meta.Owner + "/" + meta.Name
+	}
+	if opts.ID == "" {
+		opts.ID = meta.Name
+	}
+	if opts.Name == "" && opts.ID != "" {
+		opts.Name = humanize(strings.ReplaceAll(opts.ID, "-", "_"))
+	}
+
+	generated, err := GenerateModel(schema, opts)
+	if err != nil {
+		log.Fatalf("FATAL: %v", err)
+	}
+	if generated.ReplicateID == "" {
+		log.Println("WARN: replicate_id is empty, pass -replicate-id owner/name")
+	}
+
+	if !*diffMode && !*mergeMode {
+		printJSON(generated)
+		return
+	}
+
+	models, err := loadAllModels(*modelsFile)
+	if err != nil {
+		log.Fatalf("FATAL: Could not load %s: %v", *modelsFile, err)
+	}
+	existing := findExisting(models, opts.ID, opts.ReplicateID)
+	if existing == nil {
+		log.Fatalf("FATAL: No entry with id '%s' or replicate_id '%s' in %s", opts.ID, opts.ReplicateID, *modelsFile)
+	}
+
+	if *mergeMode {
+		printJSON(mergeModel(*existing, generated))
+		return
+	}
+
+	lines := diffModels(*existing, generated)
+	if len(lines) == 0 {
+		fmt.Printf("%s is up to date with the schema.\n", existing.ID)
+		return
+	}
+	fmt.Printf("%s differs from the schema:\n", existing.ID)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	os.Exit(1)
+}
+
+func printJSON(v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalf("FATAL: Could not encode model: %v", err)
+	}
+	fmt.Println(string(out))
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"telegram-ai-bot/internal/config"
+)
+
+// schemaProperty adalah subset dari JSON Schema yang dipakai Replicate untuk input model.
+type schemaProperty struct {
+	Type        string            `json:"type"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Default     interface{}       `json:"default"`
+	Format      string            `json:"format"`
+	Minimum     *float64          `json:"minimum"`
+	Maximum     *float64          `json:"maximum"`
+	MinLength   int               `json:"minLength"`
+	MaxLength   int               `json:"maxLength"`
+	Enum        []interface{}     `json:"enum"`
+	AllOf       []schemaReference `json:"allOf"`
+	Ref         string            `json:"$ref"`
+	Items       *schemaProperty   `json:"items"`
+	XOrder      *int              `json:"x-order"`
+}
+
+type schemaReference struct {
+	Ref string `json:"$ref"`
+}
+
+type openAPISchema struct {
+	Components struct {
+		Schemas map[string]json.RawMessage `json:"schemas"`
+	} `json:"components"`
+}
+
+// versionFile menampung tiga bentuk file yang umum: respons
+// GET /models/{owner}/{name} (latest_version), GET .../versions/{id},
+// atau openapi_schema mentah.
+type versionFile struct {
+	Owner         string          `json:"owner"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	OpenAPISchema json.RawMessage `json:"openapi_schema"`
+	LatestVersion *struct {
+		OpenAPISchema json.RawMessage `json:"openapi_schema"`
+	} `json:"latest_version"`
+	Components json.RawMessage `json:"components"`
+}
+
+// GenerateOptions berisi field yang tidak bisa disimpulkan dari skema.
+type GenerateOptions struct {
+	ID          string
+	ReplicateID string
+	Name        string
+	Type        string
+	Tier        string
+	Cost        int
+	DiamondCost int
+	Skip        []string
+}
+
+// parseVersionFile membaca file schema dan mengembalikan openapi_schema + metadata model (jika ada).
+func parseVersionFile(data []byte) (*openAPISchema, *versionFile, error) {
+	var vf versionFile
+	if err := json.Unmarshal(data, &vf); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	raw := vf.OpenAPISchema
+	if len(raw) == 0 && vf.LatestVersion != nil {
+		raw = vf.LatestVersion.OpenAPISchema
+	}
+	if len(raw) == 0 && len(vf.Components) > 0 {
+		raw = data
+	}
+	if len(raw) == 0 {
+		return nil, nil, fmt.Errorf("no openapi_schema found in file")
+	}
+
+	var schema openAPISchema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, nil, fmt.Errorf("invalid openapi_schema: %w", err)
+	}
+	if _, ok := schema.Components.Schemas["Input"]; !ok {
+		return nil, nil, fmt.Errorf("openapi_schema has no components.schemas.Input")
+	}
+	return &schema, &vf, nil
+}
+
+// resolve mengikuti $ref / allOf ke components.schemas (dipakai Replicate untuk enum).
+func (s *openAPISchema) resolve(prop schemaProperty) schemaProperty {
+	ref := prop.Ref
+	if ref == "" && len(prop.AllOf) > 0 {
+		ref = prop.AllOf[0].Ref
+	}
+	if ref == "" {
+		return prop
+	}
+
+	name := strings.TrimPrefix(ref, "#/components/schemas/")
+	raw, ok := s.Components.Schemas[name]
+	if !ok {
+		return prop
+	}
+	var target schemaProperty
+	if err := json.Unmarshal(raw, &target); err != nil {
+		return prop
+	}
+
+	// Field pada properti sendiri (default, description, x-order) menang atas target
+	if prop.Type == "" {
+		prop.Type = target.Type
+	}
+	if len(prop.Enum) == 0 {
+		prop.Enum = target.Enum
+	}
+	if prop.Description == "" {
+		prop.Description = target.Description
+	}
+	// Title target berisi nama enum (misal "aspect_ratio"), bukan label, jadi tidak disalin
+	return prop
+}
+
+// GenerateModel membangun entri config.Model dari skema Input sebuah versi model.
+func GenerateModel(schema *openAPISchema, opts GenerateOptions) (config.Model, error) {
+	var input struct {
+		Properties map[string]schemaProperty `json:"properties"`
+		Required   []string                  `json:"required"`
+	}
+	if err := json.Unmarshal(schema.Components.Schemas["Input"], &input); err != nil {
+		return config.Model{}, fmt.Errorf("invalid Input schema: %w", err)
+	}
+
+	names := make([]string, 0, len(input.Properties))
+	for name := range input.Properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		oi, oj := orderOf(input.Properties[names[i]]), orderOf(input.Properties[names[j]])
+		if oi != oj {
+			return oi < oj
+		}
+		return names[i] < names[j]
+	})
+
+	skip := map[string]bool{"prompt": true}
+	for _, name := range opts.Skip {
+		skip[strings.TrimSpace(name)] = true
+	}
+
+	model := config.Model{
+		ID:          opts.ID,
+		Name:        opts.Name,
+		Type:        opts.Type,
+		ReplicateID: opts.ReplicateID,
+		Tier:        opts.Tier,
+		Cost:        opts.Cost,
+		DiamondCost: opts.DiamondCost,
+		Enabled:     false, // entri baru harus dicek manual sebelum diaktifkan
+	}
+
+	imageParam, multiple := detectImageParameter(input.Properties, names)
+	if imageParam != "" {
+		model.AcceptsImageInput = true
+		model.AcceptsMultipleImages = multiple
+		model.ImageParameterName = imageParam
+	}
+
+	for _, name := range names {
+		if skip[name] || name == imageParam {
+			continue
+		}
+		prop := schema.resolve(input.Properties[name])
+		if isURIProperty(prop) {
+			// Input file lain (mask, audio, dll) tidak bisa diisi lewat dashboard
+			continue
+		}
+
+		param, ok := toParameter(name, prop)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "aspect_ratio":
+			model.ConfigurableAspectRatio = true
+		case "num_outputs":
+			model.ConfigurableNumOutputs = true
+		}
+		model.Parameters = append(model.Parameters, param)
+	}
+
+	return model, nil
+}
+
+func toParameter(name string, prop schemaProperty) (config.Parameter, bool) {
+	param := config.Parameter{
+		Name:        name,
+		Label:       prop.Title,
+		Description: strings.TrimSpace(prop.Description),
+		Default:     prop.Default,
+		Min:         prop.Minimum,
+		Max:         prop.Maximum,
+		MinLength:   prop.MinLength,
+		MaxLength:   prop.MaxLength,
+	}
+	if param.Label == "" {
+		param.Label = humanize(name)
+	}
+
+	switch prop.Type {
+	case "integer":
+		param.Type = config.ParamTypeInteger
+	case "number":
+		param.Type = config.ParamTypeNumber
+	case "boolean":
+		param.Type = config.ParamTypeBoolean
+	case "string", "":
+		param.Type = config.ParamTypeString
+	default:
+		// array/object tidak didukung oleh dashboard
+		return config.Parameter{}, false
+	}
+
+	for _, value := range prop.Enum {
+		param.Options = append(param.Options, config.FormatParamValue(value))
+	}
+	return param, true
+}
+
+// detectImageParameter memilih properti URI yang paling mungkin merupakan gambar input.
+// Properti dengan nama mengandung "image" diutamakan.
+func detectImageParameter(props map[string]schemaProperty, ordered []string) (string, bool) {
+	var fallback string
+	var fallbackMultiple bool
+	for _, name := range ordered {
+		prop := props[name]
+		multiple := prop.Type == "array"
+		if !isURIProperty(prop) {
+			continue
+		}
+		lower := strings.ToLower(name)
+		if strings.Contains(lower, "image") && !strings.Contains(lower, "mask") {
+			return name, multiple
+		}
+		if fallback == "" && !strings.Contains(lower, "mask") && !strings.Contains(lower, "audio") && !strings.Contains(lower, "video") {
+			fallback, fallbackMultiple = name, multiple
+		}
+	}
+	return fallback, fallbackMultiple
+}
+
+func isURIProperty(prop schemaProperty) bool {
+	if prop.Type == "string" && prop.Format == "uri" {
+		return true
+	}
+	return prop.Type == "array" && prop.Items != nil && prop.Items.Format == "uri"
+}
+
+func orderOf(prop schemaProperty) int {
+	if prop.XOrder != nil {
+		return *prop.XOrder
+	}
+	return 1 << 30
+}
+
+func humanize(name string) string {
+	words := strings.Fields(strings.ReplaceAll(name, "_", " "))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
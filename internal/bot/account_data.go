@@ -0,0 +1,334 @@
+package bot
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	dataExportCooldown   = 10 * time.Minute // jarak minimal antar /mydata per user
+	dataExportBatch      = 1000
+	dataExportMaxRows    = 50000
+	deleteConfirmTimeout = 5 * time.Minute
+)
+
+// pendingDeletion adalah /deleteme yang menunggu konfirmasi di pesan tertentu.
+type pendingDeletion struct {
+	ChatID    int64
+	MessageID int
+	ExpiresAt time.Time
+}
+
+// accountStore menyimpan konfirmasi /deleteme dan waktu ekspor /mydata terakhir per user.
+type accountStore struct {
+	mu        sync.Mutex
+	deletions map[int64]*pendingDeletion
+	exports   map[int64]time.Time
+}
+
+func newAccountStore() *accountStore {
+	return &accountStore{deletions: make(map[int64]*pendingDeletion), exports: make(map[int64]time.Time)}
+}
+
+func (s *accountStore) putDeletion(userID int64, d *pendingDeletion) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deletions[userID] = d
+}
+
+// takeDeletion mengambil (dan menghapus) konfirmasi milik user untuk pesan tertentu, nil jika tidak ada atau kedaluwarsa.
+func (s *accountStore) takeDeletion(userID, chatID int64, messageID int) *pendingDeletion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deletions[userID]
+	if !ok || d.ChatID != chatID || d.MessageID != messageID {
+		return nil
+	}
+	delete(s.deletions, userID)
+	if time.Now().After(d.ExpiresAt) {
+		return nil
+	}
+	return d
+}
+
+// allowExport mencatat ekspor baru jika cooldown sudah lewat; jika belum, mengembalikan sisa waktunya.
+func (s *accountStore) allowExport(userID int64) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.exports[userID]; ok {
+		if wait := dataExportCooldown - time.Since(last); wait > 0 {
+			return false, wait
+		}
+	}
+	s.exports[userID] = time.Now()
+	return true, 0
+}
+
+// privateOnly menolak perintah data pribadi di grup agar file ekspor / konfirmasi hapus tidak terlihat anggota lain.
+func (h *Handler) privateOnly(message *tgbotapi.Message, lang string) bool {
+	if message.Chat.IsPrivate() {
+		return true
+	}
+	h.Bot.Send(h.newReplyMessage(message, h.Localizer.Get(lang, "account_private_only")))
+	return false
+}
+
+// handleMyData menangani /mydata: mengirim ZIP berisi semua data yang disimpan bot tentang user.
+func (h *Handler) handleMyData(message *tgbotapi.Message) {
+	user, err := h.getOrCreateUser(message.From)
+	if err != nil {
+		return
+	}
+	lang := user.LanguageCode
+	if !h.privateOnly(message, lang) {
+		return
+	}
+	if ok, wait := h.accounts.allowExport(user.TelegramID); !ok {
+		minutes := int(wait.Minutes()) + 1
+		h.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, h.Localizer.Getf(lang, "mydata_cooldown", map[string]string{"minutes": strconv.Itoa(minutes)})))
+		return
+	}
+
+	waitMsg, _ := h.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, h.Localizer.Get(lang, "mydata_preparing")))
+	defer h.Bot.Send(tgbotapi.NewDeleteMessage(message.Chat.ID, waitMsg.MessageID))
+
+	data, err := h.buildDataExport(user)
+	if err != nil {
+		log.Printf("ERROR: Failed to build data export for user %d: %v", user.TelegramID, err)
+		h.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, h.Localizer.Get(lang, "mydata_error")))
+		return
+	}
+
+	doc := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("mydata_%d_%s.zip", user.TelegramID, time.Now().UTC().Format("20060102")),
+		Bytes: data,
+	})
+	doc.Caption = h.Localizer.Get(lang, "mydata_caption")
+	if _, err := h.Bot.Send(doc); err != nil {
+		log.Printf("ERROR: Failed to send data export to user %d: %v", user.TelegramID, err)
+		return
+	}
+	log.Printf("INFO: Sent data export to user %d (%d bytes)", user.TelegramID, len(data))
+}
+
+// dataExportFiles menjelaskan isi ZIP di export.json.
+var dataExportFiles = map[string]string{
+	"profile.json":           "Your user record: balances, tier, moderation status, referrer and timestamps",
+	"settings.json":          "Generation settings, language, notifications and spending limits",
+	"transactions.json":      "Every change to your balances",
+	"payments.json":          "Telegram Stars payments and manual transfers",
+	"generations.json":       "Image and video generations with prompts and output links (links expire at the provider)",
+	"referral_rewards.json":  "Referral rewards you received",
+	"promo_redemptions.json": "Promo codes you redeemed",
+	"prompt_flags.json":      "Prompts and images flagged by content moderation",
+	"chat_history.json":      "Current AI chat memory (kept in memory only, cleared on restart)",
+}
+
+// buildDataExport mengumpulkan data user dari semua tabel dan membungkusnya dalam ZIP berisi file JSON.
+func (h *Handler) buildDataExport(user *database.User) ([]byte, error) {
+	id := user.TelegramID
+
+	var transactions []database.CreditTransaction
+	for offset := 0; offset < dataExportMaxRows; offset += dataExportBatch {
+		batch, _, err := h.DB.GetTransactions(id, offset, dataExportBatch)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, batch...)
+		if len(batch) < dataExportBatch {
+			break
+		}
+	}
+
+	var generations []database.Generation
+	for offset := 0; offset < dataExportMaxRows; offset += dataExportBatch {
+		batch, err := h.DB.GetUserGenerations(id, offset, dataExportBatch)
+		if err != nil {
+			return nil, err
+		}
+		generations = append(generations, batch...)
+		if len(batch) < dataExportBatch {
+			break
+		}
+	}
+
+	payments, err := h.DB.GetUserPayments(id, dataExportBatch)
+	if err != nil {
+		return nil, err
+	}
+	manual, err := h.DB.GetUserManualPayments(id, dataExportBatch)
+	if err != nil {
+		return nil, err
+	}
+	rewards, err := h.DB.GetUserReferralRewards(id, dataExportBatch)
+	if err != nil {
+		return nil, err
+	}
+	redemptions, err := h.DB.GetUserPromoRedemptions(id, dataExportBatch)
+	if err != nil {
+		return nil, err
+	}
+	flags, _, err := h.DB.GetPromptFlags(id, "", dataExportBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	var customSettings interface{}
+	if user.CustomSettings != "" {
+		if json.Unmarshal([]byte(user.CustomSettings), &customSettings) != nil {
+			customSettings = user.CustomSettings
+		}
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"export.json", map[string]interface{}{
+			"telegram_id": id,
+			"exported_at": time.Now().UTC(),
+			"files":       dataExportFiles,
+		}},
+		{"profile.json", user},
+		{"settings.json", map[string]interface{}{
+			"language":            user.LanguageCode,
+			"aspect_ratio":        user.AspectRatio,
+			"num_outputs":         user.NumOutputs,
+			"custom_settings":     customSettings,
+			"notify_free_credits": user.NotifyFreeCredits,
+			"spend_limits":        user.SpendLimits,
+		}},
+		{"transactions.json", transactions},
+		{"payments.json", map[string]interface{}{"stars": payments, "manual": manual}},
+		{"generations.json", generations},
+		{"referral_rewards.json", rewards},
+		{"promo_redemptions.json", redemptions},
+		{"prompt_flags.json", flags},
+		{"chat_history.json", h.getChatHistory(id)},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		content, err := json.MarshalIndent(f.data, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", f.name, err)
+		}
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// handleDeleteMe menangani /deleteme: menampilkan apa yang akan dihapus dan meminta konfirmasi.
+func (h *Handler) handleDeleteMe(message *tgbotapi.Message) {
+	user, err := h.getOrCreateUser(message.From)
+	if err != nil {
+		return
+	}
+	lang := user.LanguageCode
+	if !h.privateOnly(message, lang) {
+		return
+	}
+	if h.hasRenewingSubscription(user) {
+		h.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, h.Localizer.Get(lang, "deleteme_subscription_active")))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, h.Localizer.Getf(lang, "deleteme_confirm", map[string]string{
+		"balances": h.walletBalanceLines(user, lang, "▸ {name}: <b>{balance}</b> {symbol}"),
+	}))
+	msg.ParseMode = "HTML"
+	keyboard := h.createDeleteAccountKeyboard(lang)
+	msg.ReplyMarkup = &keyboard
+	sent, err := h.Bot.Send(msg)
+	if err != nil {
+		return
+	}
+	h.accounts.putDeletion(user.TelegramID, &pendingDeletion{
+		ChatID:    message.Chat.ID,
+		MessageID: sent.MessageID,
+		ExpiresAt: time.Now().Add(deleteConfirmTimeout),
+	})
+}
+
+// hasRenewingSubscription: langganan Stars yang masih diperpanjang otomatis harus dibatalkan dulu,
+// jika tidak Telegram tetap menagih user yang datanya sudah dihapus.
+func (h *Handler) hasRenewingSubscription(user *database.User) bool {
+	return user.SubscriptionChargeID != "" && !user.SubscriptionCanceled && h.PaymentHandler.IsPremiumActive(user)
+}
+
+// handleDeleteMeCallback menangani tombol deleteme_confirm / deleteme_cancel.
+func (h *Handler) handleDeleteMeCallback(callback *tgbotapi.CallbackQuery, confirmed bool) {
+	user, err := h.DB.GetUserByTelegramID(callback.From.ID)
+	if err != nil || user == nil {
+		return
+	}
+	lang := user.LanguageCode
+	chatID, messageID := callback.Message.Chat.ID, callback.Message.MessageID
+	edit := func(key string) {
+		h.Bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, h.Localizer.Get(lang, key)))
+	}
+
+	if h.accounts.takeDeletion(user.TelegramID, chatID, messageID) == nil {
+		edit("deleteme_expired")
+		return
+	}
+	if !confirmed {
+		edit("deleteme_cancelled")
+		return
+	}
+	// Dicek ulang: user bisa berlangganan lagi selama pesan konfirmasi terbuka
+	if h.hasRenewingSubscription(user) {
+		edit("deleteme_subscription_active")
+		return
+	}
+
+	if err := h.DB.AnonymizeUser(user.TelegramID, time.Now()); err != nil {
+		edit("deleteme_error")
+		return
+	}
+	// Saldo yang hangus tetap tercatat di riwayat agar pembukuan seimbang
+	for _, cur := range h.Wallet.Currencies {
+		if balance := h.Wallet.Balance(user, cur.ID); balance > 0 {
+			h.DB.Record(user.TelegramID, database.TransactionAccountDeleted, cur.ID, -balance, "account deleted")
+		}
+	}
+	h.forgetUser(user.TelegramID)
+	log.Printf("INFO: User %d deleted their account data", user.TelegramID)
+	edit("deleteme_done")
+}
+
+// forgetUser membersihkan data user yang hanya ada di memori.
+func (h *Handler) forgetUser(userID int64) {
+	h.clearChatHistory(userID)
+
+	h.userStatesMutex.Lock()
+	delete(h.userStates, userID)
+	delete(h.pendingGenerations, userID)
+	h.userStatesMutex.Unlock()
+
+	h.lastGeneratedURLsMutex.Lock()
+	delete(h.lastGeneratedURLs, userID)
+	h.lastGeneratedURLsMutex.Unlock()
+
+	h.moderation.invalidate(userID)
+}
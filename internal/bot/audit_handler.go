@@ -0,0 +1,283 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	auditPageSize  = 10
+	auditCSVBatch  = 1000
+	auditCSVMaxRow = 50000
+)
+
+// handleAudit menangani /audit untuk admin:
+//
+//	/audit [action=ACTION] [actor=ID] [target=ID|@username] [since=7d|12h|YYYY-MM-DD]
+//	/audit export [filter yang sama]
+func (h *Handler) handleAudit(message *tgbotapi.Message) {
+	lang := "en"
+	parts := strings.Fields(message.CommandArguments())
+	export := len(parts) > 0 && strings.ToLower(parts[0]) == "export"
+	if export {
+		parts = parts[1:]
+	}
+
+	filter, err := h.parseAuditFilter(parts)
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Getf(lang, "audit_invalid_filter", map[string]string{"error": html.EscapeString(err.Error())}))
+		return
+	}
+	if export {
+		h.sendAuditCSV(message.Chat.ID, message.From.ID, filter)
+		return
+	}
+
+	text, keyboard := h.buildAuditPage(filter, 0)
+	msg := h.newReplyMessage(message, text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	h.Bot.Send(msg)
+}
+
+func (h *Handler) parseAuditFilter(parts []string) (database.AuditFilter, error) {
+	var filter database.AuditFilter
+	for _, option := range parts {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok || value == "" {
+			return filter, fmt.Errorf("expected key=value, got %s", option)
+		}
+		switch strings.ToLower(key) {
+		case "action":
+			filter.Action = strings.ToLower(value)
+		case "actor":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return filter, fmt.Errorf("invalid actor %s", value)
+			}
+			filter.ActorID = id
+		case "target":
+			user, err := h.resolveUserArg(value)
+			if err != nil {
+				return filter, fmt.Errorf("could not look up %s", value)
+			}
+			if user != nil {
+				filter.TargetID = user.TelegramID
+			} else if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+				// User yang sudah dihapus tetap bisa dicari dengan ID
+				filter.TargetID = id
+			} else {
+				return filter, fmt.Errorf("user %s not found", value)
+			}
+		case "since":
+			since, err := parseAuditSince(value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid since %s", value)
+			}
+			filter.Since = since
+		default:
+			return filter, fmt.Errorf("unknown filter %s", key)
+		}
+	}
+	return filter, nil
+}
+
+// parseAuditSince menerima durasi ke belakang (7d, 12h) atau tanggal YYYY-MM-DD (UTC).
+func parseAuditSince(value string) (time.Time, error) {
+	if d, err := parseModerationDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// encodeAuditFilter meringkas filter untuk data tombol (batas 64 byte): angka ditulis dalam basis 36.
+func encodeAuditFilter(filter database.AuditFilter) string {
+	since := int64(0)
+	if !filter.Since.IsZero() {
+		since = filter.Since.Unix()
+	}
+	return strings.Join([]string{
+		filter.Action,
+		strconv.FormatInt(filter.ActorID, 36),
+		strconv.FormatInt(filter.TargetID, 36),
+		strconv.FormatInt(since, 36),
+	}, "|")
+}
+
+func decodeAuditFilter(data string) database.AuditFilter {
+	var filter database.AuditFilter
+	fields := strings.Split(data, "|")
+	if len(fields) != 4 {
+		return filter
+	}
+	filter.Action = fields[0]
+	filter.ActorID, _ = strconv.ParseInt(fields[1], 36, 64)
+	filter.TargetID, _ = strconv.ParseInt(fields[2], 36, 64)
+	if since, _ := strconv.ParseInt(fields[3], 36, 64); since > 0 {
+		filter.Since = time.Unix(since, 0)
+	}
+	return filter
+}
+
+// handleAuditPage menangani tombol audit:<page>:<filter>.
+func (h *Handler) handleAuditPage(callback *tgbotapi.CallbackQuery, data string) {
+	if !h.can(callback.From.ID, permAudit) {
+		return
+	}
+	pageStr, encoded, _ := strings.Cut(data, ":")
+	page, _ := strconv.Atoi(pageStr)
+	text, keyboard := h.buildAuditPage(decodeAuditFilter(encoded), page)
+
+	edit := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	edit.ParseMode = "HTML"
+	edit.DisableWebPagePreview = true
+	edit.ReplyMarkup = keyboard
+	h.Bot.Send(edit)
+}
+
+// handleAuditCSV menangani tombol audit_csv:<filter>.
+func (h *Handler) handleAuditCSV(callback *tgbotapi.CallbackQuery, data string) {
+	if !h.can(callback.From.ID, permAudit) {
+		return
+	}
+	h.sendAuditCSV(callback.Message.Chat.ID, callback.From.ID, decodeAuditFilter(data))
+}
+
+func (h *Handler) buildAuditPage(filter database.AuditFilter, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	lang := "en"
+	if page < 0 {
+		page = 0
+	}
+	entries, total, err := h.DB.GetAuditEntries(filter, page*auditPageSize, auditPageSize)
+	if err != nil {
+		return h.Localizer.Get(lang, "audit_error"), nil
+	}
+	if total == 0 {
+		return h.Localizer.Get(lang, "audit_empty"), nil
+	}
+
+	pages := (total + auditPageSize - 1) / auditPageSize
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = formatAuditLine(entry)
+	}
+	text := h.Localizer.Getf(lang, "audit_title", map[string]string{
+		"filter": html.EscapeString(describeAuditFilter(filter)),
+		"total":  strconv.Itoa(total),
+		"page":   strconv.Itoa(page + 1),
+		"pages":  strconv.Itoa(pages),
+		"lines":  strings.Join(lines, "\n\n"),
+	})
+	keyboard := h.createAuditKeyboard(page, pages, encodeAuditFilter(filter))
+	return text, &keyboard
+}
+
+func formatAuditLine(entry database.AuditEntry) string {
+	line := fmt.Sprintf("<code>%s</code> <b>%s</b> · admin <code>%d</code>", formatHistoryTime(entry.CreatedAt), html.EscapeString(entry.Action), entry.ActorID)
+	if entry.TargetID != nil {
+		line += fmt.Sprintf(" → <code>%d</code>", *entry.TargetID)
+	}
+	if params := auditParamsText(entry.Params); params != "" {
+		// Teks broadcast bisa panjang, potong agar satu halaman tetap muat di satu pesan
+		runes := []rune(params)
+		if len(runes) > 200 {
+			params = string(runes[:200]) + "…"
+		}
+		line += "\n" + html.EscapeString(params)
+	}
+	return line
+}
+
+func auditParamsText(params map[string]interface{}) string {
+	if len(params) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func describeAuditFilter(filter database.AuditFilter) string {
+	var parts []string
+	if filter.Action != "" {
+		parts = append(parts, "action="+filter.Action)
+	}
+	if filter.ActorID != 0 {
+		parts = append(parts, fmt.Sprintf("actor=%d", filter.ActorID))
+	}
+	if filter.TargetID != 0 {
+		parts = append(parts, fmt.Sprintf("target=%d", filter.TargetID))
+	}
+	if !filter.Since.IsZero() {
+		parts = append(parts, "since="+filter.Since.UTC().Format("2006-01-02 15:04"))
+	}
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, " ")
+}
+
+// sendAuditCSV mengirim audit log yang cocok dengan filter sebagai dokumen CSV. Ekspornya sendiri juga dicatat.
+func (h *Handler) sendAuditCSV(chatID, adminID int64, filter database.AuditFilter) {
+	lang := "en"
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "time_utc", "actor_id", "action", "target_id", "params"})
+	rows := 0
+	for offset := 0; offset < auditCSVMaxRow; offset += auditCSVBatch {
+		entries, _, err := h.DB.GetAuditEntries(filter, offset, auditCSVBatch)
+		if err != nil {
+			h.Bot.Send(tgbotapi.NewMessage(chatID, h.Localizer.Get(lang, "audit_error")))
+			return
+		}
+		for _, entry := range entries {
+			target := ""
+			if entry.TargetID != nil {
+				target = strconv.FormatInt(*entry.TargetID, 10)
+			}
+			createdAt := ""
+			if entry.CreatedAt != nil {
+				createdAt = entry.CreatedAt.UTC().Format(time.RFC3339)
+			}
+			w.Write([]string{
+				strconv.FormatInt(entry.ID, 10),
+				createdAt,
+				strconv.FormatInt(entry.ActorID, 10),
+				entry.Action,
+				target,
+				auditParamsText(entry.Params),
+			})
+		}
+		rows += len(entries)
+		if len(entries) < auditCSVBatch {
+			break
+		}
+	}
+	w.Flush()
+	h.DB.RecordAudit(adminID, database.AuditExport, 0, map[string]interface{}{"filter": describeAuditFilter(filter), "rows": rows})
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("audit_%s.csv", time.Now().UTC().Format("20060102_150405")),
+		Bytes: buf.Bytes(),
+	})
+	doc.Caption = h.Localizer.Getf(lang, "audit_csv_caption", map[string]string{
+		"rows":   strconv.Itoa(rows),
+		"filter": describeAuditFilter(filter),
+	})
+	h.Bot.Send(doc)
+}
@@ -0,0 +1,49 @@
+package bot
+
+import "telegram-ai-bot/internal/database"
+
+// broadcastProgressEvery adalah jumlah pesan di antara dua penyimpanan progres broadcast.
+const broadcastProgressEvery = 25
+
+// broadcastProgress menyimpan progres /broadcast dan /broadcastgroup ke tabel broadcast_jobs
+// agar statusnya terlihat di dashboard admin. Jika job gagal dibuat, broadcast tetap jalan tanpa dicatat.
+type broadcastProgress struct {
+	db     *database.Client
+	id     int64
+	sent   int
+	failed int
+}
+
+func (h *Handler) startBroadcastJob(kind string, actorID int64, text, photo string, total int) *broadcastProgress {
+	p := &broadcastProgress{db: h.DB}
+	job, err := h.DB.CreateBroadcastJob(&database.BroadcastJob{
+		Kind:    kind,
+		ActorID: actorID,
+		Text:    text,
+		Photo:   photo,
+		Total:   total,
+		Status:  database.BroadcastRunning,
+	})
+	if err == nil {
+		p.id = job.ID
+	}
+	return p
+}
+
+// add mencatat hasil satu pesan.
+func (p *broadcastProgress) add(ok bool) {
+	if ok {
+		p.sent++
+	} else {
+		p.failed++
+	}
+	if p.id != 0 && (p.sent+p.failed)%broadcastProgressEvery == 0 {
+		p.db.UpdateBroadcastJob(p.id, p.sent, p.failed, false)
+	}
+}
+
+func (p *broadcastProgress) finish() {
+	if p.id != 0 {
+		p.db.UpdateBroadcastJob(p.id, p.sent, p.failed, true)
+	}
+}
@@ -0,0 +1,240 @@
+package bot
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"telegram-ai-bot/internal/captcha"
+	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	captchaTimeout  = 10 * time.Minute // umur satu tantangan
+	captchaLockTime = 15 * time.Minute // kunci setelah salah CaptchaMaxAttempts kali
+)
+
+// pendingCaptcha adalah tantangan anti-bot yang sedang menunggu jawaban user.
+type pendingCaptcha struct {
+	Challenge *captcha.Challenge
+	ChatID    int64
+	MessageID int
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+type captchaStore struct {
+	mu          sync.Mutex
+	pending     map[int64]*pendingCaptcha
+	lockedUntil map[int64]time.Time
+	promos      map[int64]string // kode promo yang ditukar sebelum lulus tantangan, dipakai setelah lulus
+}
+
+func newCaptchaStore() *captchaStore {
+	return &captchaStore{pending: make(map[int64]*pendingCaptcha), lockedUntil: make(map[int64]time.Time), promos: make(map[int64]string)}
+}
+
+func (s *captchaStore) deferPromo(userID int64, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.promos[userID] = code
+}
+
+func (s *captchaStore) takePromo(userID int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := s.promos[userID]
+	delete(s.promos, userID)
+	return code
+}
+
+// onboarding menentukan apakah user baru langsung dianggap manusia (CAPTCHA_MODE off atau
+// referral yang memenuhi CAPTCHA_SKIP_REFERRED) dan free credit awalnya.
+func (h *Handler) onboarding(referrerID int64) (verified bool, freeCredits int) {
+	if h.Config.CaptchaMode == config.CaptchaOff || h.skipCaptchaForReferral(referrerID) {
+		return true, h.newUserFreeCredits()
+	}
+	return false, 0
+}
+
+func (h *Handler) skipCaptchaForReferral(referrerID int64) bool {
+	if referrerID == 0 {
+		return false
+	}
+	switch h.Config.CaptchaSkipReferred {
+	case config.CaptchaSkipAny:
+		return true
+	case config.CaptchaSkipTrusted:
+		referrer, err := h.DB.GetUserByTelegramID(referrerID)
+		if err != nil || referrer == nil || referrer.IsBanned {
+			return false
+		}
+		if flagged, err := h.DB.IsReferrerFlagged(referrerID); err != nil || flagged {
+			return false
+		}
+		purchased, err := h.DB.HasCompletedPurchase(referrerID)
+		return err == nil && purchased
+	}
+	return false
+}
+
+// lockedMinutes mengembalikan sisa menit kunci user, 0 jika tidak dikunci.
+func (s *captchaStore) lockedMinutes(userID int64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.lockedUntil[userID]
+	if !ok || time.Now().After(until) {
+		return 0
+	}
+	return int(math.Ceil(time.Until(until).Minutes()))
+}
+
+// sendCaptcha mengirim tantangan baru ke user yang belum terverifikasi (menggantikan tantangan lama).
+// notice adalah kunci teks opsional yang ditampilkan di atas tantangan (misalnya jawaban salah).
+func (h *Handler) sendCaptcha(chatID int64, user *database.User, notice string) {
+	lang := user.LanguageCode
+	if minutes := h.captchas.lockedMinutes(user.TelegramID); minutes > 0 {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, h.Localizer.Getf(lang, "captcha_locked", map[string]string{"minutes": strconv.Itoa(minutes)})))
+		return
+	}
+
+	h.captchas.mu.Lock()
+	old := h.captchas.pending[user.TelegramID]
+	h.captchas.mu.Unlock()
+	attempts := 0
+	if old != nil {
+		attempts = old.Attempts
+		h.Bot.Request(tgbotapi.NewDeleteMessage(old.ChatID, old.MessageID))
+	}
+	prefix := ""
+	if notice != "" {
+		prefix = h.Localizer.Get(lang, notice) + "\n\n"
+	}
+
+	var challenge *captcha.Challenge
+	if h.Config.CaptchaMode == config.CaptchaImage {
+		var err error
+		if challenge, err = captcha.NewImage(); err != nil {
+			log.Printf("ERROR: Failed to render captcha image for user %d: %v", user.TelegramID, err)
+			challenge = captcha.NewButtons()
+		}
+	} else {
+		challenge = captcha.NewButtons()
+	}
+
+	keyboard := h.createCaptchaKeyboard(challenge)
+	var sent tgbotapi.Message
+	var err error
+	if challenge.Image != nil {
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "captcha.png", Bytes: challenge.Image})
+		photo.Caption = prefix + h.Localizer.Getf(lang, "captcha_image", map[string]string{"amount": strconv.Itoa(h.newUserFreeCredits())})
+		photo.ParseMode = "HTML"
+		photo.ReplyMarkup = keyboard
+		sent, err = h.Bot.Send(photo)
+	} else {
+		msg := tgbotapi.NewMessage(chatID, prefix+h.Localizer.Getf(lang, "captcha_buttons", map[string]string{
+			"target": challenge.Target,
+			"amount": strconv.Itoa(h.newUserFreeCredits()),
+		}))
+		msg.ParseMode = "HTML"
+		msg.ReplyMarkup = keyboard
+		sent, err = h.Bot.Send(msg)
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to send captcha to user %d: %v", user.TelegramID, err)
+		return
+	}
+
+	h.captchas.mu.Lock()
+	h.captchas.pending[user.TelegramID] = &pendingCaptcha{
+		Challenge: challenge,
+		ChatID:    chatID,
+		MessageID: sent.MessageID,
+		ExpiresAt: time.Now().Add(captchaTimeout),
+		Attempts:  attempts,
+	}
+	h.captchas.mu.Unlock()
+}
+
+// handleCaptchaAnswer menangani tombol captcha:<index>.
+func (h *Handler) handleCaptchaAnswer(callback *tgbotapi.CallbackQuery, data string) {
+	user, err := h.getOrCreateUser(callback.From)
+	if err != nil || user.HumanVerified {
+		return
+	}
+	chatID, messageID := callback.Message.Chat.ID, callback.Message.MessageID
+	choice, _ := strconv.Atoi(data)
+
+	h.captchas.mu.Lock()
+	pending := h.captchas.pending[user.TelegramID]
+	if pending == nil || pending.MessageID != messageID || pending.ChatID != chatID {
+		// Tantangan lama (mis. setelah bot restart): ganti dengan yang baru
+		h.captchas.mu.Unlock()
+		h.Bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID))
+		h.sendCaptcha(chatID, user, "")
+		return
+	}
+	if time.Now().After(pending.ExpiresAt) {
+		h.captchas.mu.Unlock()
+		h.sendCaptcha(chatID, user, "captcha_expired")
+		return
+	}
+	if choice != pending.Challenge.Answer {
+		pending.Attempts++
+		notice := "captcha_wrong"
+		if pending.Attempts >= h.Config.CaptchaMaxAttempts {
+			delete(h.captchas.pending, user.TelegramID)
+			h.captchas.lockedUntil[user.TelegramID] = time.Now().Add(captchaLockTime)
+			h.Bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID))
+			log.Printf("WARN: User %d failed the onboarding challenge %d times", user.TelegramID, pending.Attempts)
+			notice = ""
+		}
+		h.captchas.mu.Unlock()
+		h.sendCaptcha(chatID, user, notice)
+		return
+	}
+	delete(h.captchas.pending, user.TelegramID)
+	h.captchas.mu.Unlock()
+
+	h.Bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID))
+	h.completeVerification(chatID, user)
+}
+
+// completeVerification memberikan free credit awal dan bonus referral yang ditunda sampai user lulus.
+func (h *Handler) completeVerification(chatID int64, user *database.User) {
+	lang := user.LanguageCode
+	credits := h.newUserFreeCredits()
+	now := time.Now()
+	verified, err := h.DB.VerifyHuman(user.TelegramID, credits, now)
+	if err != nil {
+		h.Bot.Send(tgbotapi.NewMessage(chatID, h.Localizer.Get(lang, "captcha_error")))
+		return
+	}
+	if !verified {
+		return
+	}
+	user.HumanVerified = true
+	user.FreeCredits = credits
+	user.LastFreeCreditsReset = now
+	log.Printf("INFO: User %d passed the onboarding challenge", user.TelegramID)
+
+	h.captchas.mu.Lock()
+	delete(h.captchas.lockedUntil, user.TelegramID)
+	h.captchas.mu.Unlock()
+
+	msg := tgbotapi.NewMessage(chatID, h.Localizer.Getf(lang, "captcha_passed", map[string]string{"amount": strconv.Itoa(credits)}))
+	msg.ParseMode = "HTML"
+	h.Bot.Send(msg)
+
+	if user.ReferrerID != 0 {
+		h.Referrals.OnSignup(user)
+	}
+	if code := h.captchas.takePromo(user.TelegramID); code != "" {
+		h.redeemPromoCode(chatID, user, code)
+	}
+}
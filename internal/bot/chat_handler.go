@@ -6,11 +6,14 @@ import (
 	"html"
 	"log"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/pricing"
+	"telegram-ai-bot/internal/wallet"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -58,7 +61,8 @@ func (h *Handler) handleChatModeStart(message *tgbotapi.Message, modelID string)
 	h.clearChatHistory(user.TelegramID)
 
 	modelName := extractModelName(modelID)
-	text := fmt.Sprintf("🟢 <b>Chat Mode Active!</b>\n\n🧠 Brain: <code>%s</code>\nCredits: 1 per reply.\n\nSend text or photo to start.", modelName)
+	replyCost := h.quoteFeature(user, pricing.FeatureChat, modelID, 0)
+	text := fmt.Sprintf("🟢 <b>Chat Mode Active!</b>\n\n🧠 Brain: <code>%s</code>\nCredits: %d per reply.\n\nSend text or photo to start.", modelName, replyCost)
 	
 	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	msg.ParseMode = "HTML"
@@ -77,8 +81,6 @@ func (h *Handler) handleChatMessage(message *tgbotapi.Message) {
 	lang := user.LanguageCode
 	userID := user.TelegramID
 
-	cost := 1
-	
 	// LOGGING: Cek pesan masuk
 	log.Printf("DEBUG: Chat Message Received from %d: %s", userID, message.Text)
 
@@ -117,14 +119,26 @@ func (h *Handler) handleChatMessage(message *tgbotapi.Message) {
 
 	h.Bot.Send(tgbotapi.NewChatAction(message.Chat.ID, tgbotapi.ChatTyping))
 
-	// Cek Kredit (Tanpa potong dulu)
-	if !h.deductUserCredit(user, 0) {
+	feature := pricing.FeatureChat
+	if message.Photo != nil && len(message.Photo) > 0 {
+		feature = pricing.FeatureChatVision
+	}
+
+	// Cek Kredit (Tanpa potong dulu), biaya minimum tanpa token jawaban
+	minCost := h.quoteFeature(user, feature, selectedModel, 0)
+	if user.PaidCredits+user.FreeCredits < minCost {
 		log.Printf("DEBUG: Insufficient Credits for user %d", userID)
-		args := map[string]string{"required": "1", "balance": fmt.Sprintf("%d", user.PaidCredits+user.FreeCredits)}
+		args := map[string]string{"required": strconv.Itoa(minCost), "balance": fmt.Sprintf("%d", user.PaidCredits+user.FreeCredits)}
 		failMsg := h.newReplyMessage(message, h.Localizer.Getf(lang, "insufficient_credits", args))
 		h.Bot.Send(failMsg)
 		return
 	}
+	if text := h.spendLimitText(user, wallet.CreditsPool, minCost); text != "" {
+		failMsg := h.newReplyMessage(message, text)
+		failMsg.ParseMode = "HTML"
+		h.Bot.Send(failMsg)
+		return
+	}
 
 	var finalModelID string
 	var prompt string
@@ -192,7 +206,10 @@ func (h *Handler) handleChatMessage(message *tgbotapi.Message) {
 			h.appendChatHistory(userID, "Model: "+resultText)
 		}
 
+		// Biaya akhir dihitung dari panjang jawaban (output_tokens)
+		cost := h.quoteFeature(user, feature, finalModelID, pricing.EstimateTokens(resultText))
 		if h.deductUserCredit(user, cost) {
+			h.DB.Record(user.TelegramID, database.TransactionChat, wallet.CreditsPool, -cost, extractModelName(finalModelID))
 			formattedText := h.formatChatMarkdownToHTML(resultText)
 			//header := fmt.Sprintf("🤖 <i>%s</i>\n\n", extractModelName(finalModelID))
 			costInfo := fmt.Sprintf(h.Localizer.Get(lang, "chat_mode_reply_cost"), cost)
@@ -217,6 +234,10 @@ func (h *Handler) handleChatMessage(message *tgbotapi.Message) {
 			} else {
 				log.Printf("DEBUG: Message Sent ID: %d", sent.MessageID)
 			}
+		} else {
+			// Jawaban panjang bisa lebih mahal dari biaya minimum yang dicek di awal
+			args := map[string]string{"required": strconv.Itoa(cost), "balance": strconv.Itoa(user.PaidCredits + user.FreeCredits)}
+			h.Bot.Send(h.newReplyMessage(message, h.Localizer.Getf(lang, "insufficient_credits", args)))
 		}
 	}()
 }
@@ -244,20 +265,12 @@ func (h *Handler) handleChatModeStop(message *tgbotapi.Message) {
 
 func (h *Handler) deductUserCredit(user *database.User, cost int) bool {
 	if cost == 0 { return true }
-	u, _ := h.DB.GetUserByTelegramID(user.TelegramID)
-	
-	// Pastikan saldo cukup
-	total := u.PaidCredits + u.FreeCredits
-	if total < cost { return false }
-	
-	if u.FreeCredits >= cost {
-		u.FreeCredits -= cost
-	} else {
-		rem := cost - u.FreeCredits
-		u.FreeCredits = 0
-		u.PaidCredits -= rem
+
+	// Saldo dicek & dipotong langsung di database, gagal jika tidak cukup
+	if err := h.Wallet.Debit(h.DB, user, wallet.CreditsPool, cost); err != nil {
+		return false
 	}
-	h.DB.UpdateUser(u)
+	h.afterSpend(user)
 	return true
 }
 
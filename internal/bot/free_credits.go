@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const freeCreditBatchSize = 500
+
+// freeCreditRule mengembalikan jatah free credit (free_credits.json) sesuai tier user.
+func (h *Handler) freeCreditRule(user *database.User) config.FreeCreditTier {
+	return h.FreeCredits.ForTier(h.userTier(user))
+}
+
+// newUserFreeCredits adalah free credit awal user baru (jatah tier free).
+func (h *Handler) newUserFreeCredits() int {
+	return h.FreeCredits.ForTier(h.Tiers.ForUser(false)).Amount
+}
+
+// StartFreeCreditScheduler menjalankan job pengisian free credit secara berkala
+// sesuai periode dan zona waktu di free_credits.json.
+func (h *Handler) StartFreeCreditScheduler() {
+	go func() {
+		h.refillFreeCredits()
+		ticker := time.NewTicker(h.FreeCredits.Interval())
+		defer ticker.Stop()
+		for range ticker.C {
+			h.refillFreeCredits()
+		}
+	}()
+}
+
+func (h *Handler) refillFreeCredits() {
+	now := time.Now()
+	periodStart := h.FreeCredits.PeriodStart(now)
+
+	refilled := 0
+	var afterID int64
+	for {
+		users, err := h.DB.GetUsersDueFreeCredits(periodStart, afterID, freeCreditBatchSize)
+		if err != nil || len(users) == 0 {
+			break
+		}
+		for i := range users {
+			user := &users[i]
+			afterID = user.TelegramID
+
+			rule := h.freeCreditRule(user)
+			credits := rule.Refill(user.FreeCredits)
+			ok, err := h.DB.RefillFreeCredits(user.TelegramID, user.FreeCredits, credits, periodStart, now)
+			if err != nil || !ok {
+				// Saldo berubah di tengah jalan; user ini diambil lagi di putaran berikutnya
+				continue
+			}
+			refilled++
+			h.DB.Record(user.TelegramID, database.TransactionFreeRefill, wallet.FreeCredits, credits-user.FreeCredits, h.FreeCredits.Period)
+
+			if user.NotifyFreeCredits && rule.Amount > 0 {
+				h.notifyFreeCreditRefill(user, credits)
+			}
+		}
+		if len(users) < freeCreditBatchSize {
+			break
+		}
+	}
+
+	if refilled > 0 {
+		log.Printf("INFO: Refilled free credits for %d users (period starting %s)", refilled, periodStart.Format(time.RFC3339))
+	}
+}
+
+func (h *Handler) notifyFreeCreditRefill(user *database.User, credits int) {
+	lang := user.LanguageCode
+	if lang == "" {
+		lang = "en"
+	}
+	args := map[string]string{
+		"credits": strconv.Itoa(credits),
+		"next":    h.formatDuration(time.Until(h.FreeCredits.NextReset(time.Now())), lang),
+	}
+	msg := tgbotapi.NewMessage(user.TelegramID, h.Localizer.Getf(lang, "free_credits_refilled", args))
+	msg.ParseMode = "HTML"
+	h.Bot.Send(msg)
+}
+
+// toggleRefillNotification menyalakan/mematikan notifikasi pengisian free credit
+// lalu memperbarui tombol di pesan profil (label tombol menunjukkan status terbaru).
+func (h *Handler) toggleRefillNotification(callback *tgbotapi.CallbackQuery) {
+	user, err := h.getOrCreateUser(callback.From)
+	if err != nil {
+		return
+	}
+
+	user.NotifyFreeCredits = !user.NotifyFreeCredits
+	if err := h.DB.UpdateUser(user); err != nil {
+		return
+	}
+
+	keyboard := h.createProfileKeyboard(user)
+	h.Bot.Send(tgbotapi.NewEditMessageReplyMarkup(callback.Message.Chat.ID, callback.Message.MessageID, keyboard))
+}
@@ -0,0 +1,303 @@
+package bot
+
+import (
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// giftConfirmTimeout adalah batas waktu tombol konfirmasi /gift.
+const giftConfirmTimeout = 5 * time.Minute
+
+// pendingGift adalah gift yang menunggu konfirmasi pengirim.
+type pendingGift struct {
+	RecipientID   int64
+	RecipientName string
+	Amount        int
+	ChatID        int64
+	MessageID     int
+	ExpiresAt     time.Time
+}
+
+// giftStore menyimpan gift yang belum dikonfirmasi, satu per pengirim.
+type giftStore struct {
+	mu      sync.Mutex
+	pending map[int64]*pendingGift
+}
+
+func newGiftStore() *giftStore {
+	return &giftStore{pending: make(map[int64]*pendingGift)}
+}
+
+func (s *giftStore) put(senderID int64, gift *pendingGift) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[senderID] = gift
+}
+
+// take mengambil (dan menghapus) gift milik pengirim untuk pesan konfirmasi tertentu.
+func (s *giftStore) take(senderID int64, chatID int64, messageID int) *pendingGift {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gift, ok := s.pending[senderID]
+	if !ok || gift.ChatID != chatID || gift.MessageID != messageID {
+		return nil
+	}
+	delete(s.pending, senderID)
+	if time.Now().After(gift.ExpiresAt) {
+		return nil
+	}
+	return gift
+}
+
+// GiftError membawa kunci locale untuk penolakan gift.
+type GiftError struct {
+	Key  string
+	Args map[string]string
+}
+
+func (e *GiftError) Error() string {
+	return e.Key
+}
+
+// handleGift menangani /gift @username AMOUNT, atau /gift AMOUNT sebagai balasan ke pesan user lain.
+func (h *Handler) handleGift(message *tgbotapi.Message) {
+	sender, err := h.getOrCreateUser(message.From)
+	if err != nil {
+		return
+	}
+	lang := sender.LanguageCode
+
+	args := strings.Fields(message.CommandArguments())
+	var recipient *database.User
+	var amountArg string
+	switch {
+	case len(args) == 1 && message.ReplyToMessage != nil && message.ReplyToMessage.From != nil && !message.ReplyToMessage.From.IsBot:
+		amountArg = args[0]
+		recipient, err = h.DB.GetUserByTelegramID(message.ReplyToMessage.From.ID)
+	case len(args) == 2 && strings.HasPrefix(args[0], "@") && len(args[0]) > 1:
+		amountArg = args[1]
+		recipient, err = h.DB.GetUserByUsername(strings.TrimPrefix(args[0], "@"))
+	default:
+		h.sendGiftText(message, h.Localizer.Get(lang, "gift_usage"))
+		return
+	}
+	if err != nil {
+		h.sendGiftText(message, h.Localizer.Get(lang, "gift_error_generic"))
+		return
+	}
+
+	amount, err := strconv.Atoi(amountArg)
+	if err == nil {
+		err = h.validateGift(sender, recipient, amount)
+	} else {
+		err = h.giftAmountError()
+	}
+	if err != nil {
+		h.sendGiftText(message, h.giftErrorText(lang, err))
+		return
+	}
+
+	name := giftDisplayName(recipient)
+	text := h.Localizer.Getf(lang, "gift_confirm", map[string]string{
+		"amount":    strconv.Itoa(amount),
+		"recipient": html.EscapeString(name),
+	})
+	msg := h.newReplyMessage(message, text)
+	msg.ParseMode = "HTML"
+	keyboard := h.createGiftConfirmKeyboard(lang)
+	msg.ReplyMarkup = &keyboard
+	sent, err := h.Bot.Send(msg)
+	if err != nil {
+		return
+	}
+
+	h.pendingGifts.put(sender.TelegramID, &pendingGift{
+		RecipientID:   recipient.TelegramID,
+		RecipientName: name,
+		Amount:        amount,
+		ChatID:        sent.Chat.ID,
+		MessageID:     sent.MessageID,
+		ExpiresAt:     time.Now().Add(giftConfirmTimeout),
+	})
+}
+
+func (h *Handler) giftAmountError() error {
+	return &GiftError{Key: "gift_invalid_amount", Args: map[string]string{
+		"min": strconv.Itoa(h.Config.GiftMinAmount),
+		"max": strconv.Itoa(h.Config.GiftMaxAmount),
+	}}
+}
+
+// validateGift menjalankan semua pemeriksaan sebelum gift boleh dikirim.
+// Dipanggil saat /gift dan sekali lagi saat dikonfirmasi.
+func (h *Handler) validateGift(sender, recipient *database.User, amount int) error {
+	if amount < h.Config.GiftMinAmount || amount > h.Config.GiftMaxAmount {
+		return h.giftAmountError()
+	}
+	if recipient == nil || recipient.IsBanned {
+		return &GiftError{Key: "gift_recipient_not_found"}
+	}
+	if recipient.TelegramID == sender.TelegramID {
+		return &GiftError{Key: "gift_self"}
+	}
+	if sender.IsBanned {
+		return &GiftError{Key: "gift_not_allowed"}
+	}
+
+	// Hanya kredit dari pembelian yang boleh dibagi, agar kredit hasil farming
+	// akun referral tidak bisa dikumpulkan ke satu akun
+	purchased, err := h.DB.HasCompletedPurchase(sender.TelegramID)
+	if err != nil {
+		return err
+	}
+	if !purchased {
+		return &GiftError{Key: "gift_purchase_required"}
+	}
+
+	sentToday, err := h.DB.SumTransactions(sender.TelegramID, database.TransactionGiftSent, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	remaining := h.Config.GiftDailyLimit + sentToday // gift_sent tercatat negatif
+	if amount > remaining {
+		if remaining < 0 {
+			remaining = 0
+		}
+		return &GiftError{Key: "gift_daily_limit", Args: map[string]string{
+			"limit":     strconv.Itoa(h.Config.GiftDailyLimit),
+			"remaining": strconv.Itoa(remaining),
+		}}
+	}
+
+	if sender.PaidCredits < amount {
+		return &GiftError{Key: "gift_insufficient", Args: map[string]string{"balance": strconv.Itoa(sender.PaidCredits)}}
+	}
+	return nil
+}
+
+// handleGiftCallback menangani tombol gift_confirm / gift_cancel.
+func (h *Handler) handleGiftCallback(callback *tgbotapi.CallbackQuery, confirmed bool) {
+	sender, err := h.getOrCreateUser(callback.From)
+	if err != nil {
+		return
+	}
+	lang := sender.LanguageCode
+	chatID, messageID := callback.Message.Chat.ID, callback.Message.MessageID
+
+	gift := h.pendingGifts.take(sender.TelegramID, chatID, messageID)
+	if gift == nil {
+		// Di grup pesan konfirmasi membalas perintah pengirim; klik dari user lain diabaikan
+		original := callback.Message.ReplyToMessage
+		if original == nil || original.From == nil || original.From.ID == sender.TelegramID {
+			h.editGiftText(chatID, messageID, h.Localizer.Get(lang, "gift_expired"))
+		}
+		return
+	}
+	if !confirmed {
+		h.editGiftText(chatID, messageID, h.Localizer.Get(lang, "gift_cancelled"))
+		return
+	}
+
+	var balance int
+	recipient, err := h.DB.GetUserByTelegramID(gift.RecipientID)
+	if err == nil {
+		err = h.validateGift(sender, recipient, gift.Amount)
+	}
+	if err == nil {
+		balance, err = h.transferGift(sender, recipient, gift.Amount)
+	}
+	if err != nil {
+		h.editGiftText(chatID, messageID, h.giftErrorText(lang, err))
+		return
+	}
+
+	h.editGiftText(chatID, messageID, h.Localizer.Getf(lang, "gift_success", map[string]string{
+		"amount":    strconv.Itoa(gift.Amount),
+		"recipient": html.EscapeString(gift.RecipientName),
+		"balance":   strconv.Itoa(balance),
+	}))
+
+	recipientLang := recipient.LanguageCode
+	if recipientLang == "" {
+		recipientLang = "en"
+	}
+	notice := tgbotapi.NewMessage(recipient.TelegramID, h.Localizer.Getf(recipientLang, "gift_received", map[string]string{
+		"amount": strconv.Itoa(gift.Amount),
+		"sender": html.EscapeString(giftDisplayName(sender)),
+	}))
+	notice.ParseMode = "HTML"
+	h.Bot.Send(notice)
+}
+
+// transferGift memindahkan paid credits pengirim ke penerima lalu mencatat keduanya di riwayat saldo.
+// Mengembalikan saldo paid credits pengirim setelah transfer.
+func (h *Handler) transferGift(sender, recipient *database.User, amount int) (int, error) {
+	balance, err := h.DB.AdjustBalance(sender.TelegramID, wallet.PaidCredits, -amount)
+	if err != nil {
+		if err == database.ErrInsufficientBalance {
+			return 0, &GiftError{Key: "gift_insufficient", Args: map[string]string{"balance": strconv.Itoa(balance)}}
+		}
+		return 0, err
+	}
+	if _, err := h.DB.AdjustBalance(recipient.TelegramID, wallet.PaidCredits, amount); err != nil {
+		// Kembalikan kredit pengirim agar tidak hilang
+		if _, refundErr := h.DB.AdjustBalance(sender.TelegramID, wallet.PaidCredits, amount); refundErr != nil {
+			log.Printf("ERROR: Gift of %d credits from %d to %d failed and could not be returned: %v", amount, sender.TelegramID, recipient.TelegramID, refundErr)
+		}
+		return 0, err
+	}
+
+	h.DB.RecordTransaction(&database.CreditTransaction{
+		TelegramID:     sender.TelegramID,
+		Kind:           database.TransactionGiftSent,
+		Currency:       wallet.PaidCredits,
+		Amount:         -amount,
+		Description:    "Gift to " + giftDisplayName(recipient),
+		CounterpartyID: recipient.TelegramID,
+	})
+	h.DB.RecordTransaction(&database.CreditTransaction{
+		TelegramID:     recipient.TelegramID,
+		Kind:           database.TransactionGiftReceived,
+		Currency:       wallet.PaidCredits,
+		Amount:         amount,
+		Description:    "Gift from " + giftDisplayName(sender),
+		CounterpartyID: sender.TelegramID,
+	})
+	log.Printf("INFO: User %d gifted %d paid credits to user %d", sender.TelegramID, amount, recipient.TelegramID)
+	return balance, nil
+}
+
+func giftDisplayName(user *database.User) string {
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return strconv.FormatInt(user.TelegramID, 10)
+}
+
+func (h *Handler) giftErrorText(lang string, err error) string {
+	if giftErr, ok := err.(*GiftError); ok {
+		return h.Localizer.Getf(lang, giftErr.Key, giftErr.Args)
+	}
+	return h.Localizer.Get(lang, "gift_error_generic")
+}
+
+func (h *Handler) sendGiftText(message *tgbotapi.Message, text string) {
+	msg := h.newReplyMessage(message, text)
+	msg.ParseMode = "HTML"
+	h.Bot.Send(msg)
+}
+
+func (h *Handler) editGiftText(chatID int64, messageID int, text string) {
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "HTML"
+	h.Bot.Send(edit)
+}
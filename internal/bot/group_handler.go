@@ -36,8 +36,11 @@ func (gh *GroupHandler) HandleGroupMessage(message *tgbotapi.Message) {
 		isMention = true
 	}
 
+	// /gift sebagai balasan ke pesan user lain juga ditujukan untuk bot
+	isGift := message.IsCommand() && message.Command() == "gift" && message.ReplyToMessage != nil
+
 	// Jika bukan untuk bot, abaikan
-	if !isReply && !isMention {
+	if !isReply && !isMention && !isGift {
 		return
 	}
 
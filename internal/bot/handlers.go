@@ -9,7 +9,6 @@ import (
 	"io/ioutil" // <-- TAMBAHKAN
 	"log"
 	"net/http" // <-- TAMBAHKAN
-	"net/url"
 	"path/filepath" // <-- TAMBAHKAN
 	"strconv"
 	"strings"
@@ -18,7 +17,11 @@ import (
 	"telegram-ai-bot/internal/database"
 	"telegram-ai-bot/internal/localization"
 	"telegram-ai-bot/internal/payments"
+	"telegram-ai-bot/internal/pricing"
+	"telegram-ai-bot/internal/referral"
+	"telegram-ai-bot/internal/safety"
 	"telegram-ai-bot/internal/services"
+	"telegram-ai-bot/internal/wallet"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -50,9 +53,22 @@ type Handler struct {
 	PaymentHandler         *payments.PaymentHandler
 	GroupHandler           *GroupHandler
 	pendingGenerations     map[int64]*PendingGeneration
+	Tiers                  *config.TierConfig
+	generationQueue        *generationQueue
+	pendingGifts           *giftStore
+	pendingSpends          *spendConfirmStore
+	captchas               *captchaStore
+	moderation             *moderationCache
+	roles                  *roleStore
+	accounts               *accountStore
+	Pricing                pricing.Features // biaya chat & prompt assistant (pricing.json)
+	Wallet                 *wallet.Config   // mata uang & kurs tukar (wallet.json)
+	FreeCredits            *config.FreeCreditPolicy // jadwal & jatah free credit (free_credits.json)
+	Referrals              *referral.Program        // hadiah & dashboard referral (referral.json)
+	Safety                 *safety.Checker          // pemeriksaan prompt sebelum generate (safety.json)
 }
 
-func NewHandler(api *tgbotapi.BotAPI, db *database.Client, localizer *localization.Localizer, providers []config.Provider, models []config.Model, templates []config.PromptTemplate, styles []config.StyleTemplate, replicate *services.ReplicateClient, cfg *config.Config, paymentHandler *payments.PaymentHandler) *Handler {
+func NewHandler(api *tgbotapi.BotAPI, db *database.Client, localizer *localization.Localizer, providers []config.Provider, models []config.Model, templates []config.PromptTemplate, styles []config.StyleTemplate, replicate *services.ReplicateClient, cfg *config.Config, paymentHandler *payments.PaymentHandler, tiers *config.TierConfig, features pricing.Features, walletConfig *wallet.Config, freeCredits *config.FreeCreditPolicy, referrals *referral.Program, promptSafety *safety.Checker) *Handler {
 	h := &Handler{
 		Bot:                api,
 		DB:                 db,
@@ -67,6 +83,19 @@ func NewHandler(api *tgbotapi.BotAPI, db *database.Client, localizer *localizati
 		lastGeneratedURLs:  make(map[int64][]string),
 		PaymentHandler:     paymentHandler,
 		pendingGenerations: make(map[int64]*PendingGeneration),
+		Tiers:              tiers,
+		generationQueue:    newGenerationQueue(cfg.MaxConcurrentGenerations),
+		pendingGifts:       newGiftStore(),
+		pendingSpends:      newSpendConfirmStore(),
+		captchas:           newCaptchaStore(),
+		moderation:         newModerationCache(),
+		roles:              newRoleStore(),
+		accounts:           newAccountStore(),
+		Pricing:            features,
+		Wallet:             walletConfig,
+		FreeCredits:        freeCredits,
+		Referrals:          referrals,
+		Safety:             promptSafety,
 	}
 	h.GroupHandler = NewGroupHandler(h)
 	return h
@@ -104,13 +133,9 @@ func (h *Handler) newReplyMediaGroup(originalMessage *tgbotapi.Message, media []
 	return msg
 }
 
+// isAdmin memeriksa apakah user punya peran staf apa pun (lihat roles.go untuk izin per perintah).
 func (h *Handler) isAdmin(userID int64) bool {
-	for _, adminID := range h.Config.AdminTelegramIDs {
-		if userID == adminID {
-			return true
-		}
-	}
-	return false
+	return h.roleOf(userID) != ""
 }
 
 func (h *Handler) isUserSubscribed(userID int64) (bool, error) {
@@ -144,6 +169,10 @@ func (h *Handler) isUserSubscribed(userID int64) (bool, error) {
 
 func (h *Handler) HandleUpdate(update tgbotapi.Update) {
 	log.Println("DEBUG: HandleUpdate function started")
+	// User yang dibanned/disuspend berhenti di sini sebelum handler mana pun
+	if h.rejectBlockedUser(update) {
+		return
+	}
 	switch {
 	case update.PreCheckoutQuery != nil:
 		log.Println("DEBUG: Routing update to HandlePreCheckoutQuery")
@@ -180,8 +209,7 @@ func (h *Handler) handleCommand(message *tgbotapi.Message) {
 	log.Printf("DIAGNOSTIC: handleCommand triggered. Raw Text: [%s]", message.Text)
 	command := message.Command()
 	log.Printf("DIAGNOSTIC: Command parsed by library: [%s]", command)
-	isAdminCommand := command == "stats" || command == "addcredits" || command == "broadcast" || command == "broadcastgroup"
-	if isAdminCommand && !h.isAdmin(message.From.ID) {
+	if perm, isAdminCommand := adminCommands[command]; isAdminCommand && !h.can(message.From.ID, perm) {
 		msg := h.newReplyMessage(message, h.Localizer.Get("en", "permission_denied"))
 		h.Bot.Send(msg)
 		return
@@ -248,6 +276,28 @@ func (h *Handler) handleCommand(message *tgbotapi.Message) {
 		h.handleStats(message)
 	case "addcredits":
 		h.handleAddCredits(message)
+	case "refund":
+		h.handleRefund(message)
+	case "promo":
+		h.handlePromoAdmin(message)
+	case "referrals":
+		h.handleReferralAdmin(message)
+	case "ban":
+		h.handleBan(message)
+	case "unban":
+		h.handleUnban(message)
+	case "suspend":
+		h.handleSuspend(message)
+	case "user":
+		h.handleUserLookup(message)
+	case "audit":
+		h.handleAudit(message)
+	case "grant":
+		h.handleGrant(message)
+	case "revoke":
+		h.handleRevoke(message)
+	case "flags":
+		h.handleFlags(message)
 	case "broadcastgroup":
 		h.handleBroadcastGroup(message)
 	case "broadcast":
@@ -256,6 +306,22 @@ func (h *Handler) handleCommand(message *tgbotapi.Message) {
 	///h.handleSettings(message)
 	case "topup":
 		h.PaymentHandler.ShowTopUpOptions(message.Chat.ID)
+	case "subscription", "premium":
+		h.PaymentHandler.ShowSubscriptionStatus(message.Chat.ID, message.From.ID)
+	case "purchases":
+		h.PaymentHandler.ShowPurchaseHistory(message.Chat.ID, message.From.ID)
+	case "redeem":
+		h.handleRedeem(message)
+	case "gift":
+		h.handleGift(message)
+	case "history":
+		h.handleHistory(message)
+	case "limits":
+		h.handleLimits(message)
+	case "mydata":
+		h.handleMyData(message)
+	case "deleteme":
+		h.handleDeleteMe(message)
 	case "removebg":
 		h.handleRemoveBg(message)
 	case "upscaler":
@@ -350,8 +416,10 @@ func (h *Handler) handleAddCredits(message *tgbotapi.Message) {
 		return
 	}
 
-	targetUser.PaidCredits += amount
-	h.DB.UpdateUser(targetUser)
+	if h.Wallet.Credit(h.DB, targetUser, wallet.PaidCredits, amount) == nil {
+		h.DB.Record(targetID, database.TransactionAdminCredit, wallet.PaidCredits, amount, fmt.Sprintf("Added by admin %d", message.From.ID))
+		h.DB.RecordAudit(message.From.ID, database.AuditAddCredits, targetID, map[string]interface{}{"amount": amount, "currency": wallet.PaidCredits})
+	}
 
 	args := map[string]string{
 		"amount":  strconv.Itoa(amount),
@@ -361,6 +429,43 @@ func (h *Handler) handleAddCredits(message *tgbotapi.Message) {
 	h.Bot.Send(msg)
 }
 
+func (h *Handler) handleRefund(message *tgbotapi.Message) {
+	lang := "en"
+	parts := strings.Fields(message.CommandArguments())
+	if len(parts) == 0 || len(parts) > 2 || (len(parts) == 2 && parts[1] != "force") {
+		msg := h.newReplyMessage(message, h.Localizer.Get(lang, "refund_usage"))
+		h.Bot.Send(msg)
+		return
+	}
+	force := len(parts) == 2
+
+	payment, err := h.PaymentHandler.RefundPayment(parts[0], message.From.ID, force)
+	if err != nil {
+		text := h.Localizer.Get(lang, "refund_error_generic")
+		if refundErr, ok := err.(*payments.RefundError); ok {
+			text = h.Localizer.Getf(lang, refundErr.Key, refundErr.Args)
+		}
+		msg := h.newReplyMessage(message, text)
+		h.Bot.Send(msg)
+		return
+	}
+	h.DB.RecordAudit(message.From.ID, database.AuditRefund, payment.TelegramID, map[string]interface{}{
+		"charge_id": payment.ChargeID,
+		"amount":    payment.Amount,
+		"credits":   payment.CreditsReversed,
+		"force":     force,
+	})
+
+	args := map[string]string{
+		"charge_id": payment.ChargeID,
+		"amount":    strconv.Itoa(payment.Amount),
+		"user_id":   strconv.FormatInt(payment.TelegramID, 10),
+		"credits":   strconv.Itoa(payment.CreditsReversed),
+	}
+	msg := h.newReplyMessage(message, h.Localizer.Getf(lang, "refund_success", args))
+	h.Bot.Send(msg)
+}
+
 func (h *Handler) handleBroadcast(message *tgbotapi.Message) {
 	lang := "en"
 	// --- PERUBAHAN DI SINI (1/4): Variabel untuk menyimpan ID foto ---
@@ -388,6 +493,8 @@ func (h *Handler) handleBroadcast(message *tgbotapi.Message) {
 		log.Printf("ERROR: Failed to get all users for broadcast: %v", err)
 		return
 	}
+	h.DB.RecordAudit(message.From.ID, database.AuditBroadcast, 0, map[string]interface{}{"text": broadcastText, "photo": photoFileID, "recipients": len(allUsers)})
+	progress := h.startBroadcastJob(database.BroadcastUsers, message.From.ID, broadcastText, photoFileID, len(allUsers))
 
 	args := map[string]string{"user_count": strconv.Itoa(len(allUsers))}
 	startMsg := h.newReplyMessage(message, h.Localizer.Getf(lang, "broadcast_started", args))
@@ -412,8 +519,10 @@ func (h *Handler) handleBroadcast(message *tgbotapi.Message) {
 			if err == nil {
 				sentCount++
 			}
+			progress.add(err == nil)
 			time.Sleep(100 * time.Millisecond)
 		}
+		progress.finish()
 
 		finishArgs := map[string]string{
 			"sent_count":  strconv.Itoa(sentCount),
@@ -726,6 +835,14 @@ case "dash_img_clear":
 	case "model_select":
 		h.handleModelSelection(callback, data)
 
+	case "model_locked":
+		for _, m := range h.Models {
+			if m.ID == data {
+				h.showPremiumUpsell(callback.Message.Chat.ID, user, &m)
+				break
+			}
+		}
+
 	case "adv_setting_open":
 		h.handleOpenAdvancedSettings(callback, data)
 
@@ -844,6 +961,28 @@ case "dash_img_clear":
 
 	case "topup_stars":
 		h.PaymentHandler.ShowStarsPackages(callback.Message.Chat.ID, callback.Message.MessageID)
+	case "manual_pkg":
+		if h.PaymentHandler.PromptManualProof(callback.Message.Chat.ID, callback.From.ID, callback.Message.MessageID, data) {
+			h.userStatesMutex.Lock()
+			h.userStates[callback.From.ID] = "awaiting_manual_proof:" + data
+			h.userStatesMutex.Unlock()
+		}
+	case "manual_approve", "manual_reject":
+		if !h.can(callback.From.ID, permCredits) {
+			return
+		}
+		requestID, err := strconv.ParseInt(data, 10, 64)
+		if err != nil {
+			log.Printf("ERROR: Invalid manual payment ID in callback: %s", data)
+			return
+		}
+		h.PaymentHandler.ReviewManualPayment(callback, requestID, action == "manual_approve")
+	case "sub_buy":
+		h.PaymentHandler.SendSubscriptionInvoice(callback.Message.Chat.ID, callback.From.ID)
+	case "sub_cancel":
+		h.PaymentHandler.SetSubscriptionCanceled(callback.Message.Chat.ID, callback.From.ID, callback.Message.MessageID, true)
+	case "sub_resume":
+		h.PaymentHandler.SetSubscriptionCanceled(callback.Message.Chat.ID, callback.From.ID, callback.Message.MessageID, false)
 	case "topup_manual":
 		h.PaymentHandler.ShowManualPaymentOptions(callback.Message.Chat.ID, callback.Message.MessageID)
 	case "topup_transfer_bank":
@@ -852,9 +991,53 @@ case "dash_img_clear":
 		h.PaymentHandler.ShowTopUpOptions(callback.Message.Chat.ID, callback.Message.MessageID)
 	case "topup_back_to_manual":
 		h.PaymentHandler.ShowManualPaymentOptions(callback.Message.Chat.ID, callback.Message.MessageID)
-	//case "topup_bmac":
-	//	h.PaymentHandler.ShowBMACPackages(callback.Message.Chat.ID, callback.Message.MessageID)
+	case "topup_bmac":
+		if h.PaymentHandler.BMACEnabled() {
+			h.PaymentHandler.ShowBMACPackages(callback.Message.Chat.ID, callback.Message.MessageID)
+		}
+
+	case "history_open":
+		h.handleHistoryPage(callback, "0", false)
+
+	case "history":
+		h.handleHistoryPage(callback, data, true)
+
+	case "history_csv":
+		h.sendHistoryCSV(callback)
+
+	case "audit":
+		h.handleAuditPage(callback, data)
+
+	case "audit_csv":
+		h.handleAuditCSV(callback, data)
+
+	case "referral_leaderboard":
+		h.handleReferralLeaderboard(callback)
+
+	case "captcha":
+		h.handleCaptchaAnswer(callback, data)
+
+	case "spend_confirm", "spend_cancel":
+		h.handleSpendCallback(callback, action == "spend_confirm")
+
+	case "gift_confirm", "gift_cancel":
+		h.handleGiftCallback(callback, action == "gift_confirm")
+
+	case "deleteme_confirm", "deleteme_cancel":
+		h.handleDeleteMeCallback(callback, action == "deleteme_confirm")
+
+	case "toggle_refill_notify":
+		h.toggleRefillNotification(callback)
 
+	case "exchange_pick":
+		if ex := h.Wallet.FindExchange(data); ex != nil {
+			user, err := h.getOrCreateUser(callback.From)
+			if err != nil {
+				return
+			}
+			h.Bot.Request(tgbotapi.NewDeleteMessage(callback.Message.Chat.ID, callback.Message.MessageID))
+			h.startExchange(callback.Message.Chat.ID, user, ex)
+		}
 	case "faq_show":
 		h.handleFaqShow(callback, data)
 	case "faq_back":
@@ -1037,10 +1220,11 @@ func (h *Handler) handleRemoveBg(message *tgbotapi.Message) {
 		return
 	}
 
+	cost := h.modelCost(user, removeBgModel)
 	totalAvailableCredits := user.PaidCredits + user.FreeCredits
-	if totalAvailableCredits < removeBgModel.Cost {
+	if totalAvailableCredits < cost {
 		args := map[string]string{
-			"required": strconv.Itoa(removeBgModel.Cost),
+			"required": strconv.Itoa(cost),
 			"balance":  strconv.Itoa(totalAvailableCredits),
 		}
 		text := h.Localizer.Getf(lang, "insufficient_credits", args)
@@ -1054,7 +1238,7 @@ func (h *Handler) handleRemoveBg(message *tgbotapi.Message) {
 	h.userStatesMutex.Unlock()
 
 	args := map[string]string{
-		"cost": strconv.Itoa(removeBgModel.Cost),
+		"cost": strconv.Itoa(cost),
 	}
 	text := h.Localizer.Getf(lang, "removebg_prompt", args)
 
@@ -1066,7 +1250,6 @@ func (h *Handler) handleRemoveBg(message *tgbotapi.Message) {
 
 func (h *Handler) handleProviderSelection(callback *tgbotapi.CallbackQuery, providerID string) {
 	user, _ := h.getOrCreateUser(callback.From)
-	lang := user.LanguageCode
 
 	var selectedProvider *config.Provider
 	for _, p := range h.Providers {
@@ -1108,7 +1291,7 @@ func (h *Handler) handleProviderSelection(callback *tgbotapi.CallbackQuery, prov
 
 	text := fmt.Sprintf("<b>%s</b>\n\n%s\n\nSilakan pilih model:", selectedProvider.Name, selectedProvider.Description)
 
-	keyboard := h.createModelSelectionKeyboard(providerModels, lang, providerID, 0)
+	keyboard := h.createModelSelectionKeyboard(providerModels, user, providerID, 0)
 
 	msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
 	msg.ParseMode = "HTML"
@@ -1264,13 +1447,19 @@ func (h *Handler) handleModelSelection(callback *tgbotapi.CallbackQuery, modelID
 		return
 	}
 
+	if !h.canUseModel(user, selectedModel) {
+		h.showPremiumUpsell(callback.Message.Chat.ID, user, selectedModel)
+		return
+	}
+
 	// Cek saldo
 	totalAvailableCredits := user.PaidCredits + user.FreeCredits
-	cost := selectedModel.Cost
+	cost := h.quoteGeneration(user, selectedModel, h.generationParams(selectedModel, userCustomSettings(user)), generationNumOutputs(user, selectedModel), 0)
 	if selectedModel.Type == "video" {
-		if user.Diamonds < selectedModel.DiamondCost {
+		diamondCost := cost
+		if user.Diamonds < diamondCost {
 			// Kirim pesan saldo kurang (kode sama seperti sebelumnya, disingkat)
-			args := map[string]string{"required": strconv.Itoa(selectedModel.DiamondCost), "balance": strconv.Itoa(user.Diamonds)}
+			args := map[string]string{"required": strconv.Itoa(diamondCost), "balance": strconv.Itoa(user.Diamonds)}
 			text := h.Localizer.Getf(lang, "insufficient_diamonds", args)
 			h.Bot.Send(tgbotapi.NewMessage(callback.Message.Chat.ID, text))
 			return
@@ -1352,15 +1541,14 @@ func (h *Handler) showGenerationDashboard(chatID int64, user *database.User, mod
 					val = "-"
 				}
 			}
-			displayVal := fmt.Sprintf("%v", val)
-			if v, ok := val.(float64); ok && v == float64(int(v)) {
-				displayVal = fmt.Sprintf("%d", int(v))
-			}
+			displayVal := param.DisplayValue(val)
 			
 			textBuilder.WriteString(fmt.Sprintf("• %s: <code>%s</code>\n", param.Label, displayVal))
 		}
 	}
 
+	textBuilder.WriteString(h.dashboardCostLine(user, model, customSettings, imageCount))
+
 	textBuilder.WriteString("\n✏️ <b>Ready! Please type your prompt now to generate.</b>")
 
 	msg := tgbotapi.NewMessage(chatID, textBuilder.String())
@@ -1413,14 +1601,13 @@ func (h *Handler) updateGenerationDashboard(chatID int64, messageID int, user *d
 					val = "-"
 				}
 			}
-			displayVal := fmt.Sprintf("%v", val)
-			if v, ok := val.(float64); ok && v == float64(int(v)) {
-				displayVal = fmt.Sprintf("%d", int(v))
-			}
+			displayVal := param.DisplayValue(val)
 			textBuilder.WriteString(fmt.Sprintf("• %s: <code>%s</code>\n", param.Label, displayVal))
 		}
 	}
 
+	textBuilder.WriteString(h.dashboardCostLine(user, model, customSettings, imageCount))
+
 	textBuilder.WriteString("\n✏️ <b>Ready! Please type your prompt now to generate.</b>")
 
 	keyboard := h.createGenerationDashboardKeyboard(lang, *model, user, imageCount)
@@ -1431,7 +1618,8 @@ func (h *Handler) updateGenerationDashboard(chatID int64, messageID int, user *d
 	h.Bot.Send(editMsg)
 }
 
-func (h *Handler) triggerVideoGeneration(user *database.User, originalMessage *tgbotapi.Message, modelID, prompt, imageURL string) {
+// confirmed true berarti user sudah menekan tombol konfirmasi belanja (lihat confirmSpend).
+func (h *Handler) triggerVideoGeneration(user *database.User, originalMessage *tgbotapi.Message, modelID, prompt, imageURL string, confirmed bool) {
 	h.userStatesMutex.Lock()
 	delete(h.userStates, user.TelegramID)
 	h.userStatesMutex.Unlock()
@@ -1450,41 +1638,79 @@ func (h *Handler) triggerVideoGeneration(user *database.User, originalMessage *t
 		return
 	}
 
-	if user.Diamonds < selectedModel.DiamondCost {
+	if !h.canUseModel(user, selectedModel) {
+		h.showPremiumUpsell(originalMessage.Chat.ID, user, selectedModel)
+		return
+	}
+	// Prompt sudah diperiksa sebelum konfirmasi belanja
+	if !confirmed && !h.screenPrompt(user, originalMessage, selectedModel, prompt) {
+		return
+	}
+
+	inputImages := 0
+	if imageURL != "" {
+		inputImages = 1
+	}
+	// Parameter yang sama dipakai untuk harga dan untuk Replicate
+	cleanParams := h.generationParams(selectedModel, userCustomSettings(user))
+	diamondCost := h.quoteGeneration(user, selectedModel, cleanParams, 1, inputImages)
+	if user.Diamonds < diamondCost {
+		args := map[string]string{"required": strconv.Itoa(diamondCost), "balance": strconv.Itoa(user.Diamonds)}
+		h.Bot.Send(h.newReplyMessage(originalMessage, h.Localizer.Getf(lang, "insufficient_diamonds", args)))
+		return
+	}
+
+	// --- BATAS BELANJA & KONFIRMASI (/limits) ---
+	if text := h.spendLimitText(user, wallet.Diamonds, diamondCost); text != "" {
+		msg := h.newReplyMessage(originalMessage, text)
+		msg.ParseMode = "HTML"
+		h.Bot.Send(msg)
 		return
 	}
+	if !confirmed {
+		run := func(u *database.User) {
+			h.triggerVideoGeneration(u, originalMessage, modelID, prompt, imageURL, true)
+		}
+		if !h.confirmSpend(user, originalMessage, wallet.Diamonds, diamondCost, run) {
+			return
+		}
+	}
 
 	waitMsg := h.newReplyMessage(originalMessage, h.Localizer.Get(lang, "video_generating"))
 	sentMsg, _ := h.Bot.Send(waitMsg)
 	defer h.Bot.Send(tgbotapi.NewDeleteMessage(originalMessage.Chat.ID, sentMsg.MessageID))
 
+	release := h.acquireGenerationSlot(user, originalMessage.Chat.ID, sentMsg.MessageID, h.Localizer.Get(lang, "video_generating"))
+	defer release()
+
 	action := tgbotapi.NewChatAction(originalMessage.Chat.ID, tgbotapi.ChatUploadVideo)
 	h.Bot.Send(action)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	var customParams map[string]interface{}
-	if user.CustomSettings != "" {
-		json.Unmarshal([]byte(user.CustomSettings), &customParams)
-	}
-
-	videoUrls, err := h.Replicate.CreatePrediction(ctx, selectedModel.ReplicateID, prompt, imageURL, selectedModel.ImageParameterName, "", 1, customParams)
+	started := time.Now()
+	videoUrls, err := h.Replicate.CreatePrediction(ctx, selectedModel.ReplicateID, prompt, imageURL, selectedModel.ImageParameterName, "", 1, cleanParams)
+	h.recordGeneration(user, selectedModel, database.GenerationVideo, prompt, videoUrls, err, diamondCost, started)
 
 	if err != nil || len(videoUrls) == 0 {
+		if h.handleProviderRejection(user, originalMessage, selectedModel, prompt, err) {
+			return
+		}
 		failMsg := h.newReplyMessage(originalMessage, h.Localizer.Get(lang, "video_generation_failed"))
 		h.Bot.Send(failMsg)
 		return
 	}
 
-	user.Diamonds -= selectedModel.DiamondCost
-	h.DB.UpdateUser(user)
+	if charged, err := h.Wallet.Charge(h.DB, user, wallet.Diamonds, diamondCost); err == nil {
+		h.DB.Record(user.TelegramID, database.TransactionVideo, wallet.Diamonds, -charged, selectedModel.Name)
+	}
 
 	safePrompt := html.EscapeString(prompt)
 	if len(safePrompt) > 900 {
 		safePrompt = safePrompt[:900] + "..."
 	}
-	caption := fmt.Sprintf("<b>Prompt:</b> <pre>%s</pre>\n<b>Model:</b> <code>%s</code>\n<b>Cost:</b> %d 💎", safePrompt, selectedModel.Name, selectedModel.DiamondCost)
+	caption := fmt.Sprintf("<b>Prompt:</b> <pre>%s</pre>\n<b>Model:</b> <code>%s</code>\n<b>Cost:</b> %d 💎", safePrompt, selectedModel.Name, diamondCost)
 
 	resp, httpErr := http.Get(videoUrls[0])
 	if httpErr != nil {
@@ -1575,6 +1801,16 @@ func (h *Handler) handleMessage(message *tgbotapi.Message) {
 		return
 	}
 
+	if strings.HasPrefix(state, "awaiting_manual_proof:") {
+		packageID := strings.TrimPrefix(state, "awaiting_manual_proof:")
+		if h.PaymentHandler.SubmitManualProof(message, packageID) {
+			h.userStatesMutex.Lock()
+			delete(h.userStates, user.TelegramID)
+			h.userStatesMutex.Unlock()
+		}
+		return
+	}
+
 	// 2. LOGIKA MODE UPLOAD GAMBAR (DARI DASHBOARD)
 	if strings.HasPrefix(state, "awaiting_dashboard_image:") {
 		// Jika ada foto dikirim
@@ -1634,25 +1870,9 @@ func (h *Handler) handleMessage(message *tgbotapi.Message) {
 		}
 		modelID, paramName := parts[0], parts[1]
 
-		// Cari model & tipe parameter
-		var selectedModel *config.Model
-		var targetParamType string
-		found := false
-		for _, m := range h.Models {
-			if m.ID == modelID {
-				selectedModel = &m
-				for _, p := range m.Parameters {
-					if p.Name == paramName {
-						targetParamType = p.Type
-						found = true
-						break
-					}
-				}
-				break
-			}
-		}
-
-		if !found || selectedModel == nil {
+		// Cari model & skema parameter
+		selectedModel, selectedParam := h.findModelParameter(modelID, paramName)
+		if selectedModel == nil || selectedParam == nil {
 			return
 		}
 
@@ -1664,25 +1884,11 @@ func (h *Handler) handleMessage(message *tgbotapi.Message) {
 			customSettings = make(map[string]interface{})
 		}
 
-		inputValue := message.Text
-		var parsedValue interface{}
-		var errParse error
-
-		// Validasi & Konversi Input
-		switch targetParamType {
-		case "integer":
-			val, err := strconv.ParseInt(inputValue, 10, 64)
-			parsedValue = int(val)
-			errParse = err
-		case "number":
-			parsedValue, errParse = strconv.ParseFloat(inputValue, 64)
-		default: // string
-			parsedValue = inputValue
-		}
-
+		// Validasi & Konversi Input sesuai skema (tipe, min/max, options, panjang)
+		parsedValue, errParse := selectedParam.Parse(message.Text)
 		if errParse != nil {
-			errorText := fmt.Sprintf("❌ Invalid input. Please enter a valid %s value.", targetParamType)
-			msg := h.newReplyMessage(message, errorText)
+			msg := h.newReplyMessage(message, h.paramErrorText(lang, errParse))
+			msg.ParseMode = "HTML"
 			h.Bot.Send(msg)
 			return
 		}
@@ -1715,51 +1921,8 @@ func (h *Handler) handleMessage(message *tgbotapi.Message) {
 		return
 	}
 	
-	if state == "awaiting_exchange_amount" {
-		diamondsToBuy, err := strconv.Atoi(message.Text)
-		if err != nil || diamondsToBuy <= 0 {
-			msg := h.newReplyMessage(message, h.Localizer.Get(lang, "exchange_invalid_amount"))
-			h.Bot.Send(msg)
-			return
-		}
-
-		creditsNeeded := diamondsToBuy * 20
-		totalCredits := user.PaidCredits + user.FreeCredits
-
-		if totalCredits < creditsNeeded {
-			args := map[string]string{
-				"diamonds_to_buy": strconv.Itoa(diamondsToBuy),
-				"credits_needed":  strconv.Itoa(creditsNeeded),
-				"credits_balance": strconv.Itoa(totalCredits),
-			}
-			msg := h.newReplyMessage(message, h.Localizer.Getf(lang, "exchange_not_enough_credits", args))
-			h.Bot.Send(msg)
-			return
-		}
-
-		creditsToDeduct := creditsNeeded
-		if user.FreeCredits >= creditsToDeduct {
-			user.FreeCredits -= creditsToDeduct
-		} else {
-			creditsToDeduct -= user.FreeCredits
-			user.FreeCredits = 0
-			user.PaidCredits -= creditsToDeduct
-		}
-
-		user.Diamonds += diamondsToBuy
-		h.DB.UpdateUser(user)
-
-		h.userStatesMutex.Lock()
-		delete(h.userStates, user.TelegramID)
-		h.userStatesMutex.Unlock()
-
-		args := map[string]string{
-			"credits_spent":        strconv.Itoa(creditsNeeded),
-			"diamonds_gained":      strconv.Itoa(diamondsToBuy),
-			"new_diamonds_balance": strconv.Itoa(user.Diamonds),
-		}
-		msg := h.newReplyMessage(message, h.Localizer.Getf(lang, "exchange_success", args))
-		h.Bot.Send(msg)
+	if strings.HasPrefix(state, "awaiting_exchange_amount") {
+		h.handleExchangeAmount(message, user, state)
 		return
 	}
 
@@ -1781,7 +1944,7 @@ func (h *Handler) handleMessage(message *tgbotapi.Message) {
 
 		if prompt == "" { return }
 
-		h.triggerVideoGeneration(user, message, modelID, prompt, imageURL)
+		h.triggerVideoGeneration(user, message, modelID, prompt, imageURL, false)
 		return
 	}
 
@@ -1949,7 +2112,7 @@ func (h *Handler) handleStyleSelection(callback *tgbotapi.CallbackQuery, styleID
 	fullText := warningText + mainText
 
 	if user.NumOutputs > 1 && selectedModel.ConfigurableNumOutputs {
-		totalCost := selectedModel.Cost * user.NumOutputs
+		totalCost := h.quoteGeneration(user, selectedModel, h.generationParams(selectedModel, userCustomSettings(user)), user.NumOutputs, 0)
 		warningArgs := map[string]string{
 			"num_images": strconv.Itoa(user.NumOutputs),
 			"total_cost": strconv.Itoa(totalCost),
@@ -1980,6 +2143,30 @@ func (h *Handler) handleStyleSelection(callback *tgbotapi.CallbackQuery, styleID
 
 // File: internal/bot/handlers.go
 
+// recordGeneration mencatat hasil prediksi ke tabel generations (thumbnail & kesehatan model di dashboard admin).
+// Biaya hanya dicatat untuk prediksi yang berhasil karena yang gagal tidak ditagih.
+func (h *Handler) recordGeneration(user *database.User, model *config.Model, kind, prompt string, urls []string, err error, cost int, started time.Time) {
+	g := &database.Generation{
+		TelegramID: user.TelegramID,
+		ModelID:    model.ID,
+		Kind:       kind,
+		Prompt:     prompt,
+		OutputURLs: urls,
+		Status:     database.GenerationSucceeded,
+		Cost:       cost,
+		DurationMS: int(time.Since(started).Milliseconds()),
+	}
+	if err != nil || len(urls) == 0 {
+		g.Status, g.Cost = database.GenerationFailed, 0
+		if err != nil {
+			g.Error = err.Error()
+		} else {
+			g.Error = "no output"
+		}
+	}
+	go h.DB.RecordGeneration(g)
+}
+
 func (h *Handler) triggerImageGeneration(user *database.User, originalMessage *tgbotapi.Message, modelID, prompt string, imageURLAndParams ...interface{}) {
 	// Hapus state agar user bersih
 	h.userStatesMutex.Lock()
@@ -2017,6 +2204,10 @@ func (h *Handler) triggerImageGeneration(user *database.User, originalMessage *t
 			rawCustomParams = params
 		}
 	}
+	confirmed := false
+	if len(imageURLAndParams) > 2 {
+		_, confirmed = imageURLAndParams[2].(generationConfirmed)
+	}
 
 	// Load custom settings mentah dari DB
 	if rawCustomParams == nil {
@@ -2030,7 +2221,6 @@ func (h *Handler) triggerImageGeneration(user *database.User, originalMessage *t
 	// --- [AWAL LOGIKA SANITASI / WHITELIST] --- 
 	// Kita buat map baru yang BERSIH. Hanya parameter yang ada di models.json 
 	// yang boleh masuk ke sini. Parameter sisa (sampah) dibuang.
-	cleanParams := make(map[string]interface{})
 
 	// 1. Tentukan Aspect Ratio & Num Outputs (Ini spesial, tidak masuk cleanParams dulu)
 	var aspectRatio string
@@ -2052,29 +2242,28 @@ func (h *Handler) triggerImageGeneration(user *database.User, originalMessage *t
 	}
 
 	// 2. Filter parameter lainnya berdasarkan models.json
-	for _, param := range selectedModel.Parameters {
-		// Skip AR dan NumOutputs karena sudah dihandle variabel terpisah di atas
-		if param.Name == "aspect_ratio" || param.Name == "num_outputs" {
-			continue
-		}
-
-		// Cek apakah user punya settingan untuk parameter ini di DB (rawCustomParams)?
-		if val, exists := rawCustomParams[param.Name]; exists {
-			// JIKA ADA: Masukkan ke cleanParams (Valid)
-			cleanParams[param.Name] = val
-		} else if param.Default != nil {
-			// JIKA TIDAK ADA: Pakai default dari models.json
-			cleanParams[param.Name] = param.Default
-		}
-        // Parameter sampah dari model lain otomatis tidak ter-copy ke cleanParams
-	}
+	cleanParams := h.generationParams(selectedModel, rawCustomParams)
 
 	// Debugging: Lihat apa yang bersih
 	log.Printf("DEBUG: Cleaned params for model %s: %+v", modelID, cleanParams)
     // --- [AKHIR LOGIKA SANITASI] ---
 
+	if !h.canUseModel(user, selectedModel) {
+		h.showPremiumUpsell(originalMessage.Chat.ID, user, selectedModel)
+		return
+	}
+
+	// --- CEK PROMPT (safety.json) --- sudah diperiksa sebelum konfirmasi belanja
+	if !confirmed && !h.screenPrompt(user, originalMessage, selectedModel, prompt) {
+		return
+	}
+
 	// --- CEK SALDO ---
-	totalCost := selectedModel.Cost * numOutputs
+	inputImages := len(finalImageURLs)
+	if finalImageURL != "" {
+		inputImages = 1
+	}
+	totalCost := h.quoteGeneration(user, selectedModel, cleanParams, numOutputs, inputImages)
 	totalAvailableCredits := user.PaidCredits + user.FreeCredits
 
 	if totalAvailableCredits < totalCost {
@@ -2087,11 +2276,32 @@ func (h *Handler) triggerImageGeneration(user *database.User, originalMessage *t
 		return
 	}
 
+	// --- BATAS BELANJA & KONFIRMASI (/limits) ---
+	if text := h.spendLimitText(user, wallet.CreditsPool, totalCost); text != "" {
+		msg := h.newReplyMessage(originalMessage, text)
+		msg.ParseMode = "HTML"
+		h.Bot.Send(msg)
+		return
+	}
+	if !confirmed {
+		run := func(u *database.User) {
+			args := make([]interface{}, 2, 3)
+			copy(args, imageURLAndParams)
+			h.triggerImageGeneration(u, originalMessage, modelID, prompt, append(args, generationConfirmed{})...)
+		}
+		if !h.confirmSpend(user, originalMessage, wallet.CreditsPool, totalCost, run) {
+			return
+		}
+	}
+
 	// --- EKSEKUSI ---
 	waitMsg := h.newReplyMessage(originalMessage, h.Localizer.Get(lang, "generating"))
 	sentMsg, _ := h.Bot.Send(waitMsg)
 	defer h.Bot.Send(tgbotapi.NewDeleteMessage(originalMessage.Chat.ID, sentMsg.MessageID))
 
+	release := h.acquireGenerationSlot(user, originalMessage.Chat.ID, sentMsg.MessageID, h.Localizer.Get(lang, "generating"))
+	defer release()
+
 	action := tgbotapi.NewChatAction(originalMessage.Chat.ID, tgbotapi.ChatUploadPhoto)
 	h.Bot.Send(action)
 
@@ -2102,51 +2312,51 @@ func (h *Handler) triggerImageGeneration(user *database.User, originalMessage *t
 	var err error
 
 	// Panggil Service Replicate menggunakan cleanParams (yang sudah bersih) <--- PENTING
+	started := time.Now()
 	if len(finalImageURLs) > 0 {
 		imageUrls, err = h.Replicate.CreatePrediction(ctx, selectedModel.ReplicateID, prompt, "", selectedModel.ImageParameterName, aspectRatio, numOutputs, cleanParams, finalImageURLs)
 	} else {
 		imageUrls, err = h.Replicate.CreatePrediction(ctx, selectedModel.ReplicateID, prompt, finalImageURL, selectedModel.ImageParameterName, aspectRatio, numOutputs, cleanParams)
 	}
+	h.recordGeneration(user, selectedModel, database.GenerationImage, prompt, imageUrls, err, totalCost, started)
 
 	if err != nil || len(imageUrls) == 0 {
 		// Log error detail untuk debugging di console
 		log.Printf("ERROR REPLICATE: %v", err)
+		if h.handleProviderRejection(user, originalMessage, selectedModel, prompt, err) {
+			return
+		}
 		failMsg := h.newReplyMessage(originalMessage, h.Localizer.Get(lang, "generation_failed"))
 		h.Bot.Send(failMsg)
 		return
 	}
 
-	// --- DEDUKSI KREDIT ---
-	costLeft := totalCost
-	if user.FreeCredits > 0 {
-		if user.FreeCredits >= costLeft {
-			user.FreeCredits -= costLeft
-			costLeft = 0
-		} else {
-			costLeft -= user.FreeCredits
-			user.FreeCredits = 0
+	// --- MODERASI OUTPUT (safety.json) --- sebelum kredit dipotong: jika semua gambar ditahan, user tidak membayar
+	outputs, withheld := plainOutputImages(imageUrls), 0
+	if modelID != "remove-background" && modelID != "recraft-upscaler" {
+		outputs, withheld = h.moderateOutputs(user, originalMessage, selectedModel, prompt, imageUrls)
+		if len(outputs) == 0 {
+			h.Bot.Send(h.newReplyMessage(originalMessage, h.Localizer.Get(lang, "output_withheld_all")))
+			return
 		}
 	}
-	if costLeft > 0 {
-		user.PaidCredits -= costLeft
+	// Gambar yang ditahan tidak dibayar: biaya dihitung ulang untuk output yang dikirim saja
+	if withheld > 0 {
+		totalCost = h.quoteGeneration(user, selectedModel, cleanParams, len(outputs), inputImages)
 	}
 
-	user.GeneratedImageCount++
-	h.DB.UpdateUser(user)
-
-	// Referral Bonus
-	if user.GeneratedImageCount == 2 && user.ReferrerID != 0 {
-		referrer, errRef := h.DB.GetUserByTelegramID(user.ReferrerID)
-		if errRef == nil && referrer != nil {
-			referrer.PaidCredits += 5
-			if errUpdate := h.DB.UpdateUser(referrer); errUpdate == nil {
-				notificationText := h.Localizer.Get(referrer.LanguageCode, "referral_bonus_notification")
-				msg := tgbotapi.NewMessage(referrer.TelegramID, notificationText)
-				msg.ParseMode = "Markdown"
-				h.Bot.Send(msg)
-			}
-		}
+	// --- DEDUKSI KREDIT --- dari saldo terbaru di database (free dulu, lalu paid), bukan dari salinan user
+	// yang dibaca sebelum antrian & generate agar gift/hadiah yang masuk di antaranya tidak tertimpa
+	if charged, err := h.Wallet.Charge(h.DB, user, wallet.CreditsPool, totalCost); err == nil {
+		h.DB.Record(user.TelegramID, database.TransactionGeneration, wallet.CreditsPool, -charged, selectedModel.Name)
+		h.afterSpend(user)
 	}
+	if count, err := h.DB.IncrementGeneratedImages(user.TelegramID); err == nil {
+		user.GeneratedImageCount = count
+	}
+
+	// Referral Bonus (milestone referee, lihat referral.json)
+	h.Referrals.OnGeneration(user)
 
 	// --- OUTPUT ---
 	if modelID == "remove-background" || modelID == "recraft-upscaler" {
@@ -2158,7 +2368,24 @@ func (h *Handler) triggerImageGeneration(user *database.User, originalMessage *t
 		}
 		caption := fmt.Sprintf("<b>Prompt:</b> <pre>%s</pre>\n<b>Model:</b> <code>%s</code>\n<b>Cost:</b> %d 💵", safePrompt, selectedModel.Name, totalCost)
 
-		if len(imageUrls) == 1 {
+		// File mentah hanya untuk gambar yang lolos tanpa spoiler/blur (dokumen tidak bisa disembunyikan)
+		var deliveredURLs []string
+		moderated := withheld > 0
+		for _, img := range outputs {
+			if img.moderated() {
+				moderated = true
+				continue
+			}
+			deliveredURLs = append(deliveredURLs, img.URL)
+		}
+		imageUrls = deliveredURLs
+
+		if moderated {
+			h.sendOutputImages(originalMessage, outputs, caption)
+			if withheld > 0 {
+				h.Bot.Send(h.newReplyMessage(originalMessage, h.Localizer.Getf(lang, "output_withheld_some", map[string]string{"count": strconv.Itoa(withheld)})))
+			}
+		} else if len(imageUrls) == 1 {
 			msg := h.newReplyPhoto(originalMessage, tgbotapi.FileURL(imageUrls[0]))
 			msg.Caption = caption
 			msg.ParseMode = "HTML"
@@ -2177,6 +2404,10 @@ func (h *Handler) triggerImageGeneration(user *database.User, originalMessage *t
 			h.Bot.Send(msg)
 		}
 
+		if len(imageUrls) == 0 {
+			return
+		}
+
 		// Simpan URL terakhir
 		h.lastGeneratedURLsMutex.Lock()
 		h.lastGeneratedURLs[user.TelegramID] = imageUrls
@@ -2216,10 +2447,11 @@ func (h *Handler) handleUpscaler(message *tgbotapi.Message) {
 		return
 	}
 
+	cost := h.modelCost(user, upscalerModel)
 	totalAvailableCredits := user.PaidCredits + user.FreeCredits
-	if totalAvailableCredits < upscalerModel.Cost {
+	if totalAvailableCredits < cost {
 		args := map[string]string{
-			"required": strconv.Itoa(upscalerModel.Cost),
+			"required": strconv.Itoa(cost),
 			"balance":  strconv.Itoa(totalAvailableCredits),
 		}
 		text := h.Localizer.Getf(lang, "insufficient_credits", args)
@@ -2233,7 +2465,7 @@ func (h *Handler) handleUpscaler(message *tgbotapi.Message) {
 	h.userStatesMutex.Unlock()
 
 	args := map[string]string{
-		"cost": strconv.Itoa(upscalerModel.Cost),
+		"cost": strconv.Itoa(cost),
 	}
 	text := h.Localizer.Getf(lang, "upscaler_prompt", args)
 
@@ -2253,7 +2485,7 @@ func (h *Handler) navigateModels(callback *tgbotapi.CallbackQuery, providerID st
 		}
 	}
 
-	keyboard := h.createModelSelectionKeyboard(providerModels, user.LanguageCode, providerID, page)
+	keyboard := h.createModelSelectionKeyboard(providerModels, user, providerID, page)
 	msg := tgbotapi.NewEditMessageReplyMarkup(callback.Message.Chat.ID, callback.Message.MessageID, keyboard)
 	h.Bot.Send(msg)
 }
@@ -2282,12 +2514,14 @@ func (h *Handler) handleStart(message *tgbotapi.Message) {
 			}
 		}
 
-		// Siapkan data pengguna baru
+		// Siapkan data pengguna baru; free credit awal menunggu tantangan anti-bot jika aktif
+		verified, freeCredits := h.onboarding(referrerID)
 		newUser := database.User{
 			TelegramID:           message.From.ID,
 			Username:             message.From.UserName,
 			PaidCredits:          0,
-			FreeCredits:          5, // Bonus awal
+			FreeCredits:          freeCredits, // Bonus awal
+			HumanVerified:        verified,
 			LastFreeCreditsReset: time.Now(),
 			LanguageCode:         "en", // Default Inggris dulu, nanti bisa ganti
 			AspectRatio:          "1:1",
@@ -2304,6 +2538,9 @@ func (h *Handler) handleStart(message *tgbotapi.Message) {
 
 		if referrerID != 0 {
 			log.Printf("INFO: User %d created with referral from %d", user.TelegramID, referrerID)
+			if user.HumanVerified {
+				h.Referrals.OnSignup(user)
+			}
 		}
 	}
 
@@ -2333,6 +2570,16 @@ func (h *Handler) handleStart(message *tgbotapi.Message) {
 	msg.ReplyMarkup = keyboard
 
 	h.Bot.Send(msg)
+
+	// Deep link promo (misal: /start promo_LAUNCH50); ditunda sampai tantangan anti-bot lulus
+	if code, ok := strings.CutPrefix(message.CommandArguments(), "promo_"); ok && code != "" {
+		h.redeemPromoCode(message.Chat.ID, user, code)
+	}
+
+	// User yang belum lulus tantangan anti-bot belum menerima free credit awal
+	if !user.HumanVerified && message.Chat.IsPrivate() {
+		h.sendCaptcha(message.Chat.ID, user, "")
+	}
 }
 
 func (h *Handler) handleHelp(message *tgbotapi.Message) {
@@ -2347,7 +2594,6 @@ func (h *Handler) handleHelp(message *tgbotapi.Message) {
 }
 
 func (h *Handler) handleProfile(message *tgbotapi.Message) {
-	// Panggil getOrCreateUser yang sudah memiliki logika reset
 	user, err := h.getOrCreateUser(message.From)
 	if err != nil {
 		// Error sudah di-log di dalam getOrCreateUser, cukup hentikan proses
@@ -2355,19 +2601,18 @@ func (h *Handler) handleProfile(message *tgbotapi.Message) {
 	}
 	lang := user.LanguageCode
 
-	// Logika reset sudah dipindahkan, sekarang bagian ini hanya untuk tampilan
+	// Free credit diisi ulang oleh job terjadwal (free_credits.json), di sini hanya hitung mundurnya
 	var resetTimeStr string
-	if user.IsPremium {
-		resetTimeStr = "N/A (Premium)"
+	if h.freeCreditRule(user).Amount == 0 {
+		resetTimeStr = "N/A"
 	} else {
-		nowUTC := time.Now().UTC()
-		nextResetUTC := time.Date(nowUTC.Year(), nowUTC.Month(), nowUTC.Day(), 0, 0, 0, 0, time.UTC).Add(24 * time.Hour)
-		duration := time.Until(nextResetUTC)
+		duration := time.Until(h.FreeCredits.NextReset(time.Now()))
 		resetTimeStr = h.formatDuration(duration, lang)
 	}
 
-	totalCredits := user.PaidCredits + user.FreeCredits
+	totalCredits := h.Wallet.Balance(user, wallet.CreditsPool)
 	args := map[string]string{
+		"balances":      h.walletBalanceLines(user, lang, "▸ {name}: `{balance}` {symbol}"),
 		"paid_credits":  "`" + strconv.Itoa(user.PaidCredits) + "`",
 		"free_credits":  "`" + strconv.Itoa(user.FreeCredits) + "`",
 		"diamonds":      "`" + strconv.Itoa(user.Diamonds) + "`",
@@ -2380,34 +2625,7 @@ func (h *Handler) handleProfile(message *tgbotapi.Message) {
 	msg := h.newReplyMessage(message, text)
 
 	msg.ParseMode = "Markdown"
-	keyboard := h.createProfileKeyboard(lang)
-	msg.ReplyMarkup = &keyboard
-	h.Bot.Send(msg)
-}
-
-func (h *Handler) handleReferral(message *tgbotapi.Message) {
-	user, err := h.getOrCreateUser(message.From)
-	if err != nil {
-		return
-	}
-	lang := user.LanguageCode
-	referralLink := fmt.Sprintf("https://t.me/%s?start=ref_%d", h.Bot.Self.UserName, user.TelegramID)
-	text := fmt.Sprintf("%s\n\n%s\n%s",
-		h.Localizer.Get(lang, "referral_message"),
-		h.Localizer.Get(lang, "referral_link_text"),
-		referralLink,
-	)
-	shareText := url.QueryEscape(fmt.Sprintf("Come join this cool AI image bot! Use my link to get a bonus:😉\n %s", referralLink))
-	shareURL := fmt.Sprintf("https://t.me/share/url?url=%s", shareText)
-
-	// Buat keyboard dengan tombol "Share"
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonURL("🚀 Share with Friends", shareURL),
-		),
-	)
-	msg := h.newReplyMessage(message, text)
-	msg.ParseMode = "HTML"
+	keyboard := h.createProfileKeyboard(user)
 	msg.ReplyMarkup = &keyboard
 	h.Bot.Send(msg)
 }
@@ -2462,11 +2680,13 @@ func (h *Handler) getOrCreateUser(tgUser *tgbotapi.User) (*database.User, error)
 		return nil, err
 	}
 	if user == nil {
+		verified, freeCredits := h.onboarding(0)
 		newUser := database.User{
 			TelegramID:           tgUser.ID,
 			Username:             tgUser.UserName,
 			PaidCredits:          0,
-			FreeCredits:          5,
+			FreeCredits:          freeCredits,
+			HumanVerified:        verified,
 			Diamonds:             0,
 			LastFreeCreditsReset: time.Now(),
 			LanguageCode:         "en",
@@ -2478,24 +2698,12 @@ func (h *Handler) getOrCreateUser(tgUser *tgbotapi.User) (*database.User, error)
 			log.Printf("ERROR: Failed to create user %d in database: %v", tgUser.ID, err)
 			return nil, err
 		}
-	} else {
-		// --- MULAI LOGIKA BARU UNTUK PENGGUNA LAMA ---
-		now := time.Now()
-		lastReset := user.LastFreeCreditsReset
-		// Cek jika hari kalender sudah berbeda (dalam zona waktu UTC)
-		if now.YearDay() != lastReset.YearDay() || now.Year() != lastReset.Year() {
-			log.Printf("INFO: Resetting free credits for existing user %d", user.TelegramID)
-			user.FreeCredits = 5
-			user.LastFreeCreditsReset = now
-			// Penting: simpan perubahan ini ke DB segera
-			err := h.DB.UpdateUser(user)
-			if err != nil {
-				// Log error tapi tetap kembalikan user object yang sudah diupdate di memory
-				log.Printf("ERROR: Failed to update user credits during reset for user %d: %v", user.TelegramID, err)
-			}
-		}
-		// --- SELESAI LOGIKA BARU ---
 	}
+	// Akun yang sudah dihapus hanya aktif lagi lewat /start (lihat restoreDeletedUser)
+	if user.IsDeleted() {
+		return user, database.ErrAccountDeleted
+	}
+	// Pengisian ulang free credit tidak lagi dilakukan di sini, lihat StartFreeCreditScheduler
 	return user, nil
 }
 
@@ -2728,6 +2936,9 @@ func (h *Handler) handleBroadcastGroup(message *tgbotapi.Message) {
 		return
 	}
 
+	h.DB.RecordAudit(message.From.ID, database.AuditBroadcastGroup, 0, map[string]interface{}{"text": broadcastText, "photo": photoFileID, "recipients": len(allGroups)})
+	progress := h.startBroadcastJob(database.BroadcastGroups, message.From.ID, broadcastText, photoFileID, len(allGroups))
+
 	args := map[string]string{"group_count": strconv.Itoa(len(allGroups))}
 	startMsgText := "⏳ Starting group broadcast to {group_count} groups..."
 	startMsg := h.newReplyMessage(message, h.Localizer.Getf(lang, startMsgText, args))
@@ -2755,8 +2966,10 @@ func (h *Handler) handleBroadcastGroup(message *tgbotapi.Message) {
 				log.Printf("WARN: Failed to send broadcast to group %d (%s). Removing from DB. Error: %v", group.GroupID, group.GroupTitle, err)
 				h.DB.DeleteGroup(group.GroupID)
 			}
+			progress.add(err == nil)
 			time.Sleep(100 * time.Millisecond)
 		}
+		progress.finish()
 
 		finishArgs := map[string]string{
 			"sent_count":  strconv.Itoa(sentCount),
@@ -2801,37 +3014,24 @@ func (h *Handler) handleSelectAdvancedSetting(callback *tgbotapi.CallbackQuery,
 	}
 	lang := user.LanguageCode
 
-	var selectedParam *config.Parameter
-	// Langsung cari parameter yang relevan tanpa menyimpan modelnya
-	found := false
-	for _, m := range h.Models {
-		if m.ID == modelID {
-			for _, p := range m.Parameters {
-				if p.Name == paramName {
-					selectedParam = &p
-					found = true
-					break
-				}
-			}
-		}
-		if found {
-			break
-		}
-	}
-
+	_, selectedParam := h.findModelParameter(modelID, paramName)
 	if selectedParam == nil {
 		log.Printf("WARN: Parameter %s for model %s not found", paramName, modelID)
 		return
 	}
 
-	// Jika parameter punya daftar 'options', tampilkan sebagai tombol
-	if len(selectedParam.Options) > 0 {
+	// Jika parameter punya daftar 'options' (atau boolean/enum), tampilkan sebagai tombol
+	if selectedParam.IsChoice() {
 		var keyboardRows [][]tgbotapi.InlineKeyboardButton
 		var currentRow []tgbotapi.InlineKeyboardButton
 
-		for _, option := range selectedParam.Options {
+		for _, option := range selectedParam.ChoiceOptions() {
 			callbackData := fmt.Sprintf("adv_set_option:%s:%s:%s", modelID, paramName, option)
-			button := tgbotapi.NewInlineKeyboardButtonData(option, callbackData)
+			buttonText := selectedParam.OptionLabel(option)
+			if selectedParam.Type == config.ParamTypeBoolean && buttonText == option {
+				buttonText = h.Localizer.Get(lang, "param_boolean_"+option)
+			}
+			button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
 			currentRow = append(currentRow, button)
 
 			if len(currentRow) == 2 {
@@ -2849,7 +3049,7 @@ func (h *Handler) handleSelectAdvancedSetting(callback *tgbotapi.CallbackQuery,
 
 		keyboard := tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
 
-		msgText := fmt.Sprintf("Select a value for <b>%s</b>:", selectedParam.Label)
+		msgText := fmt.Sprintf("Select a value for <b>%s</b>:", html.EscapeString(selectedParam.Label))
 		msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, msgText)
 		msg.ParseMode = "HTML"
 		msg.ReplyMarkup = &keyboard
@@ -2861,9 +3061,12 @@ func (h *Handler) handleSelectAdvancedSetting(callback *tgbotapi.CallbackQuery,
 		h.userStatesMutex.Unlock()
 
 		var promptText strings.Builder
-		promptText.WriteString(fmt.Sprintf("Please enter a new value for <b>%s</b>.", selectedParam.Label))
+		promptText.WriteString(fmt.Sprintf("Please enter a new value for <b>%s</b>.", html.EscapeString(selectedParam.Label)))
 		if selectedParam.Description != "" {
-			promptText.WriteString(fmt.Sprintf("\n\n<i>%s</i>", selectedParam.Description))
+			promptText.WriteString(fmt.Sprintf("\n\n<i>%s</i>", html.EscapeString(selectedParam.Description)))
+		}
+		if hint := h.paramInputHint(lang, selectedParam); hint != "" {
+			promptText.WriteString("\n\n" + hint)
 		}
 
 		msg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, promptText.String())
@@ -2887,40 +3090,21 @@ func (h *Handler) handleSetOption(callback *tgbotapi.CallbackQuery, modelID, par
 		customSettings = make(map[string]interface{})
 	}
 
-	var targetParamType string
-	var selectedModel *config.Model
-	found := false
-	for _, model := range h.Models {
-		if model.ID == modelID {
-			selectedModel = &model // Simpan referensi model untuk refresh dashboard nanti
-			for _, param := range model.Parameters {
-				if param.Name == paramName {
-					targetParamType = param.Type
-					found = true
-					break
-				}
-			}
-		}
-		if found {
-			break
-		}
-	}
-
-	if !found || selectedModel == nil {
+	// Simpan referensi model untuk refresh dashboard nanti
+	selectedModel, selectedParam := h.findModelParameter(modelID, paramName)
+	if selectedModel == nil || selectedParam == nil {
 		log.Printf("WARN: Parameter %s for model %s not found in handleSetOption", paramName, modelID)
 		return
 	}
 
-	// Konversi nilai berdasarkan tipe data
-	var parsedValue interface{}
-	switch targetParamType {
-	case "integer":
-		val, _ := strconv.ParseInt(optionValue, 10, 64)
-		parsedValue = int(val)
-	case "number":
-		parsedValue, _ = strconv.ParseFloat(optionValue, 64)
-	default:
-		parsedValue = optionValue
+	// Konversi & validasi nilai (callback data bisa saja dimanipulasi)
+	parsedValue, err := selectedParam.Parse(optionValue)
+	if err != nil {
+		log.Printf("WARN: Rejected option '%s' for %s/%s: %v", optionValue, modelID, paramName, err)
+		msg := tgbotapi.NewMessage(callback.Message.Chat.ID, h.paramErrorText(user.LanguageCode, err))
+		msg.ParseMode = "HTML"
+		h.Bot.Send(msg)
+		return
 	}
 
 	customSettings[paramName] = parsedValue
@@ -2932,6 +3116,58 @@ func (h *Handler) handleSetOption(callback *tgbotapi.CallbackQuery, modelID, par
 	h.updateGenerationDashboard(callback.Message.Chat.ID, callback.Message.MessageID, user, selectedModel)
 }
 
+// findModelParameter mencari model beserta skema parameternya dari models.json.
+func (h *Handler) findModelParameter(modelID, paramName string) (*config.Model, *config.Parameter) {
+	for i := range h.Models {
+		if h.Models[i].ID != modelID {
+			continue
+		}
+		model := h.Models[i]
+		for j := range model.Parameters {
+			if model.Parameters[j].Name == paramName {
+				param := model.Parameters[j]
+				return &model, &param
+			}
+		}
+		return &model, nil
+	}
+	return nil, nil
+}
+
+// paramErrorText menerjemahkan error validasi parameter menjadi pesan untuk user.
+func (h *Handler) paramErrorText(lang string, err error) string {
+	paramErr, ok := err.(*config.ParamError)
+	if !ok {
+		return h.Localizer.Get(lang, "param_error_generic")
+	}
+	args := make(map[string]string, len(paramErr.Args))
+	for k, v := range paramErr.Args {
+		args[k] = html.EscapeString(v)
+	}
+	return h.Localizer.Getf(lang, paramErr.Key, args)
+}
+
+// paramInputHint menampilkan batas yang berlaku (range / panjang / multi-baris) saat user diminta mengetik nilai.
+func (h *Handler) paramInputHint(lang string, param *config.Parameter) string {
+	var hints []string
+	min, max := param.RangeHint()
+	switch {
+	case min != "" && max != "":
+		hints = append(hints, h.Localizer.Getf(lang, "param_hint_range", map[string]string{"min": min, "max": max}))
+	case min != "":
+		hints = append(hints, h.Localizer.Getf(lang, "param_hint_min", map[string]string{"min": min}))
+	case max != "":
+		hints = append(hints, h.Localizer.Getf(lang, "param_hint_max", map[string]string{"max": max}))
+	}
+	if param.MaxLength > 0 {
+		hints = append(hints, h.Localizer.Getf(lang, "param_hint_max_length", map[string]string{"max_length": strconv.Itoa(param.MaxLength)}))
+	}
+	if param.Type == config.ParamTypeText {
+		hints = append(hints, h.Localizer.Get(lang, "param_hint_multiline"))
+	}
+	return strings.Join(hints, "\n")
+}
+
 // showPromptEntryScreen is a helper function to display the prompt entry message.
 // This avoids code duplication between handleStyleSelection and the 'back' action.
 func (h *Handler) showPromptEntryScreen(callback *tgbotapi.CallbackQuery, modelID string, styleID string, isEdit bool) {
@@ -2974,7 +3210,7 @@ func (h *Handler) showPromptEntryScreen(callback *tgbotapi.CallbackQuery, modelI
 	fullText := warningText + mainText
 
 	if user.NumOutputs > 1 && selectedModel.ConfigurableNumOutputs {
-		totalCost := selectedModel.Cost * user.NumOutputs
+		totalCost := h.quoteGeneration(user, selectedModel, h.generationParams(selectedModel, userCustomSettings(user)), user.NumOutputs, 0)
 		warningArgs := map[string]string{
 			"num_images": strconv.Itoa(user.NumOutputs),
 			"total_cost": strconv.Itoa(totalCost),
@@ -3027,22 +3263,19 @@ func (h *Handler) handleExchangeCommand(message *tgbotapi.Message) {
 	}
 	lang := user.LanguageCode
 
-	h.userStatesMutex.Lock()
-	h.userStates[user.TelegramID] = "awaiting_exchange_amount"
-	h.userStatesMutex.Unlock()
-
-	totalCredits := user.PaidCredits + user.FreeCredits
-	args := map[string]string{
-		"credits":  strconv.Itoa(totalCredits),
-		"diamonds": strconv.Itoa(user.Diamonds),
+	exchanges := h.Wallet.EnabledExchanges()
+	switch len(exchanges) {
+	case 0:
+		h.Bot.Send(h.newReplyMessage(message, h.Localizer.Get(lang, "exchange_unavailable")))
+	case 1:
+		h.startExchange(message.Chat.ID, user, &exchanges[0])
+	default:
+		msg := h.newReplyMessage(message, h.Localizer.Get(lang, "exchange_choose"))
+		msg.ParseMode = "HTML"
+		keyboard := h.createExchangeKeyboard(lang, exchanges)
+		msg.ReplyMarkup = &keyboard
+		h.Bot.Send(msg)
 	}
-	text := h.Localizer.Getf(lang, "exchange_prompt", args)
-
-	msg := h.newReplyMessage(message, text)
-	msg.ParseMode = "HTML"
-	keyboard := h.createCancelFlowKeyboard(lang)
-	msg.ReplyMarkup = &keyboard
-	h.Bot.Send(msg)
 }
 
 func (h *Handler) showProviderMenu(chatID int64, userID int64, modelType string, messageID ...int) {
@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	historyPageSize  = 10
+	historyCSVBatch  = 1000
+	historyCSVMaxRow = 50000 // batas aman ukuran file CSV
+)
+
+// handleHistory menangani /history (halaman pertama riwayat saldo).
+func (h *Handler) handleHistory(message *tgbotapi.Message) {
+	user, err := h.getOrCreateUser(message.From)
+	if err != nil {
+		return
+	}
+	text, keyboard := h.buildHistoryPage(user, 0)
+	msg := h.newReplyMessage(message, text)
+	msg.ParseMode = "HTML"
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	h.Bot.Send(msg)
+}
+
+// handleHistoryPage menangani tombol history:<page>. Dari tombol profil pesan baru dikirim,
+// dari tombol navigasi pesan yang sama diedit.
+func (h *Handler) handleHistoryPage(callback *tgbotapi.CallbackQuery, data string, edit bool) {
+	user, err := h.getOrCreateUser(callback.From)
+	if err != nil {
+		return
+	}
+	page, _ := strconv.Atoi(data)
+	text, keyboard := h.buildHistoryPage(user, page)
+
+	if !edit {
+		msg := tgbotapi.NewMessage(callback.Message.Chat.ID, text)
+		msg.ParseMode = "HTML"
+		if keyboard != nil {
+			msg.ReplyMarkup = keyboard
+		}
+		h.Bot.Send(msg)
+		return
+	}
+	editMsg := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	editMsg.ParseMode = "HTML"
+	editMsg.ReplyMarkup = keyboard
+	h.Bot.Send(editMsg)
+}
+
+func (h *Handler) buildHistoryPage(user *database.User, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	lang := user.LanguageCode
+	if page < 0 {
+		page = 0
+	}
+
+	transactions, total, err := h.DB.GetTransactions(user.TelegramID, page*historyPageSize, historyPageSize)
+	if err != nil {
+		return h.Localizer.Get(lang, "history_error"), nil
+	}
+	if total == 0 {
+		return h.Localizer.Get(lang, "history_empty"), nil
+	}
+
+	pages := (total + historyPageSize - 1) / historyPageSize
+	var lines []string
+	for _, tx := range transactions {
+		lines = append(lines, h.formatHistoryLine(tx, lang))
+	}
+	text := h.Localizer.Getf(lang, "history_title", map[string]string{
+		"page":  strconv.Itoa(page + 1),
+		"pages": strconv.Itoa(pages),
+		"lines": strings.Join(lines, "\n\n"),
+	})
+
+	keyboard := h.createHistoryKeyboard(lang, page, pages)
+	return text, &keyboard
+}
+
+func (h *Handler) formatHistoryLine(tx database.CreditTransaction, lang string) string {
+	amount := strconv.Itoa(tx.Amount)
+	if tx.Amount > 0 {
+		amount = "+" + amount
+	}
+	line := fmt.Sprintf("<code>%s</code> %s\n<b>%s</b> %s", formatHistoryTime(tx.CreatedAt), h.historyKindLabel(tx.Kind, lang), amount, h.Wallet.Symbol(tx.Currency))
+	if tx.Description != "" {
+		line += " · " + html.EscapeString(tx.Description)
+	}
+	return line
+}
+
+// historyKindLabel mengembalikan label jenis transaksi, jenis yang belum punya terjemahan ditampilkan apa adanya.
+func (h *Handler) historyKindLabel(kind, lang string) string {
+	key := "history_kind_" + kind
+	if label := h.Localizer.Get(lang, key); label != key {
+		return label
+	}
+	return kind
+}
+
+func formatHistoryTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04")
+}
+
+// sendHistoryCSV mengirim seluruh riwayat saldo user sebagai dokumen CSV.
+func (h *Handler) sendHistoryCSV(callback *tgbotapi.CallbackQuery) {
+	user, err := h.getOrCreateUser(callback.From)
+	if err != nil {
+		return
+	}
+	lang := user.LanguageCode
+	chatID := callback.Message.Chat.ID
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"time_utc", "type", "currency", "amount", "description"})
+	for offset := 0; offset < historyCSVMaxRow; offset += historyCSVBatch {
+		transactions, _, err := h.DB.GetTransactions(user.TelegramID, offset, historyCSVBatch)
+		if err != nil {
+			h.Bot.Send(tgbotapi.NewMessage(chatID, h.Localizer.Get(lang, "history_error")))
+			return
+		}
+		for _, tx := range transactions {
+			w.Write([]string{
+				formatHistoryTime(tx.CreatedAt),
+				tx.Kind,
+				tx.Currency,
+				strconv.Itoa(tx.Amount),
+				tx.Description,
+			})
+		}
+		if len(transactions) < historyCSVBatch {
+			break
+		}
+	}
+	w.Flush()
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("history_%d.csv", user.TelegramID),
+		Bytes: buf.Bytes(),
+	})
+	doc.Caption = h.Localizer.Get(lang, "history_csv_caption")
+	h.Bot.Send(doc)
+}
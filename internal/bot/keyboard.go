@@ -5,9 +5,11 @@ import (
 	"strconv"
 	"strings"
 	"encoding/json"
+	"telegram-ai-bot/internal/captcha"
 	"telegram-ai-bot/internal/config"
 
 	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/wallet"
 	
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -32,7 +34,8 @@ func (h *Handler) createProviderSelectionKeyboard(providers []config.Provider, l
 	return tgbotapi.NewInlineKeyboardMarkup(keyboard...)
 }
 
-func (h *Handler) createModelSelectionKeyboard(models []config.Model, lang string, providerID string, page int) tgbotapi.InlineKeyboardMarkup {
+func (h *Handler) createModelSelectionKeyboard(models []config.Model, user *database.User, providerID string, page int) tgbotapi.InlineKeyboardMarkup {
+	lang := user.LanguageCode
 	var keyboard [][]tgbotapi.InlineKeyboardButton
 
 	start := page * itemsPerPage
@@ -49,14 +52,13 @@ func (h *Handler) createModelSelectionKeyboard(models []config.Model, lang strin
 
 	var row []tgbotapi.InlineKeyboardButton
 	for i, model := range paginatedModels {
-		var buttonText string
-		if model.Type == "video" {
-			buttonText = fmt.Sprintf("%s (%d 💎)", model.Name, model.DiamondCost)
-		} else {
-			buttonText = fmt.Sprintf("%s (%d 💵)", model.Name, model.Cost)
-		}
-		
+		buttonText := h.modelButtonText(user, &model)
+
+		// Model di luar tier user tetap tampil (terkunci) agar user tahu ada opsi Premium
 		callbackData := fmt.Sprintf("model_select:%s", model.ID)
+		if !h.canUseModel(user, &model) {
+			callbackData = fmt.Sprintf("model_locked:%s", model.ID)
+		}
 		row = append(row, tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData))
 
 		if (i+1)%2 == 0 || i == len(paginatedModels)-1 {
@@ -261,7 +263,12 @@ func (h *Handler) createChatModeKeyboard(lang string) tgbotapi.ReplyKeyboardMark
 	return keyboard
 }
 
-func (h *Handler) createProfileKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
+func (h *Handler) createProfileKeyboard(user *database.User) tgbotapi.InlineKeyboardMarkup {
+	lang := user.LanguageCode
+	notifyKey := "button_refill_notify_off"
+	if user.NotifyFreeCredits {
+		notifyKey = "button_refill_notify_on"
+	}
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "button_topup"), "main_menu_topup"),
@@ -269,6 +276,131 @@ func (h *Handler) createProfileKeyboard(lang string) tgbotapi.InlineKeyboardMark
 		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "button_referral"), "main_menu_referral"),
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "button_history"), "history_open"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, notifyKey), "toggle_refill_notify"),
+		),
+	)
+}
+
+// createHistoryKeyboard berisi navigasi halaman /history dan tombol ekspor CSV.
+func (h *Handler) createHistoryKeyboard(lang string, page, pages int) tgbotapi.InlineKeyboardMarkup {
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "history_prev"), fmt.Sprintf("history:%d", page-1)))
+	}
+	if page+1 < pages {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "history_next"), fmt.Sprintf("history:%d", page+1)))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "history_csv_button"), "history_csv"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// createAuditKeyboard berisi navigasi halaman /audit dan tombol ekspor CSV. filter adalah hasil encodeAuditFilter.
+func (h *Handler) createAuditKeyboard(page, pages int, filter string) tgbotapi.InlineKeyboardMarkup {
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get("en", "history_prev"), fmt.Sprintf("audit:%d:%s", page-1, filter)))
+	}
+	if page+1 < pages {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get("en", "history_next"), fmt.Sprintf("audit:%d:%s", page+1, filter)))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get("en", "history_csv_button"), "audit_csv:"+filter),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// createCaptchaKeyboard berisi pilihan jawaban tantangan anti-bot. Data tombol hanya berisi
+// indeks pilihan agar jawaban tidak bisa dibaca dari callback.
+func (h *Handler) createCaptchaKeyboard(challenge *captcha.Challenge) tgbotapi.InlineKeyboardMarkup {
+	perRow := 3
+	if len(challenge.Options) == 4 {
+		perRow = 2
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for i, option := range challenge.Options {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(option, fmt.Sprintf("captcha:%d", i)))
+		if len(row) == perRow {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// createReferralKeyboard berisi tombol share link referral dan leaderboard.
+func (h *Handler) createReferralKeyboard(lang, shareURL string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(h.Localizer.Get(lang, "button_referral_share"), shareURL),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "button_referral_leaderboard"), "referral_leaderboard"),
+		),
+	)
+}
+
+func (h *Handler) createSpendConfirmKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "spend_confirm_button"), "spend_confirm"),
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "spend_cancel_button"), "spend_cancel"),
+		),
+	)
+}
+
+// createLowBalanceKeyboard membuka menu paket Stars (ShowStarsPackages) dengan satu tap.
+func (h *Handler) createLowBalanceKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "low_balance_button"), "topup_stars"),
+		),
+	)
+}
+
+func (h *Handler) createGiftConfirmKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "gift_confirm_button"), "gift_confirm"),
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "gift_cancel_button"), "gift_cancel"),
+		),
+	)
+}
+
+func (h *Handler) createDeleteAccountKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "deleteme_confirm_button"), "deleteme_confirm"),
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "deleteme_cancel_button"), "deleteme_cancel"),
+		),
+	)
+}
+
+func (h *Handler) createPremiumUpsellKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "premium_upsell_button"), "sub_buy"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "back_button"), "main_menu_back"),
 		),
 	)
 }
@@ -379,19 +511,8 @@ func (h *Handler) createAdvancedSettingsKeyboard(lang string, model *config.Mode
 			}
 		}
 
-		// Format nilai agar lebih rapi
-		var displayValue string
-		switch v := currentValue.(type) {
-		case float64:
-			// Cek apakah angka tersebut sebenarnya integer
-			if v == float64(int(v)) {
-				displayValue = fmt.Sprintf("%d", int(v))
-			} else {
-				displayValue = fmt.Sprintf("%.1f", v)
-			}
-		default:
-			displayValue = fmt.Sprintf("%v", currentValue)
-		}
+		// Format nilai agar lebih rapi (angka bulat tanpa desimal, label untuk enum)
+		displayValue := param.DisplayValue(currentValue)
 
 
 		settingsText.WriteString(fmt.Sprintf("▸ %s: <code>%s</code>\n", param.Label, displayValue))
@@ -424,6 +545,20 @@ func (h *Handler) createCancelFlowKeyboard(lang string) tgbotapi.InlineKeyboardM
 	)
 }
 
+// createExchangeKeyboard menampilkan arah penukaran yang aktif di wallet.json.
+func (h *Handler) createExchangeKeyboard(lang string, exchanges []wallet.Exchange) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, ex := range exchanges {
+		text := fmt.Sprintf("%d %s → %d %s", ex.FromAmount, h.Wallet.Symbol(ex.From), ex.ToAmount, h.Wallet.Symbol(ex.To))
+		text = fmt.Sprintf("%s → %s (%s)", h.Wallet.DisplayName(ex.From, lang), h.Wallet.DisplayName(ex.To, lang), text)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(text, "exchange_pick:"+ex.ID)))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(h.Localizer.Get(lang, "cancel_button"), "cancel_flow"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
 func (h *Handler) createStyleConfirmationKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -0,0 +1,389 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	moderationCacheTTL    = 30 * time.Second // status ban dibaca ulang dari database setelah ini
+	moderationNoticeEvery = 5 * time.Minute  // pesan "kamu dibanned" paling sering sekali per jendela ini
+	moderationRecentRows  = 5                // generate & pembayaran terakhir di /user
+)
+
+type moderationEntry struct {
+	User      *database.User // nil = user belum terdaftar
+	FetchedAt time.Time
+}
+
+// moderationCache menyimpan status user sebentar agar HandleUpdate tidak membaca database di setiap update.
+type moderationCache struct {
+	mu       sync.Mutex
+	entries  map[int64]moderationEntry
+	notified map[int64]time.Time
+}
+
+func newModerationCache() *moderationCache {
+	return &moderationCache{entries: make(map[int64]moderationEntry), notified: make(map[int64]time.Time)}
+}
+
+func (c *moderationCache) invalidate(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+}
+
+// shouldNotify membatasi pesan pemberitahuan ke user yang diblokir.
+func (c *moderationCache) shouldNotify(userID int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.notified[userID]; ok && time.Since(last) < moderationNoticeEvery {
+		return false
+	}
+	c.notified[userID] = time.Now()
+	return true
+}
+
+// blockedUser mengembalikan user jika dia sedang dibanned/disuspend atau sudah menghapus akunnya,
+// nil jika boleh dilayani.
+func (h *Handler) blockedUser(userID int64) *database.User {
+	if h.isAdmin(userID) {
+		return nil
+	}
+	c := h.moderation
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+	if !ok || time.Since(entry.FetchedAt) > moderationCacheTTL {
+		user, err := h.DB.GetUserByTelegramID(userID)
+		if err != nil {
+			// Jangan blokir semua orang saat database bermasalah
+			return nil
+		}
+		entry = moderationEntry{User: user, FetchedAt: time.Now()}
+		c.mu.Lock()
+		c.entries[userID] = entry
+		c.mu.Unlock()
+	}
+	if entry.User == nil || (!entry.User.IsBlocked(time.Now()) && !entry.User.IsDeleted()) {
+		return nil
+	}
+	return entry.User
+}
+
+// restoreDeletedUser mengaktifkan lagi akun yang sudah dihapus jika user mengirim /start di chat pribadi.
+// Perintah lain ditolak agar baris yang sudah dianonimkan tidak diam-diam terisi lagi.
+func (h *Handler) restoreDeletedUser(message *tgbotapi.Message, user *database.User) bool {
+	if user.IsBlocked(time.Now()) || !message.Chat.IsPrivate() || message.Command() != "start" {
+		return false
+	}
+	if _, err := h.DB.RestoreUser(user.TelegramID); err != nil {
+		return false
+	}
+	h.moderation.invalidate(user.TelegramID)
+	log.Printf("INFO: User %d started again after deleting their account", user.TelegramID)
+	return true
+}
+
+// rejectBlockedUser dipanggil HandleUpdate sebelum handler mana pun. Pembayaran tidak diblokir di sini:
+// pre-checkout ditolak oleh PaymentHandler, dan successful_payment harus tetap dikreditkan.
+func (h *Handler) rejectBlockedUser(update tgbotapi.Update) bool {
+	switch {
+	case update.Message != nil && update.Message.SuccessfulPayment == nil && update.Message.From != nil:
+		message := update.Message
+		user := h.blockedUser(message.From.ID)
+		if user == nil {
+			return false
+		}
+		if user.IsDeleted() && h.restoreDeletedUser(message, user) {
+			return false
+		}
+		// Di grup cukup diabaikan agar tidak mengganggu percakapan
+		if message.Chat.IsPrivate() && h.moderation.shouldNotify(user.TelegramID) {
+			msg := tgbotapi.NewMessage(message.Chat.ID, h.blockedNotice(user))
+			msg.ParseMode = "HTML"
+			h.Bot.Send(msg)
+		}
+		return true
+	case update.CallbackQuery != nil:
+		user := h.blockedUser(update.CallbackQuery.From.ID)
+		if user == nil {
+			return false
+		}
+		text := []rune(html.UnescapeString(stripTags(h.blockedNotice(user))))
+		if len(text) > 200 {
+			// Batas teks alert callback dari Telegram
+			text = append(text[:199], '…')
+		}
+		alert := tgbotapi.NewCallbackWithAlert(update.CallbackQuery.ID, string(text))
+		h.Bot.Request(alert)
+		return true
+	}
+	return false
+}
+
+// blockedNotice adalah pesan untuk user yang dibanned/disuspend atau sudah menghapus akunnya,
+// dalam bahasanya sendiri.
+func (h *Handler) blockedNotice(user *database.User) string {
+	lang := user.LanguageCode
+	if !user.IsBlocked(time.Now()) {
+		return h.Localizer.Get(lang, "account_deleted_notice")
+	}
+	args := map[string]string{"reason": html.EscapeString(user.BanReason)}
+	key := "moderation_banned_notice"
+	if !user.IsBanned {
+		key = "moderation_suspended_notice"
+		args["until"] = user.SuspendedUntil.UTC().Format("2006-01-02 15:04") + " UTC"
+	}
+	if user.BanReason == "" {
+		args["reason"] = h.Localizer.Get(lang, "moderation_no_reason")
+	}
+	return h.Localizer.Getf(lang, key, args)
+}
+
+// stripTags menghapus tag HTML sederhana untuk teks alert callback (tidak mendukung format).
+func stripTags(text string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range text {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// resolveUserArg menerima ID Telegram atau @username.
+func (h *Handler) resolveUserArg(arg string) (*database.User, error) {
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return h.DB.GetUserByTelegramID(id)
+	}
+	return h.DB.GetUserByUsername(strings.TrimPrefix(arg, "@"))
+}
+
+// moderationTarget membaca user target dari argumen pertama perintah admin dan membalas jika tidak valid.
+func (h *Handler) moderationTarget(message *tgbotapi.Message, parts []string, minArgs int, usageKey string) *database.User {
+	lang := "en"
+	if len(parts) < minArgs {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, usageKey))
+		return nil
+	}
+	target, err := h.resolveUserArg(parts[0])
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "moderation_error"))
+		return nil
+	}
+	if target == nil {
+		h.sendPromoAdminText(message, h.Localizer.Getf(lang, "moderation_user_not_found", map[string]string{"user": html.EscapeString(parts[0])}))
+		return nil
+	}
+	if h.isAdmin(target.TelegramID) {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "moderation_target_admin"))
+		return nil
+	}
+	return target
+}
+
+// handleBan menangani /ban <id|@username> [alasan].
+func (h *Handler) handleBan(message *tgbotapi.Message) {
+	parts := strings.Fields(message.CommandArguments())
+	target := h.moderationTarget(message, parts, 1, "moderation_ban_usage")
+	if target == nil {
+		return
+	}
+	reason := strings.Join(parts[1:], " ")
+	if err := h.DB.SetBanned(target.TelegramID, true, reason); err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get("en", "moderation_error"))
+		return
+	}
+	log.Printf("INFO: Admin %d banned user %d (%s)", message.From.ID, target.TelegramID, reason)
+	h.DB.RecordAudit(message.From.ID, database.AuditBan, target.TelegramID, map[string]interface{}{"reason": reason})
+	target.IsBanned, target.BanReason = true, reason
+	h.applyModeration(message, target, "moderation_banned")
+}
+
+// handleUnban menangani /unban <id|@username> (juga menghapus suspend).
+func (h *Handler) handleUnban(message *tgbotapi.Message) {
+	parts := strings.Fields(message.CommandArguments())
+	target := h.moderationTarget(message, parts, 1, "moderation_unban_usage")
+	if target == nil {
+		return
+	}
+	if err := h.DB.SetBanned(target.TelegramID, false, ""); err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get("en", "moderation_error"))
+		return
+	}
+	log.Printf("INFO: Admin %d unbanned user %d", message.From.ID, target.TelegramID)
+	h.DB.RecordAudit(message.From.ID, database.AuditUnban, target.TelegramID, nil)
+	target.IsBanned, target.BanReason, target.SuspendedUntil = false, "", nil
+	h.applyModeration(message, target, "moderation_unbanned")
+}
+
+// handleSuspend menangani /suspend <id|@username> <durasi: 30m, 12h, 7d> [alasan].
+func (h *Handler) handleSuspend(message *tgbotapi.Message) {
+	parts := strings.Fields(message.CommandArguments())
+	target := h.moderationTarget(message, parts, 2, "moderation_suspend_usage")
+	if target == nil {
+		return
+	}
+	duration, err := parseModerationDuration(parts[1])
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get("en", "moderation_suspend_usage"))
+		return
+	}
+	until := time.Now().Add(duration)
+	reason := strings.Join(parts[2:], " ")
+	if err := h.DB.SetSuspendedUntil(target.TelegramID, until, reason); err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get("en", "moderation_error"))
+		return
+	}
+	log.Printf("INFO: Admin %d suspended user %d until %s (%s)", message.From.ID, target.TelegramID, until.UTC().Format(time.RFC3339), reason)
+	h.DB.RecordAudit(message.From.ID, database.AuditSuspend, target.TelegramID, map[string]interface{}{
+		"duration": parts[1],
+		"until":    until.UTC().Format(time.RFC3339),
+		"reason":   reason,
+	})
+	target.SuspendedUntil, target.BanReason = &until, reason
+	h.applyModeration(message, target, "moderation_suspended")
+}
+
+// applyModeration mengosongkan cache status, memberi tahu user target, lalu membalas admin.
+func (h *Handler) applyModeration(message *tgbotapi.Message, target *database.User, adminKey string) {
+	h.moderation.invalidate(target.TelegramID)
+
+	text := h.Localizer.Get(target.LanguageCode, "moderation_unbanned_notice")
+	if target.IsBlocked(time.Now()) {
+		text = h.blockedNotice(target)
+	}
+	notice := tgbotapi.NewMessage(target.TelegramID, text)
+	notice.ParseMode = "HTML"
+	h.Bot.Send(notice)
+
+	h.sendPromoAdminText(message, h.Localizer.Getf("en", adminKey, map[string]string{
+		"user": h.moderationUserLabel(target),
+	})+"\n\n"+h.moderationStatusText(target))
+}
+
+// parseModerationDuration menerima durasi Go (30m, 12h) ditambah hari (7d).
+func parseModerationDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %s", value)
+	}
+	return d, nil
+}
+
+func (h *Handler) moderationUserLabel(user *database.User) string {
+	label := fmt.Sprintf("<code>%d</code>", user.TelegramID)
+	if user.Username != "" {
+		label += " (@" + html.EscapeString(user.Username) + ")"
+	}
+	return label
+}
+
+func (h *Handler) moderationStatusText(user *database.User) string {
+	lang := "en"
+	reason := html.EscapeString(user.BanReason)
+	if reason == "" {
+		reason = h.Localizer.Get(lang, "moderation_no_reason")
+	}
+	switch {
+	case user.IsBanned:
+		return h.Localizer.Getf(lang, "moderation_status_banned", map[string]string{"reason": reason})
+	case user.IsSuspended(time.Now()):
+		return h.Localizer.Getf(lang, "moderation_status_suspended", map[string]string{
+			"until":  user.SuspendedUntil.UTC().Format("2006-01-02 15:04") + " UTC",
+			"reason": reason,
+		})
+	}
+	return h.Localizer.Get(lang, "moderation_status_active")
+}
+
+// handleUserLookup menangani /user <id|@username>: profil, saldo, generate dan pembayaran terakhir.
+func (h *Handler) handleUserLookup(message *tgbotapi.Message) {
+	lang := "en"
+	parts := strings.Fields(message.CommandArguments())
+	if len(parts) != 1 {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "moderation_user_usage"))
+		return
+	}
+	user, err := h.resolveUserArg(parts[0])
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "moderation_error"))
+		return
+	}
+	if user == nil {
+		h.sendPromoAdminText(message, h.Localizer.Getf(lang, "moderation_user_not_found", map[string]string{"user": html.EscapeString(parts[0])}))
+		return
+	}
+
+	tier := h.userTier(user).Name
+	if user.PremiumExpiresAt != nil && h.PaymentHandler.IsPremiumActive(user) {
+		tier += " (until " + user.PremiumExpiresAt.UTC().Format("2006-01-02") + ")"
+	}
+	referrer := "-"
+	if user.ReferrerID != 0 {
+		referrer = fmt.Sprintf("<code>%d</code>", user.ReferrerID)
+	}
+
+	var generations []string
+	if txs, err := h.DB.GetTransactionsByKinds(user.TelegramID, []string{database.TransactionGeneration, database.TransactionVideo}, moderationRecentRows); err == nil {
+		for _, tx := range txs {
+			generations = append(generations, h.formatHistoryLine(tx, lang))
+		}
+	}
+	if len(generations) == 0 {
+		generations = append(generations, "-")
+	}
+
+	var payments []string
+	if list, err := h.DB.GetUserPayments(user.TelegramID, moderationRecentRows); err == nil {
+		for _, p := range list {
+			payments = append(payments, fmt.Sprintf("<code>%s</code> %d %s → %d credits · %s\n<code>%s</code>",
+				formatHistoryTime(p.CreatedAt), p.Amount, p.Currency, p.Credits, p.Status, html.EscapeString(p.ChargeID)))
+		}
+	}
+	if len(payments) == 0 {
+		payments = append(payments, "-")
+	}
+
+	verified := "yes"
+	if !user.HumanVerified {
+		verified = "no"
+	}
+	text := h.Localizer.Getf(lang, "moderation_user_info", map[string]string{
+		"user":        h.moderationUserLabel(user),
+		"language":    user.LanguageCode,
+		"joined":      formatHistoryTime(user.CreatedAt),
+		"tier":        html.EscapeString(tier),
+		"verified":    verified,
+		"status":      h.moderationStatusText(user),
+		"referrer":    referrer,
+		"generated":   strconv.Itoa(user.GeneratedImageCount),
+		"balances":    h.walletBalanceLines(user, lang, "▸ {name}: <b>{balance}</b> {symbol}"),
+		"generations": strings.Join(generations, "\n"),
+		"payments":    strings.Join(payments, "\n"),
+	})
+	h.sendPromoAdminText(message, text)
+}
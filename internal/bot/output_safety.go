@@ -0,0 +1,178 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/safety"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// outputImage adalah gambar hasil generate yang lolos pemeriksaan output dan siap dikirim.
+type outputImage struct {
+	URL     string
+	Spoiler bool   // dikirim dengan has_spoiler
+	Blurred []byte // versi blur (JPEG); jika terisi, ini yang diunggah, bukan URL
+}
+
+// moderated bernilai true jika gambar tidak bisa dikirim lewat jalur biasa (FileURL tanpa spoiler).
+func (o outputImage) moderated() bool {
+	return o.Spoiler || o.Blurred != nil
+}
+
+func plainOutputImages(urls []string) []outputImage {
+	images := make([]outputImage, len(urls))
+	for i, url := range urls {
+		images[i] = outputImage{URL: url}
+	}
+	return images
+}
+
+// moderateOutputs memeriksa gambar hasil generate sebelum dikirim (bagian "output" di safety.json).
+// Gambar yang ditahan tidak ikut dikembalikan; jumlahnya dikembalikan sebagai withheld.
+// Semua gambar yang ditandai dicatat ke prompt_flags untuk ditinjau lewat /flags, tanpa strike.
+func (h *Handler) moderateOutputs(user *database.User, message *tgbotapi.Message, model *config.Model, prompt string, urls []string) (images []outputImage, withheld int) {
+	if h.Safety == nil || h.Safety.Images == nil {
+		return plainOutputImages(urls), 0
+	}
+	group := message.Chat.IsGroup() || message.Chat.IsSuperGroup()
+
+	type review struct {
+		action, category string
+	}
+	reviews := make([]review, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			action, category, err := h.Safety.ReviewOutput(context.Background(), url, group)
+			if err != nil {
+				log.Printf("WARN: Output moderation failed for user %d (%s), using %s: %v", user.TelegramID, model.ID, action, err)
+			}
+			reviews[i] = review{action: action, category: category}
+		}(i, url)
+	}
+	wg.Wait()
+
+	for i, url := range urls {
+		r := reviews[i]
+		if r.category != "" {
+			log.Printf("INFO: Output image from user %d (%s) flagged as %s, action: %s", user.TelegramID, model.ID, r.category, r.action)
+			h.DB.CreatePromptFlag(&database.PromptFlag{
+				TelegramID: user.TelegramID,
+				ModelID:    model.ID,
+				Prompt:     prompt,
+				Category:   r.category,
+				Source:     safety.SourceOutput,
+				Match:      r.action + " " + url,
+				Strike:     false,
+			})
+		}
+
+		switch r.action {
+		case safety.OutputWithhold:
+			withheld++
+		case safety.OutputBlur:
+			blurred, err := blurImageURL(url)
+			if err != nil {
+				// Format yang tidak bisa diblur (mis. WebP) tetap disembunyikan dengan spoiler
+				log.Printf("WARN: Could not blur output image, sending as spoiler: %v", err)
+				images = append(images, outputImage{URL: url, Spoiler: true})
+				continue
+			}
+			images = append(images, outputImage{URL: url, Blurred: blurred})
+		case safety.OutputSpoiler:
+			images = append(images, outputImage{URL: url, Spoiler: true})
+		default:
+			images = append(images, outputImage{URL: url})
+		}
+	}
+	return images, withheld
+}
+
+func blurImageURL(url string) ([]byte, error) {
+	client := http.Client{Timeout: time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download image: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return safety.Blur(data)
+}
+
+// spoilerMedia adalah InputMediaPhoto dengan has_spoiler, yang belum ada di tgbotapi v5.5.1.
+type spoilerMedia struct {
+	Type       string `json:"type"`
+	Media      string `json:"media"`
+	Caption    string `json:"caption,omitempty"`
+	ParseMode  string `json:"parse_mode,omitempty"`
+	HasSpoiler bool   `json:"has_spoiler,omitempty"`
+}
+
+// sendOutputImages mengirim gambar yang sudah dimoderasi. Request dibuat manual lewat UploadFiles
+// karena PhotoConfig/InputMediaPhoto di tgbotapi v5.5.1 belum mengenal has_spoiler.
+func (h *Handler) sendOutputImages(message *tgbotapi.Message, images []outputImage, caption string) {
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", message.Chat.ID)
+	if message.Chat.IsGroup() || message.Chat.IsSuperGroup() {
+		params.AddNonZero("reply_to_message_id", message.MessageID)
+	}
+
+	var files []tgbotapi.RequestFile
+	photoData := func(i int, img outputImage) (string, tgbotapi.RequestFileData) {
+		if img.Blurred != nil {
+			return fmt.Sprintf("blur%d", i), tgbotapi.FileBytes{Name: fmt.Sprintf("image-%d.jpg", i+1), Bytes: img.Blurred}
+		}
+		return "", tgbotapi.FileURL(img.URL)
+	}
+
+	endpoint := "sendPhoto"
+	if len(images) == 1 {
+		params.AddNonEmpty("caption", caption)
+		params.AddNonEmpty("parse_mode", "HTML")
+		params.AddBool("has_spoiler", images[0].Spoiler)
+		_, data := photoData(0, images[0])
+		files = append(files, tgbotapi.RequestFile{Name: "photo", Data: data})
+	} else {
+		endpoint = "sendMediaGroup"
+		media := make([]spoilerMedia, len(images))
+		for i, img := range images {
+			media[i] = spoilerMedia{Type: "photo", Media: img.URL, HasSpoiler: img.Spoiler}
+			if name, data := photoData(i, img); name != "" {
+				media[i].Media = "attach://" + name
+				files = append(files, tgbotapi.RequestFile{Name: name, Data: data})
+			}
+			if i == 0 {
+				media[i].Caption = caption
+				media[i].ParseMode = "HTML"
+			}
+		}
+		encoded, err := json.Marshal(media)
+		if err != nil {
+			log.Printf("ERROR: Failed to encode output media group: %v", err)
+			return
+		}
+		params["media"] = string(encoded)
+	}
+
+	if _, err := h.Bot.UploadFiles(endpoint, params, files); err != nil {
+		log.Printf("ERROR: Failed to send moderated output images to chat %d: %v", message.Chat.ID, err)
+	}
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/pricing"
+)
+
+// userCustomSettings membaca pengaturan parameter yang disimpan user (bisa kosong).
+func userCustomSettings(user *database.User) map[string]interface{} {
+	settings := make(map[string]interface{})
+	if user.CustomSettings != "" {
+		json.Unmarshal([]byte(user.CustomSettings), &settings)
+	}
+	return settings
+}
+
+// generationParams membangun parameter yang benar-benar dikirim ke Replicate.
+// Hanya parameter yang ada di models.json yang boleh masuk, nilai yang sudah tidak valid
+// diganti default. aspect_ratio dan num_outputs ditangani terpisah.
+func (h *Handler) generationParams(model *config.Model, raw map[string]interface{}) map[string]interface{} {
+	cleanParams := make(map[string]interface{})
+	for _, param := range model.Parameters {
+		if param.Name == "aspect_ratio" || param.Name == "num_outputs" {
+			continue
+		}
+
+		if val, exists := raw[param.Name]; exists && val != nil {
+			// Validasi ulang (skema models.json bisa berubah sejak nilai disimpan)
+			if errValidate := param.Validate(val); errValidate != nil {
+				log.Printf("WARN: Stored value %v for %s/%s is no longer valid, using default: %v", val, model.ID, param.Name, errValidate)
+				if param.Default != nil {
+					cleanParams[param.Name] = param.Default
+				}
+				continue
+			}
+			cleanParams[param.Name] = val
+		} else if param.Default != nil {
+			cleanParams[param.Name] = param.Default
+		}
+	}
+	return cleanParams
+}
+
+// generationNumOutputs adalah jumlah output yang akan diminta untuk model ini.
+func generationNumOutputs(user *database.User, model *config.Model) int {
+	if !model.ConfigurableNumOutputs || user.NumOutputs <= 0 {
+		return 1
+	}
+	return user.NumOutputs
+}
+
+// quoteGeneration menghitung biaya total generasi (credit untuk gambar, diamond untuk video)
+// setelah multiplier tier. Preview dashboard, cek saldo, dan pemotongan memakai fungsi ini
+// dengan parameter yang sama sehingga angkanya selalu cocok.
+func (h *Handler) quoteGeneration(user *database.User, model *config.Model, params map[string]interface{}, numOutputs, inputImages int) int {
+	in := pricing.Inputs{}
+	for name, value := range params {
+		in[name] = value
+	}
+	if model.ConfigurableAspectRatio {
+		in["aspect_ratio"] = user.AspectRatio
+	}
+	in[pricing.InputNumOutputs] = numOutputs
+	in[pricing.InputInputImages] = inputImages
+
+	rule := model.PricingRule()
+	return h.userTier(user).Price(rule.UnitCost(in)) * rule.Units(in)
+}
+
+// quoteFeature menghitung biaya fitur non-model (chat, prompt assistant) dari pricing.json.
+// Chat model yang terdaftar di models.json dengan "pricing" memakai aturannya sendiri.
+func (h *Handler) quoteFeature(user *database.User, feature, replicateID string, outputTokens int) int {
+	rule := h.Pricing.Rule(feature)
+	for _, m := range h.Models {
+		if m.Type == "chat_model" && m.ReplicateID == replicateID && m.Pricing != nil {
+			rule = *m.Pricing
+			break
+		}
+	}
+	in := pricing.Inputs{pricing.InputOutputTokens: outputTokens}
+	return h.userTier(user).Price(rule.UnitCost(in)) * rule.Units(in)
+}
+
+// dashboardCostLine menampilkan biaya generasi dengan pengaturan dashboard saat ini.
+func (h *Handler) dashboardCostLine(user *database.User, model *config.Model, customSettings map[string]interface{}, imageCount int) string {
+	cost := h.quoteGeneration(user, model, h.generationParams(model, customSettings), generationNumOutputs(user, model), imageCount)
+	unit := "💵"
+	if model.Type == "video" {
+		unit = "💎"
+	}
+	return fmt.Sprintf("• Cost: <code>%d %s</code>\n", cost, unit)
+}
@@ -0,0 +1,320 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/payments"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleRedeem menangani /redeem CODE.
+func (h *Handler) handleRedeem(message *tgbotapi.Message) {
+	user, err := h.getOrCreateUser(message.From)
+	if err != nil {
+		return
+	}
+	code := strings.TrimSpace(message.CommandArguments())
+	if code == "" {
+		msg := h.newReplyMessage(message, h.Localizer.Get(user.LanguageCode, "redeem_usage"))
+		msg.ParseMode = "HTML"
+		h.Bot.Send(msg)
+		return
+	}
+	h.redeemPromoCode(message.Chat.ID, user, code)
+	if !user.HumanVerified && message.Chat.IsPrivate() {
+		h.sendCaptcha(message.Chat.ID, user, "")
+	}
+}
+
+// redeemPromoCode dipakai oleh /redeem dan deep link /start promo_CODE. User yang belum lulus
+// tantangan anti-bot baru menerima hadiahnya setelah lulus, sama seperti bonus referral.
+func (h *Handler) redeemPromoCode(chatID int64, user *database.User, code string) {
+	lang := user.LanguageCode
+	if !user.HumanVerified {
+		h.captchas.deferPromo(user.TelegramID, code)
+		msg := tgbotapi.NewMessage(chatID, h.Localizer.Getf(lang, "promo_pending_captcha", map[string]string{"code": html.EscapeString(code)}))
+		msg.ParseMode = "HTML"
+		h.Bot.Send(msg)
+		return
+	}
+	redemption, err := h.PaymentHandler.RedeemPromo(user.TelegramID, code)
+	if err != nil {
+		text := h.Localizer.Get(lang, "promo_error_generic")
+		if promoErr, ok := err.(*payments.PromoError); ok {
+			text = h.Localizer.Getf(lang, promoErr.Key, promoErr.Args)
+		}
+		h.Bot.Send(tgbotapi.NewMessage(chatID, text))
+		return
+	}
+
+	args := map[string]string{
+		"code":   redemption.Code,
+		"amount": strconv.Itoa(redemption.RewardAmount),
+	}
+	msg := tgbotapi.NewMessage(chatID, h.Localizer.Getf(lang, "promo_success_"+redemption.RewardType, args))
+	msg.ParseMode = "HTML"
+	h.Bot.Send(msg)
+}
+
+// handlePromoAdmin menangani /promo untuk admin:
+//
+//	/promo create CODE credits|diamonds|bonus AMOUNT [max=N] [per_user=N] [expires=YYYY-MM-DD|Nd] [campaign=TAG]
+//	/promo disable CODE | /promo enable CODE
+//	/promo list [campaign]
+//	/promo stats CODE | /promo stats campaign=TAG
+func (h *Handler) handlePromoAdmin(message *tgbotapi.Message) {
+	lang := "en"
+	parts := strings.Fields(message.CommandArguments())
+	if len(parts) == 0 {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_admin_usage"))
+		return
+	}
+
+	switch strings.ToLower(parts[0]) {
+	case "create":
+		h.handlePromoCreate(message, parts[1:])
+	case "disable", "enable":
+		if len(parts) != 2 {
+			h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_admin_usage"))
+			return
+		}
+		code := payments.NormalizePromoCode(parts[1])
+		active := strings.ToLower(parts[0]) == "enable"
+		updated, err := h.DB.SetPromoCodeActive(code, active)
+		args := map[string]string{"code": code}
+		switch {
+		case err != nil:
+			h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_error_generic"))
+		case !updated:
+			h.sendPromoAdminText(message, h.Localizer.Getf(lang, "promo_admin_not_found", args))
+		case active:
+			h.DB.RecordAudit(message.From.ID, database.AuditPromoEnable, 0, map[string]interface{}{"code": code})
+			h.sendPromoAdminText(message, h.Localizer.Getf(lang, "promo_admin_enabled", args))
+		default:
+			h.DB.RecordAudit(message.From.ID, database.AuditPromoDisable, 0, map[string]interface{}{"code": code})
+			h.sendPromoAdminText(message, h.Localizer.Getf(lang, "promo_admin_disabled", args))
+		}
+	case "list":
+		campaign := ""
+		if len(parts) > 1 {
+			campaign = parts[1]
+		}
+		h.handlePromoList(message, campaign)
+	case "stats":
+		if len(parts) != 2 {
+			h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_admin_usage"))
+			return
+		}
+		h.handlePromoStats(message, parts[1])
+	default:
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_admin_usage"))
+	}
+}
+
+func (h *Handler) handlePromoCreate(message *tgbotapi.Message, parts []string) {
+	lang := "en"
+	if len(parts) < 3 {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_admin_usage"))
+		return
+	}
+	amount, err := strconv.Atoi(parts[2])
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_admin_invalid_amount"))
+		return
+	}
+
+	promo := &database.PromoCode{
+		Code:         payments.NormalizePromoCode(parts[0]),
+		RewardType:   strings.ToLower(parts[1]),
+		RewardAmount: amount,
+		PerUserLimit: 1,
+		Active:       true,
+		CreatedBy:    message.From.ID,
+	}
+	for _, option := range parts[3:] {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			h.sendPromoAdminText(message, h.Localizer.Getf(lang, "promo_admin_invalid_option", map[string]string{"option": option}))
+			return
+		}
+		var optErr error
+		switch strings.ToLower(key) {
+		case "max":
+			promo.MaxUses, optErr = strconv.Atoi(value)
+		case "per_user":
+			promo.PerUserLimit, optErr = strconv.Atoi(value)
+		case "expires":
+			var expiresAt time.Time
+			expiresAt, optErr = parsePromoExpiry(value)
+			promo.ExpiresAt = &expiresAt
+		case "campaign":
+			promo.Campaign = strings.ToLower(value)
+		default:
+			optErr = fmt.Errorf("unknown option %s", key)
+		}
+		if optErr != nil {
+			h.sendPromoAdminText(message, h.Localizer.Getf(lang, "promo_admin_invalid_option", map[string]string{"option": option}))
+			return
+		}
+	}
+
+	if err := payments.ValidatePromoCode(promo); err != nil {
+		text := h.Localizer.Get(lang, "promo_error_generic")
+		if promoErr, ok := err.(*payments.PromoError); ok {
+			text = h.Localizer.Getf(lang, promoErr.Key, promoErr.Args)
+		}
+		h.sendPromoAdminText(message, text)
+		return
+	}
+
+	created, err := h.DB.CreatePromoCode(promo)
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_error_generic"))
+		return
+	}
+	if !created {
+		h.sendPromoAdminText(message, h.Localizer.Getf(lang, "promo_admin_exists", map[string]string{"code": promo.Code}))
+		return
+	}
+	log.Printf("INFO: Admin %d created promo code %s (%d %s)", message.From.ID, promo.Code, promo.RewardAmount, promo.RewardType)
+	h.DB.RecordAudit(message.From.ID, database.AuditPromoCreate, 0, map[string]interface{}{
+		"code":     promo.Code,
+		"reward":   promo.RewardType,
+		"amount":   promo.RewardAmount,
+		"max_uses": promo.MaxUses,
+		"per_user": promo.PerUserLimit,
+		"campaign": promo.Campaign,
+	})
+
+	args := map[string]string{
+		"summary": formatPromoSummary(promo),
+		"link":    fmt.Sprintf("https://t.me/%s?start=promo_%s", h.Bot.Self.UserName, promo.Code),
+	}
+	h.sendPromoAdminText(message, h.Localizer.Getf(lang, "promo_admin_created", args))
+}
+
+func (h *Handler) handlePromoList(message *tgbotapi.Message, campaign string) {
+	lang := "en"
+	promos, err := h.DB.ListPromoCodes(strings.ToLower(campaign), 20)
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_error_generic"))
+		return
+	}
+	if len(promos) == 0 {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_admin_list_empty"))
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(h.Localizer.Get(lang, "promo_admin_list_header"))
+	for i := range promos {
+		b.WriteString("\n\n")
+		b.WriteString(formatPromoSummary(&promos[i]))
+	}
+	h.sendPromoAdminText(message, b.String())
+}
+
+func (h *Handler) handlePromoStats(message *tgbotapi.Message, target string) {
+	lang := "en"
+	var code, campaign string
+	if value, ok := strings.CutPrefix(target, "campaign="); ok {
+		campaign = strings.ToLower(value)
+	} else {
+		code = payments.NormalizePromoCode(target)
+	}
+
+	redemptions, err := h.DB.GetPromoRedemptions(code, campaign)
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "promo_error_generic"))
+		return
+	}
+
+	users := make(map[int64]bool)
+	var credits, diamonds, bonusPending, bonusUsed int
+	for _, r := range redemptions {
+		users[r.TelegramID] = true
+		switch r.RewardType {
+		case database.PromoRewardCredits:
+			credits += r.RewardAmount
+		case database.PromoRewardDiamonds:
+			diamonds += r.RewardAmount
+		case database.PromoRewardBonus:
+			if r.ConsumedAt != nil {
+				bonusUsed++
+			} else {
+				bonusPending++
+			}
+		}
+	}
+
+	name := code
+	if campaign != "" {
+		name = "campaign " + campaign
+	}
+	args := map[string]string{
+		"target":        html.EscapeString(name),
+		"redemptions":   strconv.Itoa(len(redemptions)),
+		"users":         strconv.Itoa(len(users)),
+		"credits":       strconv.Itoa(credits),
+		"diamonds":      strconv.Itoa(diamonds),
+		"bonus_used":    strconv.Itoa(bonusUsed),
+		"bonus_pending": strconv.Itoa(bonusPending),
+	}
+	h.sendPromoAdminText(message, h.Localizer.Getf(lang, "promo_admin_stats", args))
+}
+
+func (h *Handler) sendPromoAdminText(message *tgbotapi.Message, text string) {
+	msg := h.newReplyMessage(message, text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	h.Bot.Send(msg)
+}
+
+// parsePromoExpiry menerima tanggal (YYYY-MM-DD, berlaku sampai akhir hari UTC) atau durasi hari (misal 30d).
+func parsePromoExpiry(value string) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return time.Time{}, fmt.Errorf("invalid duration %s", value)
+		}
+		return time.Now().UTC().AddDate(0, 0, n), nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return date.Add(24*time.Hour - time.Second), nil
+}
+
+func formatPromoSummary(promo *database.PromoCode) string {
+	reward := fmt.Sprintf("%d %s", promo.RewardAmount, promo.RewardType)
+	if promo.RewardType == database.PromoRewardBonus {
+		reward = fmt.Sprintf("+%d%% on next Stars purchase", promo.RewardAmount)
+	}
+	uses := strconv.Itoa(promo.Uses)
+	if promo.MaxUses > 0 {
+		uses += "/" + strconv.Itoa(promo.MaxUses)
+	}
+	status := "active"
+	if !promo.Active {
+		status = "disabled"
+	} else if promo.ExpiresAt != nil && time.Now().After(*promo.ExpiresAt) {
+		status = "expired"
+	}
+
+	line := fmt.Sprintf("<code>%s</code> — %s\nUses: %s, per user: %d, status: %s", promo.Code, reward, uses, promo.PerUserLimit, status)
+	if promo.ExpiresAt != nil {
+		line += "\nExpires: " + promo.ExpiresAt.UTC().Format("2006-01-02 15:04 UTC")
+	}
+	if promo.Campaign != "" {
+		line += "\nCampaign: " + html.EscapeString(promo.Campaign)
+	}
+	return line
+}
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"telegram-ai-bot/internal/database" // <-- PENTING: Import database ditambahkan
+	"telegram-ai-bot/internal/pricing"
+	"telegram-ai-bot/internal/wallet"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -124,7 +126,8 @@ func (h *Handler) handlePromptMethodCallback(callback *tgbotapi.CallbackQuery, m
 	}
 	userIdea := pending.Prompt
 
-	if !h.checkAndDeductCredits(user, callback.Message.Chat.ID, lang, 2) {
+	cost := h.quoteFeature(user, pricing.FeaturePromptText, "", 0)
+	if !h.checkAndDeductCredits(user, callback.Message.Chat.ID, lang, cost) {
 		return
 	}
 
@@ -134,8 +137,8 @@ func (h *Handler) handlePromptMethodCallback(callback *tgbotapi.CallbackQuery, m
 
 	go func() {
 		// Panggil Logic Text Generator
-		success := h.processTextToPrompt(user.TelegramID, callback.Message.Chat.ID, userIdea, method, lang)
-		h.finalizePromptProcess(user, success, 2)
+		success := h.processTextToPrompt(user.TelegramID, callback.Message.Chat.ID, userIdea, method, lang, cost)
+		h.finalizePromptProcess(user, success, cost)
 	}()
 }
 
@@ -155,8 +158,9 @@ func (h *Handler) handlePromptImageInput(message *tgbotapi.Message) {
 		return
 	}
 
-	// 2. Cek Kredit (Biaya: 2)
-	if !h.checkAndDeductCredits(user, message.Chat.ID, lang, 2) {
+	// 2. Cek Kredit (biaya dari pricing.json)
+	cost := h.quoteFeature(user, pricing.FeaturePromptImage, "", 0)
+	if !h.checkAndDeductCredits(user, message.Chat.ID, lang, cost) {
 		return
 	}
 
@@ -171,12 +175,12 @@ func (h *Handler) handlePromptImageInput(message *tgbotapi.Message) {
 
 	// 4. Proses Background
 	go func() {
-		success := h.processImageToPrompt(message.Chat.ID, imageURL, lang)
-		h.finalizePromptProcess(user, success, 2)
+		success := h.processImageToPrompt(message.Chat.ID, imageURL, lang, cost)
+		h.finalizePromptProcess(user, success, cost)
 	}()
 }
 
-func (h *Handler) processImageToPrompt(chatID int64, imageURL, lang string) bool {
+func (h *Handler) processImageToPrompt(chatID int64, imageURL, lang string, cost int) bool {
 	replicateModelPath := "google/gemini-2.5-flash"
 	
 	prompt := "Describe this image as a highly detailed text-to-image prompt (English). " +
@@ -201,7 +205,7 @@ func (h *Handler) processImageToPrompt(chatID int64, imageURL, lang string) bool
 	htmlResult := h.formatMarkdownToHTML(resultText)
 	
 	title := "🖼️ <b>Image Decoded (Reverse Prompt)</b>"
-	costInfo := fmt.Sprintf(h.Localizer.Get(lang, "prompt_gen_cost_info"), 2)
+	costInfo := fmt.Sprintf(h.Localizer.Get(lang, "prompt_gen_cost_info"), cost)
 
 	finalResponse := fmt.Sprintf("%s\n%s\n\n%s", title, costInfo, htmlResult)
 
@@ -217,7 +221,7 @@ func (h *Handler) processImageToPrompt(chatID int64, imageURL, lang string) bool
 // ---------------------------------------------------------
 
 // Helper: Proses Text-to-Prompt (Gemini Text)
-func (h *Handler) processTextToPrompt(userID, chatID int64, idea, method, lang string) bool {
+func (h *Handler) processTextToPrompt(userID, chatID int64, idea, method, lang string, cost int) bool {
 	replicateModelPath := "google/gemini-2.5-flash"
 
 	baseInstruction := "You are an expert AI Prompt Engineer. Convert user idea into TWO professional prompts. " +
@@ -248,7 +252,7 @@ func (h *Handler) processTextToPrompt(userID, chatID int64, idea, method, lang s
 	htmlResult := h.formatMarkdownToHTML(resultText)
 	titleBase := h.Localizer.Getf(lang, "prompt_gen_result_title", map[string]string{})
 	titleFormatted := fmt.Sprintf(strings.Replace(titleBase, "%s", "%s", 1), strings.ToUpper(method))
-	costInfo := fmt.Sprintf(h.Localizer.Get(lang, "prompt_gen_cost_info"), 2)
+	costInfo := fmt.Sprintf(h.Localizer.Get(lang, "prompt_gen_cost_info"), cost)
 	safeIdea := html.EscapeString(idea)
 
 	finalResponse := fmt.Sprintf("%s\nIdea: <i>%s</i>\n%s\n\n%s", titleFormatted, safeIdea, costInfo, htmlResult)
@@ -271,29 +275,22 @@ func (h *Handler) checkAndDeductCredits(user *database.User, chatID int64, lang
 		h.Bot.Send(failMsg)
 		return false
 	}
+	if text := h.spendLimitText(user, wallet.CreditsPool, cost); text != "" {
+		failMsg := tgbotapi.NewMessage(chatID, text)
+		failMsg.ParseMode = "HTML"
+		h.Bot.Send(failMsg)
+		return false
+	}
 	return true
 }
 
 // Helper: Commit pengurangan kredit & bersihkan state
 func (h *Handler) finalizePromptProcess(user *database.User, success bool, cost int) {
 	if success {
-		// Refresh user dari DB
-		u, _ := h.DB.GetUserByTelegramID(user.TelegramID)
-		if u != nil {
-			costLeft := cost
-			if u.FreeCredits > 0 {
-				if u.FreeCredits >= costLeft {
-					u.FreeCredits -= costLeft
-					costLeft = 0
-				} else {
-					costLeft -= u.FreeCredits
-					u.FreeCredits = 0
-				}
-			}
-			if costLeft > 0 {
-				u.PaidCredits -= costLeft
-			}
-			h.DB.UpdateUser(u)
+		// Dipotong dari saldo terbaru di database (free dulu, lalu paid)
+		if charged, err := h.Wallet.Charge(h.DB, user, wallet.CreditsPool, cost); err == nil {
+			h.DB.Record(user.TelegramID, database.TransactionPrompt, wallet.CreditsPool, -charged, "Prompt assistant")
+			h.afterSpend(user)
 		}
 	}
 
@@ -0,0 +1,254 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/safety"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	flagsPageSize     = 15
+	providerCategory  = "provider_nsfw" // kategori untuk penolakan filter NSFW model
+	flagPromptPreview = 200
+)
+
+// screenPrompt memeriksa prompt dengan safety.json sebelum generate. false berarti prompt diblokir
+// dan user sudah diberi tahu. Prompt kosong (remove background, upscaler) tidak diperiksa.
+func (h *Handler) screenPrompt(user *database.User, message *tgbotapi.Message, model *config.Model, prompt string) bool {
+	if h.Safety == nil {
+		return true
+	}
+	verdict := h.Safety.Check(context.Background(), prompt, user.LanguageCode, model.ID)
+	if verdict == nil {
+		return true
+	}
+	log.Printf("INFO: Blocked prompt from user %d for model %s (%s via %s: %s)", user.TelegramID, model.ID, verdict.Category, verdict.Source, verdict.Match)
+	h.flagPrompt(user, message, model, prompt, verdict, true, "prompt_blocked")
+	return false
+}
+
+// handleProviderRejection menangani prediksi yang ditolak filter NSFW model di Replicate: user mendapat
+// pesan yang jelas (kredit tidak terpotong) dan prompt dicatat untuk ditinjau admin.
+func (h *Handler) handleProviderRejection(user *database.User, message *tgbotapi.Message, model *config.Model, prompt string, err error) bool {
+	if !safety.IsProviderRejection(err) {
+		return false
+	}
+	log.Printf("INFO: Replicate rejected prompt from user %d for model %s as NSFW", user.TelegramID, model.ID)
+	strike := h.Safety != nil && h.Safety.Policy.Enabled && h.Safety.Policy.ProviderRejectionsAreStrikes
+	verdict := &safety.Verdict{Category: providerCategory, Source: safety.SourceProvider, Match: truncateRunes(err.Error(), flagPromptPreview)}
+	h.flagPrompt(user, message, model, prompt, verdict, strike, "generation_failed_nsfw")
+	return true
+}
+
+// flagPrompt mencatat prompt ke prompt_flags, menerapkan tangga strike jika strike bernilai true, lalu membalas user.
+func (h *Handler) flagPrompt(user *database.User, message *tgbotapi.Message, model *config.Model, prompt string, verdict *safety.Verdict, strike bool, textKey string) {
+	lang := user.LanguageCode
+	flag, err := h.DB.CreatePromptFlag(&database.PromptFlag{
+		TelegramID: user.TelegramID,
+		ModelID:    model.ID,
+		Prompt:     prompt,
+		Category:   verdict.Category,
+		Source:     verdict.Source,
+		Match:      verdict.Match,
+		Strike:     strike,
+	})
+
+	text := h.Localizer.Getf(lang, textKey, map[string]string{"category": h.promptCategoryLabel(verdict.Category, lang)})
+	if strike && err == nil && flag != nil {
+		if notice := h.applyStrikes(user); notice != "" {
+			text += "\n\n" + notice
+		}
+	}
+	msg := h.newReplyMessage(message, text)
+	msg.ParseMode = "HTML"
+	h.Bot.Send(msg)
+}
+
+// applyStrikes menghitung strike user dalam jendela safety.json dan menjalankan langkah yang sudah tercapai.
+// Mengembalikan teks tambahan untuk user. Staf hanya dicatat, tidak pernah disuspend/diban otomatis.
+func (h *Handler) applyStrikes(user *database.User) string {
+	lang := user.LanguageCode
+	policy := h.Safety.Policy
+	strikes, err := h.DB.CountStrikes(user.TelegramID, time.Now().AddDate(0, 0, -policy.Strikes.WindowDays))
+	if err != nil {
+		return ""
+	}
+	args := map[string]string{"strikes": strconv.Itoa(strikes), "days": strconv.Itoa(policy.Strikes.WindowDays)}
+	step := policy.StepFor(strikes)
+	if step == nil || h.isAdmin(user.TelegramID) {
+		return h.Localizer.Getf(lang, "prompt_strike_count", args)
+	}
+
+	reason := fmt.Sprintf("repeated blocked prompts (%d strikes)", strikes)
+	switch step.Action {
+	case safety.StrikeSuspend:
+		until := time.Now().Add(step.SuspendFor())
+		if user.SuspendedUntil != nil && user.SuspendedUntil.After(until) {
+			// Jangan memperpendek suspend yang diberikan admin
+			return h.Localizer.Getf(lang, "prompt_strike_warning", args)
+		}
+		if err := h.DB.SetSuspendedUntil(user.TelegramID, until, reason); err != nil {
+			return h.Localizer.Getf(lang, "prompt_strike_warning", args)
+		}
+		log.Printf("INFO: Suspended user %d until %s after %d prompt strikes", user.TelegramID, until.UTC().Format(time.RFC3339), strikes)
+		h.DB.RecordAudit(0, database.AuditSuspend, user.TelegramID, map[string]interface{}{
+			"duration": step.Duration,
+			"until":    until.UTC().Format(time.RFC3339),
+			"reason":   reason,
+			"strikes":  strikes,
+		})
+		user.SuspendedUntil, user.BanReason = &until, reason
+	case safety.StrikeBan:
+		if err := h.DB.SetBanned(user.TelegramID, true, reason); err != nil {
+			return h.Localizer.Getf(lang, "prompt_strike_warning", args)
+		}
+		log.Printf("INFO: Banned user %d after %d prompt strikes", user.TelegramID, strikes)
+		h.DB.RecordAudit(0, database.AuditBan, user.TelegramID, map[string]interface{}{"reason": reason, "strikes": strikes})
+		user.IsBanned, user.BanReason = true, reason
+	default:
+		return h.Localizer.Getf(lang, "prompt_strike_warning", args)
+	}
+	h.moderation.invalidate(user.TelegramID)
+	return h.blockedNotice(user)
+}
+
+func (h *Handler) promptCategoryLabel(category, lang string) string {
+	key := "prompt_category_" + category
+	if label := h.Localizer.Get(lang, key); label != key {
+		return label
+	}
+	return html.EscapeString(category)
+}
+
+// handleFlags menangani /flags untuk staf:
+//
+//	/flags                      prompt yang belum ditinjau
+//	/flags user <id|@username>  semua flag milik satu user beserta jumlah strike
+//	/flags confirm <id>         blokir benar, strike tetap dihitung
+//	/flags dismiss <id>         salah blokir, strike dihapus
+func (h *Handler) handleFlags(message *tgbotapi.Message) {
+	lang := "en"
+	parts := strings.Fields(message.CommandArguments())
+	if len(parts) == 0 {
+		h.sendFlagList(message, 0, database.FlagPending)
+		return
+	}
+
+	switch strings.ToLower(parts[0]) {
+	case "user":
+		if len(parts) != 2 {
+			h.sendPromoAdminText(message, h.Localizer.Get(lang, "flags_usage"))
+			return
+		}
+		user, err := h.resolveUserArg(parts[1])
+		if err != nil {
+			h.sendPromoAdminText(message, h.Localizer.Get(lang, "flags_error"))
+			return
+		}
+		if user == nil {
+			h.sendPromoAdminText(message, h.Localizer.Getf(lang, "moderation_user_not_found", map[string]string{"user": html.EscapeString(parts[1])}))
+			return
+		}
+		h.sendFlagList(message, user.TelegramID, "")
+	case "confirm", "dismiss":
+		if len(parts) != 2 {
+			h.sendPromoAdminText(message, h.Localizer.Get(lang, "flags_usage"))
+			return
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(parts[1], "#"), 10, 64)
+		if err != nil {
+			h.sendPromoAdminText(message, h.Localizer.Get(lang, "flags_usage"))
+			return
+		}
+		status, action := database.FlagConfirmed, database.AuditFlagConfirm
+		if strings.ToLower(parts[0]) == "dismiss" {
+			status, action = database.FlagDismissed, database.AuditFlagDismiss
+		}
+		flag, err := h.DB.ReviewPromptFlag(id, status, message.From.ID)
+		if err != nil {
+			h.sendPromoAdminText(message, h.Localizer.Get(lang, "flags_error"))
+			return
+		}
+		if flag == nil {
+			h.sendPromoAdminText(message, h.Localizer.Getf(lang, "flags_not_found", map[string]string{"id": strconv.FormatInt(id, 10)}))
+			return
+		}
+		log.Printf("INFO: Admin %d marked prompt flag %d as %s", message.From.ID, id, status)
+		h.DB.RecordAudit(message.From.ID, action, flag.TelegramID, map[string]interface{}{
+			"flag_id":  id,
+			"category": flag.Category,
+			"source":   flag.Source,
+		})
+		h.sendPromoAdminText(message, h.Localizer.Getf(lang, "flags_reviewed", map[string]string{
+			"id":     strconv.FormatInt(id, 10),
+			"status": status,
+		})+"\n\n"+formatFlagLine(*flag))
+	default:
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "flags_usage"))
+	}
+}
+
+// sendFlagList menampilkan flag terbaru. telegramID 0 = semua user.
+func (h *Handler) sendFlagList(message *tgbotapi.Message, telegramID int64, status string) {
+	lang := "en"
+	flags, total, err := h.DB.GetPromptFlags(telegramID, status, flagsPageSize)
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "flags_error"))
+		return
+	}
+	if total == 0 {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "flags_empty"))
+		return
+	}
+
+	lines := make([]string, len(flags))
+	for i, flag := range flags {
+		lines[i] = formatFlagLine(flag)
+	}
+	title := h.Localizer.Getf(lang, "flags_pending_title", map[string]string{"total": strconv.Itoa(total)})
+	if telegramID != 0 {
+		strikes := "-"
+		if h.Safety != nil {
+			if n, err := h.DB.CountStrikes(telegramID, time.Now().AddDate(0, 0, -h.Safety.Policy.Strikes.WindowDays)); err == nil {
+				strikes = strconv.Itoa(n)
+			}
+		}
+		title = h.Localizer.Getf(lang, "flags_user_title", map[string]string{
+			"user":    fmt.Sprintf("<code>%d</code>", telegramID),
+			"total":   strconv.Itoa(total),
+			"strikes": strikes,
+		})
+	}
+	h.sendPromoAdminText(message, title+"\n\n"+strings.Join(lines, "\n\n")+"\n\n"+h.Localizer.Get(lang, "flags_footer"))
+}
+
+func formatFlagLine(flag database.PromptFlag) string {
+	line := fmt.Sprintf("<b>#%d</b> <code>%s</code> · user <code>%d</code> · %s\n<b>%s</b> via %s · %s",
+		flag.ID, formatHistoryTime(flag.CreatedAt), flag.TelegramID, html.EscapeString(flag.ModelID),
+		html.EscapeString(flag.Category), html.EscapeString(flag.Source), html.EscapeString(flag.Status))
+	if !flag.Strike {
+		line += " · no strike"
+	}
+	if flag.Match != "" {
+		line += "\nmatch: <code>" + html.EscapeString(truncateRunes(flag.Match, 160)) + "</code>"
+	}
+	return line + "\n<i>" + html.EscapeString(truncateRunes(flag.Prompt, flagPromptPreview)) + "</i>"
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
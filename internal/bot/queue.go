@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// generationQueue membatasi jumlah prediksi Replicate yang berjalan bersamaan.
+// Jika semua slot terpakai, permintaan menunggu dan dilayani berdasarkan
+// prioritas tier user (lebih besar duluan), lalu urutan kedatangan.
+type generationQueue struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	seq     uint64
+	waiting ticketHeap
+}
+
+type queueTicket struct {
+	priority int
+	seq      uint64
+	ready    chan struct{}
+	index    int
+}
+
+func newGenerationQueue(limit int) *generationQueue {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &generationQueue{limit: limit}
+}
+
+// Acquire menunggu sampai ada slot kosong lalu mengembalikan fungsi release.
+// onQueued dipanggil (sekali) dengan posisi antrean jika user harus menunggu.
+func (q *generationQueue) Acquire(priority int, onQueued func(position int)) func() {
+	q.mu.Lock()
+	if q.running < q.limit && q.waiting.Len() == 0 {
+		q.running++
+		q.mu.Unlock()
+		return q.releaseFunc()
+	}
+
+	q.seq++
+	ticket := &queueTicket{priority: priority, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiting, ticket)
+	position := q.positionOf(ticket)
+	q.mu.Unlock()
+
+	if onQueued != nil {
+		onQueued(position)
+	}
+	<-ticket.ready
+	return q.releaseFunc()
+}
+
+func (q *generationQueue) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(q.release)
+	}
+}
+
+func (q *generationQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.waiting.Len() > 0 {
+		// Slot langsung diberikan ke antrean teratas, jumlah running tidak berubah
+		next := heap.Pop(&q.waiting).(*queueTicket)
+		close(next.ready)
+		return
+	}
+	q.running--
+}
+
+// positionOf menghitung posisi (mulai dari 1) sebuah tiket di antrean. Harus dipanggil dengan mu terkunci.
+func (q *generationQueue) positionOf(ticket *queueTicket) int {
+	position := 1
+	for _, t := range q.waiting {
+		if t != ticket && t.before(ticket) {
+			position++
+		}
+	}
+	return position
+}
+
+func (t *queueTicket) before(other *queueTicket) bool {
+	if t.priority != other.priority {
+		return t.priority > other.priority
+	}
+	return t.seq < other.seq
+}
+
+type ticketHeap []*queueTicket
+
+func (th ticketHeap) Len() int           { return len(th) }
+func (th ticketHeap) Less(i, j int) bool { return th[i].before(th[j]) }
+func (th ticketHeap) Swap(i, j int) {
+	th[i], th[j] = th[j], th[i]
+	th[i].index = i
+	th[j].index = j
+}
+
+func (th *ticketHeap) Push(x interface{}) {
+	ticket := x.(*queueTicket)
+	ticket.index = len(*th)
+	*th = append(*th, ticket)
+}
+
+func (th *ticketHeap) Pop() interface{} {
+	old := *th
+	n := len(old)
+	ticket := old[n-1]
+	old[n-1] = nil
+	*th = old[:n-1]
+	return ticket
+}
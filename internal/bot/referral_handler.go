@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/referral"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// referralRecentSize adalah jumlah undangan terbaru yang ditampilkan di dashboard.
+const referralRecentSize = 5
+
+// handleReferral menampilkan dashboard referral: aturan hadiah, statistik, undangan terbaru dan link.
+func (h *Handler) handleReferral(message *tgbotapi.Message) {
+	user, err := h.getOrCreateUser(message.From)
+	if err != nil {
+		return
+	}
+	lang := user.LanguageCode
+	referralLink := fmt.Sprintf("https://t.me/%s?start=ref_%d", h.Bot.Self.UserName, user.TelegramID)
+
+	stats, err := h.Referrals.Stats(user.TelegramID, referralRecentSize)
+	if err != nil {
+		msg := h.newReplyMessage(message, h.Localizer.Get(lang, "referral_error"))
+		h.Bot.Send(msg)
+		return
+	}
+
+	text := h.Localizer.Getf(lang, "referral_dashboard", map[string]string{
+		"rules":     h.referralRulesText(lang),
+		"invited":   strconv.Itoa(stats.Invited),
+		"active":    strconv.Itoa(stats.Active),
+		"converted": strconv.Itoa(stats.Converted),
+		"earnings":  h.referralEarningsText(stats.Earnings),
+		"recent":    h.referralRecentText(lang, stats.Recent),
+		"link_text": h.Localizer.Get(lang, "referral_link_text"),
+		"link":      referralLink,
+	})
+	shareText := url.QueryEscape(h.Localizer.Getf(lang, "referral_share_text", map[string]string{"link": referralLink}))
+	shareURL := fmt.Sprintf("https://t.me/share/url?url=%s", shareText)
+
+	msg := h.newReplyMessage(message, text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	keyboard := h.createReferralKeyboard(lang, shareURL)
+	msg.ReplyMarkup = &keyboard
+	h.Bot.Send(msg)
+}
+
+func (h *Handler) referralRulesText(lang string) string {
+	rules := h.Referrals.Rules
+	var lines []string
+	if rules.SignupBonus.Amount > 0 {
+		lines = append(lines, h.Localizer.Getf(lang, "referral_rule_signup", map[string]string{
+			"amount": strconv.Itoa(rules.SignupBonus.Amount),
+			"symbol": h.Wallet.Symbol(rules.SignupBonus.Currency),
+		}))
+	}
+	for _, m := range rules.Milestones {
+		lines = append(lines, h.Localizer.Getf(lang, "referral_rule_milestone", map[string]string{
+			"amount":      strconv.Itoa(m.Reward.Amount),
+			"symbol":      h.Wallet.Symbol(m.Reward.Currency),
+			"generations": strconv.Itoa(m.Generations),
+		}))
+	}
+	if rules.CommissionPercent > 0 {
+		lines = append(lines, h.Localizer.Getf(lang, "referral_rule_commission", map[string]string{
+			"percent": strconv.Itoa(rules.CommissionPercent),
+			"symbol":  h.Wallet.Symbol(wallet.PaidCredits),
+		}))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (h *Handler) referralEarningsText(earnings map[string]int) string {
+	if len(earnings) == 0 {
+		return "0 " + h.Wallet.Symbol(wallet.PaidCredits)
+	}
+	currencies := make([]string, 0, len(earnings))
+	for currency := range earnings {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	parts := make([]string, len(currencies))
+	for i, currency := range currencies {
+		parts[i] = fmt.Sprintf("%d %s", earnings[currency], h.Wallet.Symbol(currency))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (h *Handler) referralRecentText(lang string, referees []database.User) string {
+	if len(referees) == 0 {
+		return ""
+	}
+	lines := make([]string, len(referees))
+	for i := range referees {
+		lines[i] = fmt.Sprintf("• %s · <code>%s</code>", referral.MaskName(&referees[i]), formatHistoryTime(referees[i].CreatedAt))
+	}
+	return h.Localizer.Getf(lang, "referral_recent", map[string]string{"lines": strings.Join(lines, "\n")})
+}
+
+// handleReferralLeaderboard menampilkan referrer dengan referee aktif terbanyak.
+func (h *Handler) handleReferralLeaderboard(callback *tgbotapi.CallbackQuery) {
+	user, err := h.getOrCreateUser(callback.From)
+	if err != nil {
+		return
+	}
+	lang := user.LanguageCode
+
+	entries, err := h.Referrals.Leaderboard()
+	var text string
+	switch {
+	case err != nil:
+		text = h.Localizer.Get(lang, "referral_error")
+	case len(entries) == 0:
+		text = h.Localizer.Get(lang, "referral_leaderboard_empty")
+	default:
+		medals := []string{"🥇", "🥈", "🥉"}
+		lines := make([]string, len(entries))
+		for i, e := range entries {
+			rank := strconv.Itoa(i+1) + "."
+			if i < len(medals) {
+				rank = medals[i]
+			}
+			name := e.Name
+			if e.TelegramID == user.TelegramID {
+				name = "<b>" + h.Localizer.Get(lang, "referral_leaderboard_you") + "</b>"
+			}
+			lines[i] = fmt.Sprintf("%s %s · %d", rank, name, e.Active)
+		}
+		text = h.Localizer.Getf(lang, "referral_leaderboard", map[string]string{"lines": strings.Join(lines, "\n")})
+	}
+
+	msg := tgbotapi.NewMessage(callback.Message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	h.Bot.Send(msg)
+}
+
+// handleReferralAdmin menangani /referrals untuk admin:
+//
+//	/referrals flagged
+//	/referrals user REFERRER_ID
+//	/referrals release|deny|reverse REWARD_ID
+//	/referrals reverseall|clear REFERRER_ID
+func (h *Handler) handleReferralAdmin(message *tgbotapi.Message) {
+	lang := "en"
+	parts := strings.Fields(strings.ToLower(message.CommandArguments()))
+	if len(parts) == 1 && parts[0] == "flagged" {
+		h.sendPromoAdminText(message, h.referralFlagsText())
+		return
+	}
+	if len(parts) != 2 {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "referral_admin_usage"))
+		return
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "referral_admin_usage"))
+		return
+	}
+
+	args := map[string]string{"id": parts[1]}
+	// Melepas, menolak dan menarik hadiah memindahkan saldo
+	if parts[0] != "user" && parts[0] != "clear" && !h.can(message.From.ID, permCredits) {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "permission_denied"))
+		return
+	}
+	var text string
+	switch parts[0] {
+	case "user":
+		text = h.referralRewardsText(id)
+	case "release", "deny", "reverse":
+		var reward *database.ReferralReward
+		var auditAction string
+		var taken int
+		switch parts[0] {
+		case "release":
+			reward, err = h.Referrals.Release(id)
+			auditAction = database.AuditReferralRelease
+		case "deny":
+			reward, err = h.Referrals.Deny(id)
+			auditAction = database.AuditReferralDeny
+		case "reverse":
+			auditAction = database.AuditReferralReverse
+			reward, taken, err = h.Referrals.Reverse(id)
+			args["taken"] = strconv.Itoa(taken)
+		}
+		switch {
+		case err == referral.ErrRewardNotFound:
+			text = h.Localizer.Getf(lang, "referral_admin_not_found", args)
+		case err == referral.ErrRewardStatus:
+			text = h.Localizer.Getf(lang, "referral_admin_wrong_status", args)
+		case err != nil:
+			text = h.Localizer.Get(lang, "referral_admin_error")
+		default:
+			params := map[string]interface{}{"reward_id": reward.ID, "amount": reward.Amount, "currency": reward.Currency}
+			if parts[0] == "reverse" {
+				params["taken"] = taken
+			}
+			h.DB.RecordAudit(message.From.ID, auditAction, reward.BeneficiaryID, params)
+			args["line"] = h.referralRewardLine(*reward)
+			text = h.Localizer.Getf(lang, "referral_admin_done_"+parts[0], args)
+		}
+	case "reverseall":
+		count, taken, err := h.Referrals.ReverseAll(id)
+		if err != nil {
+			text = h.Localizer.Get(lang, "referral_admin_error")
+			break
+		}
+		h.DB.RecordAudit(message.From.ID, database.AuditReferralRevAll, id, map[string]interface{}{"count": count, "taken": taken})
+		args["count"] = strconv.Itoa(count)
+		args["taken"] = h.referralEarningsText(taken)
+		text = h.Localizer.Getf(lang, "referral_admin_done_reverseall", args)
+	case "clear":
+		cleared, err := h.DB.ClearReferralFlag(id)
+		switch {
+		case err != nil:
+			text = h.Localizer.Get(lang, "referral_admin_error")
+		case !cleared:
+			text = h.Localizer.Getf(lang, "referral_admin_not_flagged", args)
+		default:
+			h.DB.RecordAudit(message.From.ID, database.AuditReferralClear, id, nil)
+			text = h.Localizer.Getf(lang, "referral_admin_done_clear", args)
+		}
+	default:
+		text = h.Localizer.Get(lang, "referral_admin_usage")
+	}
+	h.sendPromoAdminText(message, text)
+}
+
+func (h *Handler) referralFlagsText() string {
+	lang := "en"
+	flags, err := h.DB.GetReferralFlags()
+	if err != nil {
+		return h.Localizer.Get(lang, "referral_admin_error")
+	}
+	if len(flags) == 0 {
+		return h.Localizer.Get(lang, "referral_admin_no_flags")
+	}
+	lines := make([]string, len(flags))
+	for i, f := range flags {
+		lines[i] = fmt.Sprintf("• <code>%d</code> · score %d · %s · %s", f.ReferrerID, f.Score, html.EscapeString(f.Reasons), formatHistoryTime(f.CreatedAt))
+	}
+	return h.Localizer.Getf(lang, "referral_admin_flags", map[string]string{"lines": strings.Join(lines, "\n")})
+}
+
+func (h *Handler) referralRewardsText(referrerID int64) string {
+	lang := "en"
+	rewards, err := h.DB.GetReferralRewardsByReferrer(referrerID, "", 30)
+	if err != nil {
+		return h.Localizer.Get(lang, "referral_admin_error")
+	}
+	args := map[string]string{"id": strconv.FormatInt(referrerID, 10)}
+	if len(rewards) == 0 {
+		return h.Localizer.Getf(lang, "referral_admin_no_rewards", args)
+	}
+	lines := make([]string, len(rewards))
+	for i, r := range rewards {
+		lines[i] = h.referralRewardLine(r)
+	}
+	args["lines"] = strings.Join(lines, "\n")
+	return h.Localizer.Getf(lang, "referral_admin_rewards", args)
+}
+
+func (h *Handler) referralRewardLine(r database.ReferralReward) string {
+	line := fmt.Sprintf("#%d %s · referee <code>%d</code> → <code>%d</code> · %d %s · <b>%s</b>", r.ID, r.Kind, r.RefereeID, r.BeneficiaryID, r.Amount, h.Wallet.Symbol(r.Currency), r.Status)
+	if r.RiskReasons != "" {
+		line += fmt.Sprintf(" · risk %d (%s)", r.RiskScore, html.EscapeString(r.RiskReasons))
+	}
+	return line
+}
@@ -0,0 +1,230 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// permission adalah hak untuk satu kelompok perintah admin.
+type permission string
+
+const (
+	permStats     permission = "stats"     // /stats
+	permLookup    permission = "lookup"    // /user
+	permModerate  permission = "moderate"  // /ban, /unban, /suspend, /flags
+	permCredits   permission = "credits"   // /addcredits, /refund, transfer manual, hadiah referral
+	permBroadcast permission = "broadcast" // /broadcast, /broadcastgroup
+	permPromo     permission = "promo"     // /promo
+	permReferrals permission = "referrals" // /referrals (lihat & hapus tanda fraud)
+	permAudit     permission = "audit"     // /audit
+	permRoles     permission = "roles"     // /grant, /revoke
+)
+
+// rolePermissions menentukan izin tiap peran. Owner selalu punya semua izin.
+var rolePermissions = map[string][]permission{
+	database.RoleAdmin:   {permStats, permLookup, permModerate, permCredits, permBroadcast, permPromo, permReferrals, permAudit},
+	database.RoleSupport: {permStats, permLookup, permModerate},
+	database.RoleFinance: {permStats, permLookup, permCredits, permPromo, permReferrals, permAudit},
+}
+
+// adminCommands adalah perintah khusus staf beserta izin yang dibutuhkan.
+var adminCommands = map[string]permission{
+	"stats":          permStats,
+	"user":           permLookup,
+	"ban":            permModerate,
+	"unban":          permModerate,
+	"suspend":        permModerate,
+	"flags":          permModerate,
+	"addcredits":     permCredits,
+	"refund":         permCredits,
+	"broadcast":      permBroadcast,
+	"broadcastgroup": permBroadcast,
+	"promo":          permPromo,
+	"referrals":      permReferrals,
+	"audit":          permAudit,
+	"grant":          permRoles,
+	"revoke":         permRoles,
+}
+
+const roleCacheTTL = time.Minute
+
+// roleStore menyimpan sementara isi tabel admin_roles agar setiap update tidak perlu query.
+type roleStore struct {
+	mu       sync.Mutex
+	roles    map[int64]string
+	loadedAt time.Time
+}
+
+func newRoleStore() *roleStore {
+	return &roleStore{}
+}
+
+func (s *roleStore) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedAt = time.Time{}
+}
+
+// isOwnerFromConfig memeriksa ADMIN_TELEGRAM_IDS. ID ini selalu owner dan tidak bisa dicabut lewat bot.
+func (h *Handler) isOwnerFromConfig(userID int64) bool {
+	for _, adminID := range h.Config.AdminTelegramIDs {
+		if userID == adminID {
+			return true
+		}
+	}
+	return false
+}
+
+// roleOf mengembalikan peran staf user, "" jika bukan staf.
+func (h *Handler) roleOf(userID int64) string {
+	if h.isOwnerFromConfig(userID) {
+		return database.RoleOwner
+	}
+	s := h.roles
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.roles == nil || time.Since(s.loadedAt) > roleCacheTTL {
+		roles, err := h.DB.GetAdminRoles()
+		if err != nil {
+			// Pakai isi cache terakhir saat database bermasalah
+			return s.roles[userID]
+		}
+		s.roles = make(map[int64]string, len(roles))
+		for _, r := range roles {
+			s.roles[r.TelegramID] = r.Role
+		}
+		s.loadedAt = time.Now()
+	}
+	return s.roles[userID]
+}
+
+// can memeriksa apakah user punya izin perm lewat perannya.
+func (h *Handler) can(userID int64, perm permission) bool {
+	role := h.roleOf(userID)
+	if role == database.RoleOwner {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGrant menangani /grant <id|@username> <role>. Tanpa argumen menampilkan daftar staf.
+func (h *Handler) handleGrant(message *tgbotapi.Message) {
+	lang := "en"
+	parts := strings.Fields(message.CommandArguments())
+	if len(parts) == 0 {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "roles_usage")+"\n\n"+h.staffListText())
+		return
+	}
+	if len(parts) != 2 || !database.ValidRole(strings.ToLower(parts[1])) {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "roles_usage"))
+		return
+	}
+	role := strings.ToLower(parts[1])
+	target := h.roleTarget(message, parts[0])
+	if target == nil {
+		return
+	}
+
+	err := h.DB.SetAdminRole(&database.AdminRole{TelegramID: target.TelegramID, Role: role, GrantedBy: message.From.ID})
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "roles_error"))
+		return
+	}
+	h.roles.invalidate()
+	log.Printf("INFO: Admin %d granted role %s to user %d", message.From.ID, role, target.TelegramID)
+	h.DB.RecordAudit(message.From.ID, database.AuditGrant, target.TelegramID, map[string]interface{}{"role": role})
+
+	args := map[string]string{"user": h.moderationUserLabel(target), "role": role}
+	notice := tgbotapi.NewMessage(target.TelegramID, h.Localizer.Getf(lang, "roles_granted_notice", args))
+	notice.ParseMode = "HTML"
+	h.Bot.Send(notice)
+	h.sendPromoAdminText(message, h.Localizer.Getf(lang, "roles_granted", args))
+}
+
+// handleRevoke menangani /revoke <id|@username>.
+func (h *Handler) handleRevoke(message *tgbotapi.Message) {
+	lang := "en"
+	parts := strings.Fields(message.CommandArguments())
+	if len(parts) != 1 {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "roles_usage"))
+		return
+	}
+	target := h.roleTarget(message, parts[0])
+	if target == nil {
+		return
+	}
+
+	role := h.roleOf(target.TelegramID)
+	deleted, err := h.DB.DeleteAdminRole(target.TelegramID)
+	args := map[string]string{"user": h.moderationUserLabel(target), "role": role}
+	switch {
+	case err != nil:
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "roles_error"))
+		return
+	case !deleted:
+		h.sendPromoAdminText(message, h.Localizer.Getf(lang, "roles_not_staff", args))
+		return
+	}
+	h.roles.invalidate()
+	log.Printf("INFO: Admin %d revoked role %s from user %d", message.From.ID, role, target.TelegramID)
+	h.DB.RecordAudit(message.From.ID, database.AuditRevoke, target.TelegramID, map[string]interface{}{"role": role})
+
+	notice := tgbotapi.NewMessage(target.TelegramID, h.Localizer.Getf(lang, "roles_revoked_notice", args))
+	notice.ParseMode = "HTML"
+	h.Bot.Send(notice)
+	h.sendPromoAdminText(message, h.Localizer.Getf(lang, "roles_revoked", args))
+}
+
+// roleTarget membaca user target /grant dan /revoke. Owner dari ADMIN_TELEGRAM_IDS dan diri sendiri
+// tidak bisa diubah agar bot tidak kehilangan owner.
+func (h *Handler) roleTarget(message *tgbotapi.Message, arg string) *database.User {
+	lang := "en"
+	target, err := h.resolveUserArg(arg)
+	if err != nil {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "roles_error"))
+		return nil
+	}
+	if target == nil {
+		h.sendPromoAdminText(message, h.Localizer.Getf(lang, "moderation_user_not_found", map[string]string{"user": html.EscapeString(arg)}))
+		return nil
+	}
+	if target.TelegramID == message.From.ID || h.isOwnerFromConfig(target.TelegramID) {
+		h.sendPromoAdminText(message, h.Localizer.Get(lang, "roles_protected"))
+		return nil
+	}
+	return target
+}
+
+func (h *Handler) staffListText() string {
+	lang := "en"
+	roles, err := h.DB.GetAdminRoles()
+	if err != nil {
+		return h.Localizer.Get(lang, "roles_error")
+	}
+	lines := make([]string, 0, len(h.Config.AdminTelegramIDs)+len(roles))
+	for _, id := range h.Config.AdminTelegramIDs {
+		lines = append(lines, fmt.Sprintf("• <code>%d</code> · <b>%s</b> · ADMIN_TELEGRAM_IDS", id, database.RoleOwner))
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].TelegramID < roles[j].TelegramID })
+	for _, r := range roles {
+		if h.isOwnerFromConfig(r.TelegramID) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("• <code>%d</code> · <b>%s</b> · granted by <code>%d</code> · %s", r.TelegramID, r.Role, r.GrantedBy, formatHistoryTime(r.CreatedAt)))
+	}
+	return h.Localizer.Getf(lang, "roles_staff", map[string]string{"lines": strings.Join(lines, "\n")})
+}
@@ -0,0 +1,277 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// spendConfirmTimeout adalah batas waktu tombol konfirmasi generate mahal.
+const spendConfirmTimeout = 5 * time.Minute
+
+// generationConfirmed ditambahkan ke argumen triggerImageGeneration setelah user
+// menekan tombol konfirmasi, agar konfirmasi tidak diminta dua kali.
+type generationConfirmed struct{}
+
+// pendingSpend adalah generate yang menunggu konfirmasi karena biayanya melewati batas user.
+type pendingSpend struct {
+	ChatID    int64
+	MessageID int
+	ExpiresAt time.Time
+	Run       func(user *database.User)
+}
+
+type spendConfirmStore struct {
+	mu      sync.Mutex
+	pending map[int64]*pendingSpend
+}
+
+func newSpendConfirmStore() *spendConfirmStore {
+	return &spendConfirmStore{pending: make(map[int64]*pendingSpend)}
+}
+
+func (s *spendConfirmStore) put(userID int64, spend *pendingSpend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[userID] = spend
+}
+
+func (s *spendConfirmStore) take(userID int64, chatID int64, messageID int) *pendingSpend {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	spend, ok := s.pending[userID]
+	if !ok || spend.ChatID != chatID || spend.MessageID != messageID {
+		return nil
+	}
+	delete(s.pending, userID)
+	if time.Now().After(spend.ExpiresAt) {
+		return nil
+	}
+	return spend
+}
+
+// spendLimitText memeriksa batas harian/mingguan user untuk belanja dalam currency (atau pool) tersebut.
+// Mengembalikan pesan penolakan, atau string kosong jika biaya masih boleh dipakai.
+func (h *Handler) spendLimitText(user *database.User, currency string, cost int) string {
+	limits := user.SpendLimits
+	if cost <= 0 || (limits.Daily == 0 && limits.Weekly == 0) {
+		return ""
+	}
+
+	lang := user.LanguageCode
+	checks := []struct {
+		limit  int
+		window time.Duration
+		period string
+	}{
+		{limits.Daily, 24 * time.Hour, "limits_period_daily"},
+		{limits.Weekly, 7 * 24 * time.Hour, "limits_period_weekly"},
+	}
+	for _, check := range checks {
+		if check.limit == 0 {
+			continue
+		}
+		spent, err := h.DB.SumSpent(user.TelegramID, currency, time.Now().Add(-check.window))
+		if err != nil {
+			// Riwayat tidak terbaca: jangan blokir user karena masalah database
+			return ""
+		}
+		if spent+cost > check.limit {
+			return h.Localizer.Getf(lang, "spend_limit_reached", map[string]string{
+				"period":   h.Localizer.Get(lang, check.period),
+				"limit":    strconv.Itoa(check.limit),
+				"spent":    strconv.Itoa(spent),
+				"cost":     strconv.Itoa(cost),
+				"currency": h.Wallet.DisplayName(currency, lang),
+			})
+		}
+	}
+	return ""
+}
+
+// confirmSpend meminta konfirmasi jika biaya generate >= batas konfirmasi user.
+// Mengembalikan true jika generate boleh langsung jalan.
+func (h *Handler) confirmSpend(user *database.User, originalMessage *tgbotapi.Message, currency string, cost int, run func(user *database.User)) bool {
+	threshold := user.SpendLimits.ConfirmAbove
+	if threshold == 0 || cost < threshold {
+		return true
+	}
+
+	lang := user.LanguageCode
+	text := h.Localizer.Getf(lang, "spend_confirm", map[string]string{
+		"cost":     strconv.Itoa(cost),
+		"balance":  strconv.Itoa(h.Wallet.Balance(user, currency)),
+		"currency": h.Wallet.DisplayName(currency, lang),
+	})
+	msg := h.newReplyMessage(originalMessage, text)
+	msg.ParseMode = "HTML"
+	keyboard := h.createSpendConfirmKeyboard(lang)
+	msg.ReplyMarkup = &keyboard
+	sent, err := h.Bot.Send(msg)
+	if err != nil {
+		return false
+	}
+	h.pendingSpends.put(user.TelegramID, &pendingSpend{
+		ChatID:    sent.Chat.ID,
+		MessageID: sent.MessageID,
+		ExpiresAt: time.Now().Add(spendConfirmTimeout),
+		Run:       run,
+	})
+	return false
+}
+
+// handleSpendCallback menangani tombol spend_confirm / spend_cancel.
+func (h *Handler) handleSpendCallback(callback *tgbotapi.CallbackQuery, confirmed bool) {
+	user, err := h.getOrCreateUser(callback.From)
+	if err != nil {
+		return
+	}
+	lang := user.LanguageCode
+	chatID, messageID := callback.Message.Chat.ID, callback.Message.MessageID
+
+	spend := h.pendingSpends.take(user.TelegramID, chatID, messageID)
+	if spend == nil {
+		original := callback.Message.ReplyToMessage
+		if original == nil || original.From == nil || original.From.ID == user.TelegramID {
+			h.Bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, h.Localizer.Get(lang, "spend_confirm_expired")))
+		}
+		return
+	}
+
+	h.Bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID))
+	if confirmed {
+		spend.Run(user)
+	}
+}
+
+// lowBalanceThreshold adalah batas notifikasi saldo rendah milik user (atau default config).
+func (h *Handler) lowBalanceThreshold(user *database.User) int {
+	if user.SpendLimits.LowBalance != nil {
+		return *user.SpendLimits.LowBalance
+	}
+	return h.Config.LowBalanceThreshold
+}
+
+// afterSpend dipanggil setelah kredit user berkurang. Notifikasi saldo rendah dikirim sekali
+// saat saldo turun di bawah batas, dan diaktifkan lagi setelah saldo naik kembali.
+func (h *Handler) afterSpend(user *database.User) {
+	threshold := h.lowBalanceThreshold(user)
+	balance := h.Wallet.Balance(user, wallet.CreditsPool)
+	limits := user.SpendLimits
+
+	if threshold == 0 || balance >= threshold {
+		if limits.LowNotified {
+			limits.LowNotified = false
+			if h.DB.SetSpendLimits(user.TelegramID, limits) == nil {
+				user.SpendLimits = limits
+			}
+		}
+		return
+	}
+	if limits.LowNotified {
+		return
+	}
+
+	limits.LowNotified = true
+	if h.DB.SetSpendLimits(user.TelegramID, limits) != nil {
+		return
+	}
+	user.SpendLimits = limits
+
+	lang := user.LanguageCode
+	msg := tgbotapi.NewMessage(user.TelegramID, h.Localizer.Getf(lang, "low_balance_notification", map[string]string{
+		"balance": strconv.Itoa(balance),
+	}))
+	msg.ParseMode = "HTML"
+	keyboard := h.createLowBalanceKeyboard(lang)
+	msg.ReplyMarkup = &keyboard
+	h.Bot.Send(msg)
+}
+
+// handleLimits menangani /limits:
+//
+//	/limits
+//	/limits daily|weekly|confirm|lowbalance N|off
+func (h *Handler) handleLimits(message *tgbotapi.Message) {
+	user, err := h.getOrCreateUser(message.From)
+	if err != nil {
+		return
+	}
+	lang := user.LanguageCode
+
+	args := strings.Fields(strings.ToLower(message.CommandArguments()))
+	if len(args) == 0 {
+		h.sendLimitsText(message, h.limitsStatusText(user))
+		return
+	}
+	if len(args) != 2 {
+		h.sendLimitsText(message, h.Localizer.Get(lang, "limits_usage"))
+		return
+	}
+
+	value := 0
+	if args[1] != "off" {
+		value, err = strconv.Atoi(args[1])
+		if err != nil || value <= 0 {
+			h.sendLimitsText(message, h.Localizer.Get(lang, "limits_invalid"))
+			return
+		}
+	}
+
+	limits := user.SpendLimits
+	switch args[0] {
+	case "daily":
+		limits.Daily = value
+	case "weekly":
+		limits.Weekly = value
+	case "confirm":
+		limits.ConfirmAbove = value
+	case "lowbalance":
+		limits.LowBalance = &value
+		limits.LowNotified = false
+	default:
+		h.sendLimitsText(message, h.Localizer.Get(lang, "limits_usage"))
+		return
+	}
+
+	if err := h.DB.SetSpendLimits(user.TelegramID, limits); err != nil {
+		h.sendLimitsText(message, h.Localizer.Get(lang, "limits_error"))
+		return
+	}
+	user.SpendLimits = limits
+	h.sendLimitsText(message, h.Localizer.Get(lang, "limits_updated")+"\n\n"+h.limitsStatusText(user))
+}
+
+func (h *Handler) limitsStatusText(user *database.User) string {
+	lang := user.LanguageCode
+	limits := user.SpendLimits
+	format := func(value int) string {
+		if value == 0 {
+			return h.Localizer.Get(lang, "limits_off")
+		}
+		return strconv.Itoa(value)
+	}
+
+	spentToday, _ := h.DB.SumSpent(user.TelegramID, wallet.CreditsPool, time.Now().Add(-24*time.Hour))
+	spentWeek, _ := h.DB.SumSpent(user.TelegramID, wallet.CreditsPool, time.Now().Add(-7*24*time.Hour))
+	return h.Localizer.Getf(lang, "limits_status", map[string]string{
+		"daily":       format(limits.Daily),
+		"weekly":      format(limits.Weekly),
+		"confirm":     format(limits.ConfirmAbove),
+		"low_balance": format(h.lowBalanceThreshold(user)),
+		"spent_today": strconv.Itoa(spentToday),
+		"spent_week":  strconv.Itoa(spentWeek),
+	})
+}
+
+func (h *Handler) sendLimitsText(message *tgbotapi.Message, text string) {
+	msg := h.newReplyMessage(message, text)
+	msg.ParseMode = "HTML"
+	h.Bot.Send(msg)
+}
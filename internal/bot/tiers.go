@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// userTier mengembalikan aturan tier (harga, free credit harian, akses model) untuk user.
+func (h *Handler) userTier(user *database.User) config.UserTier {
+	return h.Tiers.ForUser(h.PaymentHandler.IsPremiumActive(user))
+}
+
+// canUseModel memeriksa apakah tier user boleh memakai model ini.
+func (h *Handler) canUseModel(user *database.User, model *config.Model) bool {
+	return h.userTier(user).CanUse(model.Tier)
+}
+
+// modelCost adalah biaya satu output dengan parameter default, setelah multiplier tier user: credit
+// untuk model gambar, diamond untuk model video. Dipakai untuk label tombol dan fitur tanpa
+// pengaturan (remove bg, upscaler).
+func (h *Handler) modelCost(user *database.User, model *config.Model) int {
+	return h.quoteGeneration(user, model, h.generationParams(model, nil), 1, 0)
+}
+
+// acquireGenerationSlot menunggu slot di antrean generate. Jika user harus antre,
+// pesan "sedang generate" (waitMessageID) diubah menjadi info posisi antrean.
+func (h *Handler) acquireGenerationSlot(user *database.User, chatID int64, waitMessageID int, generatingText string) func() {
+	lang := user.LanguageCode
+	queued := false
+	release := h.generationQueue.Acquire(h.userTier(user).QueuePriority, func(position int) {
+		queued = true
+		text := h.Localizer.Getf(lang, "generation_queued", map[string]string{"position": strconv.Itoa(position)})
+		h.Bot.Send(tgbotapi.NewEditMessageText(chatID, waitMessageID, text))
+	})
+	if queued {
+		h.Bot.Send(tgbotapi.NewEditMessageText(chatID, waitMessageID, generatingText))
+	}
+	return release
+}
+
+// showPremiumUpsell menjelaskan kenapa model terkunci dan apa keuntungan Premium.
+func (h *Handler) showPremiumUpsell(chatID int64, user *database.User, model *config.Model) {
+	lang := user.LanguageCode
+	premium := h.Tiers.Premium()
+	free := h.Tiers.ForUser(false)
+
+	var benefits []string
+	benefits = append(benefits, h.Localizer.Get(lang, "premium_benefit_models"))
+	if premium.PriceMultiplier < free.PriceMultiplier {
+		discount := int((1 - premium.PriceMultiplier/free.PriceMultiplier) * 100)
+		benefits = append(benefits, h.Localizer.Getf(lang, "premium_benefit_discount", map[string]string{"discount": strconv.Itoa(discount)}))
+	}
+	premiumCredits := h.FreeCredits.ForTier(premium).Amount
+	if premiumCredits > h.FreeCredits.ForTier(free).Amount {
+		benefits = append(benefits, h.Localizer.Getf(lang, "premium_benefit_daily", map[string]string{"credits": strconv.Itoa(premiumCredits)}))
+	}
+	if premium.QueuePriority > free.QueuePriority {
+		benefits = append(benefits, h.Localizer.Get(lang, "premium_benefit_queue"))
+	}
+
+	args := map[string]string{
+		"model":    html.EscapeString(model.Name),
+		"benefits": "• " + strings.Join(benefits, "\n• "),
+	}
+	text := h.Localizer.Getf(lang, "premium_model_locked", args)
+
+	keyboard := h.createPremiumUpsellKeyboard(lang)
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = &keyboard
+	h.Bot.Send(msg)
+}
+
+// modelButtonText membuat label tombol model dengan harga sesuai tier user.
+func (h *Handler) modelButtonText(user *database.User, model *config.Model) string {
+	if !h.canUseModel(user, model) {
+		return fmt.Sprintf("🔒 %s", model.Name)
+	}
+	if model.Type == "video" {
+		return fmt.Sprintf("%s (%d 💎)", model.Name, h.modelCost(user, model))
+	}
+	return fmt.Sprintf("%s (%d 💵)", model.Name, h.modelCost(user, model))
+}
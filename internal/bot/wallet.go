@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// walletBalanceLines membuat daftar saldo dari wallet.json, satu baris per currency.
+// format memakai placeholder {name}, {balance} dan {symbol}.
+func (h *Handler) walletBalanceLines(user *database.User, lang, format string) string {
+	var lines []string
+	for _, cur := range h.Wallet.Currencies {
+		if cur.Hidden {
+			continue
+		}
+		line := strings.NewReplacer(
+			"{name}", h.Wallet.DisplayName(cur.ID, lang),
+			"{balance}", strconv.Itoa(h.Wallet.Balance(user, cur.ID)),
+			"{symbol}", cur.Symbol,
+		).Replace(format)
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// exchangeArgs berisi placeholder umum untuk pesan exchange_*.
+func (h *Handler) exchangeArgs(user *database.User, lang string, ex *wallet.Exchange) map[string]string {
+	return map[string]string{
+		"from_name":   h.Wallet.DisplayName(ex.From, lang),
+		"from_symbol": h.Wallet.Symbol(ex.From),
+		"to_name":     h.Wallet.DisplayName(ex.To, lang),
+		"to_symbol":   h.Wallet.Symbol(ex.To),
+		"rate": strconv.Itoa(ex.FromAmount) + " " + h.Wallet.DisplayName(ex.From, lang) + " = " +
+			strconv.Itoa(ex.ToAmount) + " " + h.Wallet.DisplayName(ex.To, lang),
+		"step":     strconv.Itoa(ex.Step()),
+		"balances": h.walletBalanceLines(user, lang, "▸ {balance} {name} {symbol}"),
+		// Placeholder lama (terjemahan yang belum diperbarui)
+		"credits":  strconv.Itoa(h.Wallet.Balance(user, wallet.CreditsPool)),
+		"diamonds": strconv.Itoa(user.Diamonds),
+	}
+}
+
+// startExchange meminta user memasukkan jumlah yang ingin diterima untuk satu arah penukaran.
+func (h *Handler) startExchange(chatID int64, user *database.User, ex *wallet.Exchange) {
+	lang := user.LanguageCode
+
+	h.userStatesMutex.Lock()
+	h.userStates[user.TelegramID] = "awaiting_exchange_amount:" + ex.ID
+	h.userStatesMutex.Unlock()
+
+	msg := tgbotapi.NewMessage(chatID, h.Localizer.Getf(lang, "exchange_prompt", h.exchangeArgs(user, lang, ex)))
+	msg.ParseMode = "HTML"
+	keyboard := h.createCancelFlowKeyboard(lang)
+	msg.ReplyMarkup = &keyboard
+	h.Bot.Send(msg)
+}
+
+// handleExchangeAmount memproses jumlah yang diketik user pada state awaiting_exchange_amount[:id].
+func (h *Handler) handleExchangeAmount(message *tgbotapi.Message, user *database.User, state string) {
+	lang := user.LanguageCode
+
+	var ex *wallet.Exchange
+	if id := strings.TrimPrefix(state, "awaiting_exchange_amount:"); id != state {
+		ex = h.Wallet.FindExchange(id)
+	} else if enabled := h.Wallet.EnabledExchanges(); len(enabled) > 0 {
+		// State lama tanpa ID: pakai arah penukaran pertama
+		ex = &enabled[0]
+	}
+	if ex == nil {
+		h.userStatesMutex.Lock()
+		delete(h.userStates, user.TelegramID)
+		h.userStatesMutex.Unlock()
+		h.Bot.Send(h.newReplyMessage(message, h.Localizer.Get(lang, "exchange_unavailable")))
+		return
+	}
+
+	args := h.exchangeArgs(user, lang, ex)
+	amount, err := strconv.Atoi(strings.TrimSpace(message.Text))
+	if err != nil || amount <= 0 {
+		h.Bot.Send(h.newReplyMessage(message, h.Localizer.Get(lang, "exchange_invalid_amount")))
+		return
+	}
+	if amount%ex.Step() != 0 {
+		h.Bot.Send(h.newReplyMessage(message, h.Localizer.Getf(lang, "exchange_invalid_multiple", args)))
+		return
+	}
+
+	spent, err := h.Wallet.Apply(h.DB, user, ex, amount)
+	if err != nil {
+		if insufficient, ok := err.(*wallet.InsufficientError); ok {
+			args["amount"] = strconv.Itoa(amount)
+			args["required"] = strconv.Itoa(insufficient.Required)
+			args["balance"] = strconv.Itoa(insufficient.Balance)
+			args["diamonds_to_buy"] = args["amount"]
+			args["credits_needed"] = args["required"]
+			args["credits_balance"] = args["balance"]
+			h.Bot.Send(h.newReplyMessage(message, h.Localizer.Getf(lang, "exchange_not_enough_credits", args)))
+			return
+		}
+		h.Bot.Send(h.newReplyMessage(message, h.Localizer.Get(lang, "exchange_invalid_amount")))
+		return
+	}
+	h.DB.Record(user.TelegramID, database.TransactionExchange, ex.From, -spent, ex.ID)
+	h.DB.Record(user.TelegramID, database.TransactionExchange, ex.To, amount, ex.ID)
+
+	h.userStatesMutex.Lock()
+	delete(h.userStates, user.TelegramID)
+	h.userStatesMutex.Unlock()
+
+	args["spent"] = strconv.Itoa(spent)
+	args["received"] = strconv.Itoa(amount)
+	args["to_balance"] = strconv.Itoa(h.Wallet.Balance(user, ex.To))
+	args["credits_spent"] = args["spent"]
+	args["diamonds_gained"] = args["received"]
+	args["new_diamonds_balance"] = args["to_balance"]
+	h.Bot.Send(h.newReplyMessage(message, h.Localizer.Getf(lang, "exchange_success", args)))
+}
@@ -0,0 +1,164 @@
+// Package captcha membuat tantangan anti-bot sederhana untuk onboarding user baru:
+// memilih emoji yang diminta dari tombol, atau membaca angka dari gambar yang dirender lokal.
+package captcha
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+)
+
+// Challenge adalah satu pertanyaan dengan pilihan jawaban teracak. Answer adalah indeks jawaban benar di Options.
+type Challenge struct {
+	Target  string   // emoji yang harus dipilih (mode tombol)
+	Image   []byte   // PNG berisi kode angka (mode gambar)
+	Options []string // label tombol
+	Answer  int
+}
+
+var emojis = []string{"🍎", "🍌", "🍇", "🍉", "🍒", "🥕", "🌽", "🍄", "🐶", "🐱", "🐸", "🐼", "🚗", "✈️", "⚽", "🎸", "🌙", "⭐"}
+
+const buttonOptions = 6
+
+// NewButtons membuat tantangan "tekan tombol emoji X".
+func NewButtons() *Challenge {
+	picked := rand.Perm(len(emojis))[:buttonOptions]
+	options := make([]string, buttonOptions)
+	for i, idx := range picked {
+		options[i] = emojis[idx]
+	}
+	answer := rand.Intn(buttonOptions)
+	return &Challenge{Target: options[answer], Options: options, Answer: answer}
+}
+
+const (
+	codeDigits   = 4
+	imageOptions = 4
+	imageWidth   = 220
+	imageHeight  = 80
+	glyphScale   = 7
+)
+
+// NewImage membuat tantangan "pilih angka yang terlihat di gambar".
+func NewImage() (*Challenge, error) {
+	seen := make(map[string]bool)
+	options := make([]string, 0, imageOptions)
+	for len(options) < imageOptions {
+		code := fmt.Sprintf("%0*d", codeDigits, rand.Intn(10000))
+		if !seen[code] {
+			seen[code] = true
+			options = append(options, code)
+		}
+	}
+	answer := rand.Intn(imageOptions)
+
+	img, err := render(options[answer])
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{Image: img, Options: options, Answer: answer}, nil
+}
+
+// glyphs adalah font bitmap 5x7 untuk angka 0-9.
+var glyphs = [10][7]string{
+	{"01110", "10001", "10011", "10101", "11001", "10001", "01110"},
+	{"00100", "01100", "00100", "00100", "00100", "00100", "01110"},
+	{"01110", "10001", "00001", "00010", "00100", "01000", "11111"},
+	{"11111", "00010", "00100", "00010", "00001", "10001", "01110"},
+	{"00010", "00110", "01010", "10010", "11111", "00010", "00010"},
+	{"11111", "10000", "11110", "00001", "00001", "10001", "01110"},
+	{"00110", "01000", "10000", "11110", "10001", "10001", "01110"},
+	{"11111", "00001", "00010", "00100", "01000", "01000", "01000"},
+	{"01110", "10001", "10001", "01110", "10001", "10001", "01110"},
+	{"01110", "10001", "10001", "01111", "00001", "00010", "01100"},
+}
+
+// render menggambar kode dengan posisi, warna dan kemiringan acak di atas latar berbintik dan garis pengganggu.
+func render(code string) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, imageWidth, imageHeight))
+	for y := 0; y < imageHeight; y++ {
+		for x := 0; x < imageWidth; x++ {
+			shade := uint8(215 + rand.Intn(40))
+			img.Set(x, y, color.RGBA{shade, shade, uint8(200 + rand.Intn(55)), 255})
+		}
+	}
+
+	slot := imageWidth / (len(code) + 1)
+	for i, ch := range code {
+		digit := int(ch - '0')
+		ink := randomInk()
+		originX := slot/2 + i*slot + rand.Intn(9) - 4
+		originY := (imageHeight-7*glyphScale)/2 + rand.Intn(11) - 5
+		slant := rand.Float64()*0.5 - 0.25
+		for row, bits := range glyphs[digit] {
+			shift := int(slant * float64((3-row)*glyphScale))
+			for col, bit := range bits {
+				if bit != '1' {
+					continue
+				}
+				x0 := originX + col*glyphScale + shift
+				y0 := originY + row*glyphScale
+				for dy := 0; dy < glyphScale-1; dy++ {
+					for dx := 0; dx < glyphScale-1; dx++ {
+						img.Set(x0+dx, y0+dy, ink)
+					}
+				}
+			}
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		drawLine(img, rand.Intn(imageWidth), rand.Intn(imageHeight), rand.Intn(imageWidth), rand.Intn(imageHeight), randomInk())
+	}
+	for i := 0; i < 400; i++ {
+		img.Set(rand.Intn(imageWidth), rand.Intn(imageHeight), randomInk())
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func randomInk() color.RGBA {
+	return color.RGBA{uint8(rand.Intn(120)), uint8(rand.Intn(120)), uint8(rand.Intn(120)), 255}
+}
+
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		img.Set(x0, y0+1, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
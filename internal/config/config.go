@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 
+	"telegram-ai-bot/internal/pricing"
+
 	"github.com/joho/godotenv"
 )
 
@@ -48,17 +50,48 @@ type Config struct {
 	PaymentProviderToken string `json:"payment_provider_token"` // <-- BARU
 	ManualPaymentInfo    string `json:"manual_payment_info"`
 	ForceSubscribeChannelID int64
+	MaxConcurrentGenerations int
+	RefundMaxSpentPercent    int
+	HTTPListenAddr           string // alamat server HTTP untuk webhook
+	BMACWebhookSecret        string // kosong = webhook Buy Me a Coffee nonaktif
+	GiftMinAmount            int    // batas per /gift (paid credits)
+	GiftMaxAmount            int
+	GiftDailyLimit           int // total paid credits yang boleh dikirim per user per 24 jam
+	LowBalanceThreshold      int // default notifikasi saldo rendah (bisa diubah user lewat /limits), 0 = nonaktif
+	CaptchaMode              string // off, buttons atau image: tantangan anti-bot sebelum free credit awal diberikan
+	CaptchaMaxAttempts       int    // salah sebanyak ini = dikunci sementara
+	CaptchaSkipReferred      string // none, any atau trusted: user referral yang boleh melewati tantangan
+	DashboardToken           string // token Bearer dashboard admin web
+	DashboardUser            string // basic auth dashboard admin web
+	DashboardPassword        string // kosong (dan DashboardToken kosong) = dashboard nonaktif
 }
 
+// Mode tantangan anti-bot (CAPTCHA_MODE).
+const (
+	CaptchaOff     = "off"
+	CaptchaButtons = "buttons"
+	CaptchaImage   = "image"
+)
+
+// Aturan lewati tantangan untuk user yang datang dari link referral (CAPTCHA_SKIP_REFERRED).
+const (
+	CaptchaSkipNone    = "none"
+	CaptchaSkipAny     = "any"
+	CaptchaSkipTrusted = "trusted" // referrer pernah membeli dan tidak ditandai fraud
+)
+
 type Parameter struct {
 	Name        string      `json:"name"`
 	Label       string      `json:"label"`
 	Type        string      `json:"type"`
 	Default     interface{} `json:"default,omitempty"`
 	Description string      `json:"description,omitempty"`
-	Min         float64     `json:"min,omitempty"`
-	Max         float64     `json:"max,omitempty"`
+	Min         *float64    `json:"min,omitempty"`
+	Max         *float64    `json:"max,omitempty"`
+	MinLength   int         `json:"min_length,omitempty"`
+	MaxLength   int         `json:"max_length,omitempty"`
 	Options     []string    `json:"options,omitempty"`
+	OptionLabels map[string]string `json:"option_labels,omitempty"` // label tampilan untuk tipe enum
 }
 
 type Model struct {
@@ -78,6 +111,19 @@ type Model struct {
 	ConfigurableNumOutputs  bool `json:"configurable_num_outputs"`
 	ShowTemplates             bool   `json:"show_templates"`
 	Parameters              []Parameter `json:"parameters,omitempty"`
+	Pricing                 *pricing.Rule `json:"pricing,omitempty"` // opsional, menggantikan cost/diamond_cost
+}
+
+// PricingRule mengembalikan aturan biaya model. Model tanpa "pricing" memakai
+// cost per gambar (image) atau diamond_cost tetap (video).
+func (m Model) PricingRule() pricing.Rule {
+	if m.Pricing != nil {
+		return *m.Pricing
+	}
+	if m.Type == "video" {
+		return pricing.Flat(m.DiamondCost, false)
+	}
+	return pricing.Flat(m.Cost, true)
 }
 
 type PromptTemplate struct {
@@ -125,6 +171,46 @@ func Load() *Config {
 	}
 	// <-- SELESAI BLOK BARU
 
+	maxGenStr := getEnv("MAX_CONCURRENT_GENERATIONS", "4")
+	maxGenerations, err := strconv.Atoi(maxGenStr)
+	if err != nil || maxGenerations <= 0 {
+		log.Fatalf("FATAL: Invalid MAX_CONCURRENT_GENERATIONS: %s", maxGenStr)
+	}
+
+	refundSpentStr := getEnv("REFUND_MAX_SPENT_PERCENT", "20")
+	refundMaxSpent, err := strconv.Atoi(refundSpentStr)
+	if err != nil || refundMaxSpent < 0 || refundMaxSpent > 100 {
+		log.Fatalf("FATAL: Invalid REFUND_MAX_SPENT_PERCENT: %s", refundSpentStr)
+	}
+
+	giftMin := getIntEnv("GIFT_MIN_AMOUNT", 1)
+	giftMax := getIntEnv("GIFT_MAX_AMOUNT", 500)
+	giftDaily := getIntEnv("GIFT_DAILY_LIMIT", 1000)
+	if giftMin <= 0 || giftMax < giftMin || giftDaily < giftMax {
+		log.Fatalf("FATAL: Invalid gift limits: min %d, max %d, daily %d", giftMin, giftMax, giftDaily)
+	}
+
+	lowBalance := getIntEnv("LOW_BALANCE_THRESHOLD", 10)
+	if lowBalance < 0 {
+		log.Fatalf("FATAL: Invalid LOW_BALANCE_THRESHOLD: %d", lowBalance)
+	}
+
+	captchaMode := strings.ToLower(getEnv("CAPTCHA_MODE", CaptchaOff))
+	if captchaMode != CaptchaOff && captchaMode != CaptchaButtons && captchaMode != CaptchaImage {
+		log.Fatalf("FATAL: Invalid CAPTCHA_MODE: %s", captchaMode)
+	}
+	captchaSkip := strings.ToLower(getEnv("CAPTCHA_SKIP_REFERRED", CaptchaSkipTrusted))
+	if captchaSkip != CaptchaSkipNone && captchaSkip != CaptchaSkipAny && captchaSkip != CaptchaSkipTrusted {
+		log.Fatalf("FATAL: Invalid CAPTCHA_SKIP_REFERRED: %s", captchaSkip)
+	}
+	captchaAttempts := getIntEnv("CAPTCHA_MAX_ATTEMPTS", 3)
+	if captchaAttempts <= 0 {
+		log.Fatalf("FATAL: Invalid CAPTCHA_MAX_ATTEMPTS: %d", captchaAttempts)
+	}
+	if captchaMode != CaptchaOff {
+		log.Printf("INFO: Onboarding challenge enabled (%s, referred users skip: %s)", captchaMode, captchaSkip)
+	}
+
 	return &Config{
 		TelegramBotToken:   getEnv("TELEGRAM_BOT_TOKEN", ""),
 		SupabaseURL:        getEnv("SUPABASE_URL", ""),
@@ -135,6 +221,20 @@ func Load() *Config {
 		PaymentProviderToken: getEnv("PAYMENT_PROVIDER_TOKEN", ""), // <-- BARU
 		ManualPaymentInfo:    getEnv("MANUAL_PAYMENT_INFO", "Untuk pembayaran manual, silakan transfer ke:\nBank ABC: `1234567890` a.n. John Doe\n\nKirim bukti transfer ke @Admin."), // <-- BARU
 		ForceSubscribeChannelID: channelID,
+		MaxConcurrentGenerations: maxGenerations,
+		RefundMaxSpentPercent:    refundMaxSpent,
+		HTTPListenAddr:           getEnv("HTTP_LISTEN_ADDR", ":8080"),
+		BMACWebhookSecret:        getOptionalEnv("BMAC_WEBHOOK_SECRET"),
+		GiftMinAmount:            giftMin,
+		GiftMaxAmount:            giftMax,
+		GiftDailyLimit:           giftDaily,
+		LowBalanceThreshold:      lowBalance,
+		CaptchaMode:              captchaMode,
+		CaptchaMaxAttempts:       captchaAttempts,
+		CaptchaSkipReferred:      captchaSkip,
+		DashboardToken:           getOptionalEnv("DASHBOARD_TOKEN"),
+		DashboardUser:            getEnv("DASHBOARD_USER", "admin"),
+		DashboardPassword:        getOptionalEnv("DASHBOARD_PASSWORD"),
 	}
 }
 
@@ -167,6 +267,20 @@ func LoadModels(file string) []Model {
 	for _, m := range allModels {
 		if m.Enabled {
 			enabledModels = append(enabledModels, m)
+			if m.Pricing != nil {
+				if err := m.Pricing.Validate(); err != nil {
+					log.Fatalf("FATAL: Invalid pricing for model '%s' in %s: %v", m.ID, file, err)
+				}
+			}
+			// Default yang melanggar skema sendiri biasanya typo di models.json
+			for _, p := range m.Parameters {
+				if p.Default == nil {
+					continue
+				}
+				if err := p.Validate(p.Default); err != nil {
+					log.Printf("WARN: Default value %v of parameter '%s' in model '%s' violates its schema: %v", p.Default, p.Name, m.ID, err)
+				}
+			}
 		}
 	}
 
@@ -214,4 +328,22 @@ func getEnv(key, fallback string) string {
 		log.Fatalf("FATAL: Environment variable %s is not set.", key)
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+// getIntEnv membaca variabel angka opsional; nilai yang tidak valid langsung fatal.
+func getIntEnv(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("FATAL: Invalid %s: %s", key, value)
+	}
+	return n
+}
+
+// getOptionalEnv seperti getEnv tapi tidak fatal jika variabel tidak di-set.
+func getOptionalEnv(key string) string {
+	return os.Getenv(key)
+}
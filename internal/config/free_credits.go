@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+	_ "time/tzdata" // agar zona waktu tetap bisa dimuat di container tanpa tzdata
+)
+
+// Periode reset free credit yang didukung.
+const (
+	FreeCreditPeriodDaily   = "daily"
+	FreeCreditPeriodWeekly  = "weekly"
+	FreeCreditPeriodMonthly = "monthly"
+)
+
+// FreeCreditTier adalah jatah free credit untuk satu tier user.
+type FreeCreditTier struct {
+	Amount      int `json:"amount"`       // jumlah yang diberikan tiap periode
+	RolloverCap int `json:"rollover_cap"` // batas total jika sisa periode lalu ikut terbawa; <= amount = tidak ada rollover
+}
+
+// FreeCreditPolicy mengatur kapan dan berapa free credit diisi ulang (free_credits.json).
+// Pengisian dilakukan oleh job terjadwal, bukan saat data user dibaca.
+type FreeCreditPolicy struct {
+	Period        string                    `json:"period"`         // daily, weekly, monthly
+	Timezone      string                    `json:"timezone"`       // nama IANA, misal "Asia/Jakarta"
+	ResetHour     int                       `json:"reset_hour"`     // jam lokal saat periode baru dimulai
+	WeekStart     string                    `json:"week_start"`     // hari reset untuk periode weekly
+	CheckInterval string                    `json:"check_interval"` // seberapa sering job pengisian berjalan
+	Tiers         map[string]FreeCreditTier `json:"tiers"`          // per ID tier; tier yang tidak ada memakai daily_free_credits dari tiers.json
+
+	location  *time.Location
+	weekStart time.Weekday
+	interval  time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+func LoadFreeCreditPolicy(file string) *FreeCreditPolicy {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read free credit policy file %s: %v", file, err)
+	}
+
+	policy := &FreeCreditPolicy{Period: FreeCreditPeriodDaily, Timezone: "UTC", WeekStart: "monday", CheckInterval: "10m"}
+	if err := json.Unmarshal(data, policy); err != nil {
+		log.Fatalf("FATAL: Could not parse free credit policy file %s: %v", file, err)
+	}
+
+	switch policy.Period {
+	case FreeCreditPeriodDaily, FreeCreditPeriodWeekly, FreeCreditPeriodMonthly:
+	default:
+		log.Fatalf("FATAL: Free credit policy has unknown period '%s'", policy.Period)
+	}
+	if policy.location, err = time.LoadLocation(policy.Timezone); err != nil {
+		log.Fatalf("FATAL: Free credit policy has invalid timezone '%s': %v", policy.Timezone, err)
+	}
+	if policy.ResetHour < 0 || policy.ResetHour > 23 {
+		log.Fatalf("FATAL: Free credit policy reset_hour must be between 0 and 23, got %d", policy.ResetHour)
+	}
+	weekStart, ok := weekdays[strings.ToLower(policy.WeekStart)]
+	if !ok {
+		log.Fatalf("FATAL: Free credit policy has unknown week_start '%s'", policy.WeekStart)
+	}
+	policy.weekStart = weekStart
+	if policy.interval, err = time.ParseDuration(policy.CheckInterval); err != nil || policy.interval <= 0 {
+		log.Fatalf("FATAL: Free credit policy has invalid check_interval '%s'", policy.CheckInterval)
+	}
+	for id, tier := range policy.Tiers {
+		if tier.Amount < 0 || tier.RolloverCap < 0 {
+			log.Fatalf("FATAL: Free credit policy for tier '%s' has negative values", id)
+		}
+	}
+
+	log.Printf("INFO: Loaded free credit policy (%s, reset %02d:00 %s)", policy.Period, policy.ResetHour, policy.Timezone)
+	return policy
+}
+
+// ForTier mengembalikan jatah free credit untuk tier user.
+func (p *FreeCreditPolicy) ForTier(tier UserTier) FreeCreditTier {
+	if rule, ok := p.Tiers[tier.ID]; ok {
+		return rule
+	}
+	return FreeCreditTier{Amount: tier.DailyFreeCredits}
+}
+
+// Interval adalah jeda antar putaran job pengisian.
+func (p *FreeCreditPolicy) Interval() time.Duration {
+	return p.interval
+}
+
+// PeriodStart mengembalikan awal periode yang sedang berjalan pada waktu t.
+func (p *FreeCreditPolicy) PeriodStart(t time.Time) time.Time {
+	local := t.In(p.location)
+	start := time.Date(local.Year(), local.Month(), local.Day(), p.ResetHour, 0, 0, 0, p.location)
+
+	switch p.Period {
+	case FreeCreditPeriodWeekly:
+		offset := (int(local.Weekday()) - int(p.weekStart) + 7) % 7
+		start = start.AddDate(0, 0, -offset)
+	case FreeCreditPeriodMonthly:
+		start = time.Date(local.Year(), local.Month(), 1, p.ResetHour, 0, 0, 0, p.location)
+	}
+	if start.After(local) {
+		start = p.previous(start)
+	}
+	return start
+}
+
+// NextReset mengembalikan awal periode berikutnya setelah t.
+func (p *FreeCreditPolicy) NextReset(t time.Time) time.Time {
+	start := p.PeriodStart(t)
+	switch p.Period {
+	case FreeCreditPeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case FreeCreditPeriodMonthly:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+func (p *FreeCreditPolicy) previous(start time.Time) time.Time {
+	switch p.Period {
+	case FreeCreditPeriodWeekly:
+		return start.AddDate(0, 0, -7)
+	case FreeCreditPeriodMonthly:
+		return start.AddDate(0, -1, 0)
+	}
+	return start.AddDate(0, 0, -1)
+}
+
+// Refill menghitung saldo free credit baru dari saldo sekarang.
+// Tanpa rollover saldo diset ke Amount; dengan rollover sisa ditambah Amount sampai RolloverCap.
+func (rule FreeCreditTier) Refill(current int) int {
+	if rule.RolloverCap <= rule.Amount {
+		return rule.Amount
+	}
+	total := current + rule.Amount
+	if total > rule.RolloverCap {
+		total = rule.RolloverCap
+	}
+	if total < rule.Amount {
+		total = rule.Amount
+	}
+	return total
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Tipe parameter yang dikenali oleh models.json.
+const (
+	ParamTypeInteger = "integer"
+	ParamTypeNumber  = "number"
+	ParamTypeString  = "string"
+	ParamTypeText    = "text" // string multi-baris (misal: negative prompt panjang)
+	ParamTypeBoolean = "boolean"
+	ParamTypeEnum    = "enum" // pilihan tetap, label tampilan diambil dari OptionLabels
+)
+
+// ParamError menjelaskan kenapa nilai dari user ditolak.
+// Key adalah key di file locales, Args adalah placeholder-nya,
+// sehingga bot bisa menampilkan pesan yang sudah diterjemahkan.
+type ParamError struct {
+	Key  string
+	Args map[string]string
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid parameter value (%s): %v", e.Key, e.Args)
+}
+
+func (p Parameter) newError(key string, extra map[string]string) *ParamError {
+	args := map[string]string{"label": p.Label}
+	for k, v := range extra {
+		args[k] = v
+	}
+	return &ParamError{Key: key, Args: args}
+}
+
+// IsChoice bernilai true jika parameter dipilih lewat tombol, bukan diketik.
+func (p Parameter) IsChoice() bool {
+	return len(p.Options) > 0 || p.Type == ParamTypeBoolean || p.Type == ParamTypeEnum
+}
+
+// ChoiceOptions mengembalikan daftar nilai yang bisa dipilih lewat tombol.
+func (p Parameter) ChoiceOptions() []string {
+	if p.Type == ParamTypeBoolean && len(p.Options) == 0 {
+		return []string{"true", "false"}
+	}
+	return p.Options
+}
+
+// OptionLabel mengembalikan label tampilan untuk sebuah opsi (jika ada).
+func (p Parameter) OptionLabel(option string) string {
+	if label, ok := p.OptionLabels[option]; ok && label != "" {
+		return label
+	}
+	return option
+}
+
+// DisplayValue memformat nilai tersimpan agar rapi saat ditampilkan di dashboard.
+func (p Parameter) DisplayValue(value interface{}) string {
+	return p.OptionLabel(FormatParamValue(value))
+}
+
+// Parse mengubah input teks dari user menjadi nilai bertipe sesuai skema
+// lalu memvalidasinya. Nilai yang dikembalikan siap disimpan ke CustomSettings.
+func (p Parameter) Parse(input string) (interface{}, error) {
+	raw := input
+	if p.Type != ParamTypeText {
+		raw = strings.TrimSpace(input)
+	} else {
+		raw = strings.Trim(input, " \t\r\n")
+	}
+
+	if raw == "" {
+		return nil, p.newError("param_error_empty", nil)
+	}
+
+	var value interface{}
+	switch p.Type {
+	case ParamTypeInteger:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, p.newError("param_error_integer", nil)
+		}
+		value = int(n)
+	case ParamTypeNumber:
+		f, err := strconv.ParseFloat(strings.Replace(raw, ",", ".", 1), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, p.newError("param_error_number", nil)
+		}
+		value = f
+	case ParamTypeBoolean:
+		switch strings.ToLower(raw) {
+		case "true", "yes", "y", "on", "1", "ya":
+			value = true
+		case "false", "no", "n", "off", "0", "tidak":
+			value = false
+		default:
+			return nil, p.newError("param_error_boolean", nil)
+		}
+	case ParamTypeText:
+		value = raw
+	default: // string & enum
+		if strings.ContainsAny(raw, "\r\n") {
+			return nil, p.newError("param_error_single_line", nil)
+		}
+		value = raw
+	}
+
+	if err := p.Validate(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Validate memeriksa nilai (dari input user atau dari CustomSettings di DB)
+// terhadap min/max, daftar options dan batas panjang string.
+func (p Parameter) Validate(value interface{}) error {
+	switch v := value.(type) {
+	case bool:
+		if p.Type != ParamTypeBoolean {
+			return p.typeError()
+		}
+	case int, int64, float64:
+		f := toFloat(v)
+		if p.Type == ParamTypeInteger && f != math.Trunc(f) {
+			return p.newError("param_error_integer", nil)
+		}
+		if p.Type != ParamTypeInteger && p.Type != ParamTypeNumber && len(p.Options) == 0 {
+			return p.typeError()
+		}
+		if err := p.checkRange(f); err != nil {
+			return err
+		}
+	case string:
+		if p.Type == ParamTypeInteger || p.Type == ParamTypeNumber || p.Type == ParamTypeBoolean {
+			// Nilai lama tersimpan sebagai string: parse ulang agar tetap tervalidasi
+			if _, err := p.Parse(v); err != nil {
+				return err
+			}
+			return nil
+		}
+		length := utf8.RuneCountInString(v)
+		if p.MinLength > 0 && length < p.MinLength {
+			return p.newError("param_error_min_length", map[string]string{"min_length": strconv.Itoa(p.MinLength)})
+		}
+		if p.MaxLength > 0 && length > p.MaxLength {
+			return p.newError("param_error_max_length", map[string]string{"max_length": strconv.Itoa(p.MaxLength)})
+		}
+	default:
+		return p.typeError()
+	}
+
+	if len(p.Options) > 0 {
+		formatted := FormatParamValue(value)
+		for _, option := range p.Options {
+			if option == formatted {
+				return nil
+			}
+		}
+		return p.newError("param_error_option", map[string]string{"options": p.optionLabels()})
+	}
+	return nil
+}
+
+// RangeHint mengembalikan args {min, max} untuk ditampilkan sebagai petunjuk input.
+func (p Parameter) RangeHint() (min, max string) {
+	if p.Min != nil {
+		min = FormatParamValue(*p.Min)
+	}
+	if p.Max != nil {
+		max = FormatParamValue(*p.Max)
+	}
+	return min, max
+}
+
+func (p Parameter) checkRange(f float64) error {
+	min, max := p.RangeHint()
+	outOfRange := (p.Min != nil && f < *p.Min) || (p.Max != nil && f > *p.Max)
+	if !outOfRange {
+		return nil
+	}
+	switch {
+	case p.Min != nil && p.Max != nil:
+		return p.newError("param_error_range", map[string]string{"min": min, "max": max})
+	case p.Min != nil:
+		return p.newError("param_error_min", map[string]string{"min": min})
+	default:
+		return p.newError("param_error_max", map[string]string{"max": max})
+	}
+}
+
+// typeError adalah error untuk nilai yang tipenya tidak cocok dengan parameter.
+func (p Parameter) typeError() error {
+	switch p.Type {
+	case ParamTypeInteger, ParamTypeNumber, ParamTypeBoolean:
+		return p.newError("param_error_"+p.Type, nil)
+	}
+	if len(p.Options) > 0 {
+		return p.newError("param_error_option", map[string]string{"options": p.optionLabels()})
+	}
+	return p.newError("param_error_string", nil)
+}
+
+func (p Parameter) optionLabels() string {
+	labels := make([]string, 0, len(p.Options))
+	for _, option := range p.Options {
+		labels = append(labels, p.OptionLabel(option))
+	}
+	return strings.Join(labels, ", ")
+}
+
+// FormatParamValue mengubah nilai parameter menjadi string yang konsisten
+// (angka bulat tanpa desimal, boolean sebagai "true"/"false").
+func FormatParamValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math"
+)
+
+// ID tier user bawaan. User.IsPremium menentukan tier mana yang dipakai.
+const (
+	UserTierFree    = "free"
+	UserTierPremium = "premium"
+)
+
+// UserTier adalah aturan per tier user (bukan tier model) yang dibaca dari tiers.json.
+type UserTier struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	PriceMultiplier  float64  `json:"price_multiplier"`
+	DailyFreeCredits int      `json:"daily_free_credits"` // fallback jika tier tidak diatur di free_credits.json
+	ModelTiers       []string `json:"model_tiers"`    // Model.Tier yang boleh dipakai
+	QueuePriority    int      `json:"queue_priority"` // makin besar makin didahulukan
+}
+
+// TierConfig menyimpan semua tier user yang dikenal.
+type TierConfig struct {
+	Tiers []UserTier
+}
+
+func LoadTiers(file string) *TierConfig {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read tiers file %s: %v", file, err)
+	}
+
+	var tiers []UserTier
+	if err := json.Unmarshal(data, &tiers); err != nil {
+		log.Fatalf("FATAL: Could not parse tiers file %s: %v", file, err)
+	}
+
+	cfg := &TierConfig{Tiers: tiers}
+	for _, id := range []string{UserTierFree, UserTierPremium} {
+		if cfg.find(id) == nil {
+			log.Fatalf("FATAL: Tiers file %s must define tier '%s'", file, id)
+		}
+	}
+	for _, t := range tiers {
+		if t.PriceMultiplier <= 0 {
+			log.Fatalf("FATAL: Tier '%s' has invalid price_multiplier %v", t.ID, t.PriceMultiplier)
+		}
+	}
+
+	log.Printf("INFO: Loaded %d user tiers", len(tiers))
+	return cfg
+}
+
+func (c *TierConfig) find(id string) *UserTier {
+	for i := range c.Tiers {
+		if c.Tiers[i].ID == id {
+			return &c.Tiers[i]
+		}
+	}
+	return nil
+}
+
+// ForUser mengembalikan tier yang berlaku untuk user.
+func (c *TierConfig) ForUser(isPremium bool) UserTier {
+	if isPremium {
+		return *c.find(UserTierPremium)
+	}
+	return *c.find(UserTierFree)
+}
+
+// Premium mengembalikan tier premium (untuk teks upsell).
+func (c *TierConfig) Premium() UserTier {
+	return *c.find(UserTierPremium)
+}
+
+// CanUse bernilai true jika tier ini boleh memakai model dengan Model.Tier tertentu.
+// Model tanpa tier dianggap terbuka untuk semua.
+func (t UserTier) CanUse(modelTier string) bool {
+	if modelTier == "" {
+		return true
+	}
+	for _, allowed := range t.ModelTiers {
+		if allowed == modelTier {
+			return true
+		}
+	}
+	return false
+}
+
+// Price menerapkan price_multiplier ke biaya dasar, dibulatkan ke atas.
+// Biaya dasar > 0 tidak pernah menjadi gratis.
+func (t UserTier) Price(baseCost int) int {
+	if baseCost <= 0 {
+		return baseCost
+	}
+	price := int(math.Ceil(float64(baseCost)*t.PriceMultiplier - 1e-9))
+	if price < 1 {
+		price = 1
+	}
+	return price
+}
@@ -0,0 +1,168 @@
+// Package dashboard menyediakan dashboard admin web read-only: statistik, generate terbaru,
+// pembayaran, pencarian user, status broadcast dan kesehatan model. Datanya dibaca dari
+// lapisan database yang sama dengan bot, tidak ada aksi yang bisa mengubah data dari sini.
+package dashboard
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/database"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+const (
+	pageSize      = 50
+	healthWindow  = 24 * time.Hour
+	healthMaxRows = 10000 // batas baris yang dibaca untuk menghitung kesehatan model
+)
+
+type Dashboard struct {
+	DB     *database.Client
+	Models []config.Model
+
+	token    string
+	user     string
+	password string
+	pages    map[string]*template.Template
+}
+
+// New menyiapkan dashboard dari DASHBOARD_TOKEN / DASHBOARD_USER / DASHBOARD_PASSWORD.
+func New(db *database.Client, models []config.Model, cfg *config.Config) *Dashboard {
+	d := &Dashboard{
+		DB:       db,
+		Models:   models,
+		token:    cfg.DashboardToken,
+		user:     cfg.DashboardUser,
+		password: cfg.DashboardPassword,
+		pages:    make(map[string]*template.Template),
+	}
+	funcs := template.FuncMap{
+		"fmtTime":  formatTime,
+		"truncate": truncate,
+		"percent":  func(f float64) string { return strconv.FormatFloat(f*100, 'f', 1, 64) + "%" },
+		"seconds":  func(ms int) string { return strconv.FormatFloat(float64(ms)/1000, 'f', 1, 64) + "s" },
+	}
+	for _, name := range []string{"overview", "generations", "payments", "users", "broadcasts", "models"} {
+		d.pages[name] = template.Must(template.New(name).Funcs(funcs).ParseFS(templateFS, "templates/layout.html", "templates/partials.html", "templates/"+name+".html"))
+	}
+	return d
+}
+
+// Enabled bernilai true jika token atau password sudah diatur. Tanpa keduanya dashboard tidak dipasang.
+func (d *Dashboard) Enabled() bool {
+	return d.token != "" || d.password != ""
+}
+
+// Handler mengembalikan handler untuk semua route di bawah /admin/.
+func (d *Dashboard) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/", d.handleOverview)
+	mux.HandleFunc("/admin/generations", d.handleGenerations)
+	mux.HandleFunc("/admin/payments", d.handlePayments)
+	mux.HandleFunc("/admin/users", d.handleUsers)
+	mux.HandleFunc("/admin/broadcasts", d.handleBroadcasts)
+	mux.HandleFunc("/admin/models", d.handleModels)
+	return d.guard(mux)
+}
+
+// guard memeriksa autentikasi, hanya mengizinkan GET/HEAD dan memasang header keamanan.
+func (d *Dashboard) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.authorized(r) {
+			if d.password != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+			}
+			log.Printf("WARN: Unauthorized dashboard request from %s", r.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h := w.Header()
+		h.Set("Cache-Control", "no-store")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("X-Content-Type-Options", "nosniff")
+		// no-referrer: URL dashboard tidak ikut terkirim saat memuat thumbnail dari Replicate
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy", "default-src 'none'; img-src https: data:; style-src 'unsafe-inline'")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *Dashboard) authorized(r *http.Request) bool {
+	if d.token != "" {
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(bearer, d.token) {
+			return true
+		}
+	}
+	if d.password != "" {
+		if user, password, ok := r.BasicAuth(); ok && secureEqual(user, d.user) && secureEqual(password, d.password) {
+			return true
+		}
+	}
+	return false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// page adalah data yang diberikan ke layout.html. Data berisi isi khusus tiap halaman.
+type page struct {
+	Title  string
+	Active string
+	Now    time.Time
+	Errors []string
+	Data   interface{}
+}
+
+// render menulis halaman ke buffer dulu agar error template tidak menghasilkan HTML setengah jadi.
+func (d *Dashboard) render(w http.ResponseWriter, name, title string, errs []string, data interface{}) {
+	var buf bytes.Buffer
+	p := page{Title: title, Active: name, Now: time.Now(), Errors: errs, Data: data}
+	if err := d.pages[name].ExecuteTemplate(&buf, "layout", p); err != nil {
+		log.Printf("ERROR: Failed to render dashboard page %s: %v", name, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func formatTime(t interface{}) string {
+	switch v := t.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return "-"
+		}
+		return v.UTC().Format("2006-01-02 15:04")
+	case *time.Time:
+		if v == nil {
+			return "-"
+		}
+		return v.UTC().Format("2006-01-02 15:04")
+	}
+	return "-"
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
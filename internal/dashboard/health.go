@@ -0,0 +1,98 @@
+package dashboard
+
+import (
+	"sort"
+	"time"
+
+	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/database"
+)
+
+// Status kesehatan model berdasarkan rasio prediksi yang berhasil.
+const (
+	healthOK       = "ok"       // >= 90% berhasil
+	healthDegraded = "degraded" // >= 50% berhasil
+	healthDown     = "down"
+	healthIdle     = "idle" // belum ada prediksi di jendela waktu
+)
+
+// ModelHealth adalah ringkasan prediksi satu model di jendela healthWindow.
+type ModelHealth struct {
+	ModelID     string
+	Name        string
+	InCatalog   bool
+	Total       int
+	Failed      int
+	SuccessRate float64
+	AvgMS       int
+	P95MS       int
+	LastError   string
+	LastErrorAt *time.Time
+	Status      string
+}
+
+// modelHealth menggabungkan model di models.json dengan prediksi yang tercatat. Model yang sudah
+// dihapus dari models.json tetap ditampilkan selama masih ada prediksinya. generations harus urut terbaru dulu.
+func modelHealth(models []config.Model, generations []database.Generation) []ModelHealth {
+	byID := make(map[string]*ModelHealth)
+	var order []string
+	for _, m := range models {
+		byID[m.ID] = &ModelHealth{ModelID: m.ID, Name: m.Name, InCatalog: true}
+		order = append(order, m.ID)
+	}
+	durations := make(map[string][]int)
+	for _, g := range generations {
+		h, ok := byID[g.ModelID]
+		if !ok {
+			h = &ModelHealth{ModelID: g.ModelID, Name: g.ModelID}
+			byID[g.ModelID] = h
+			order = append(order, g.ModelID)
+		}
+		h.Total++
+		if g.Status == database.GenerationFailed {
+			h.Failed++
+			if h.LastErrorAt == nil {
+				h.LastError, h.LastErrorAt = g.Error, g.CreatedAt
+			}
+			continue
+		}
+		durations[g.ModelID] = append(durations[g.ModelID], g.DurationMS)
+	}
+
+	result := make([]ModelHealth, 0, len(order))
+	for _, id := range order {
+		h := byID[id]
+		if d := durations[id]; len(d) > 0 {
+			sort.Ints(d)
+			sum := 0
+			for _, ms := range d {
+				sum += ms
+			}
+			h.AvgMS = sum / len(d)
+			h.P95MS = d[(len(d)*95+99)/100-1]
+		}
+		switch {
+		case h.Total == 0:
+			h.Status = healthIdle
+		default:
+			h.SuccessRate = float64(h.Total-h.Failed) / float64(h.Total)
+			if h.SuccessRate >= 0.9 {
+				h.Status = healthOK
+			} else if h.SuccessRate >= 0.5 {
+				h.Status = healthDegraded
+			} else {
+				h.Status = healthDown
+			}
+		}
+		result = append(result, *h)
+	}
+	// Model bermasalah di atas, lalu yang paling sering dipakai
+	rank := map[string]int{healthDown: 0, healthDegraded: 1, healthOK: 2, healthIdle: 3}
+	sort.SliceStable(result, func(i, j int) bool {
+		if rank[result[i].Status] != rank[result[j].Status] {
+			return rank[result[i].Status] < rank[result[j].Status]
+		}
+		return result[i].Total > result[j].Total
+	})
+	return result
+}
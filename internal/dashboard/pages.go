@@ -0,0 +1,184 @@
+package dashboard
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+)
+
+// broadcastStallAfter: broadcast running yang progresnya tidak berubah selama ini kemungkinan terhenti (bot restart).
+const broadcastStallAfter = 5 * time.Minute
+
+type overviewData struct {
+	Stats       *database.Statistics
+	Generations int
+	Failed      int
+	Problems    []ModelHealth
+	Broadcasts  []broadcastRow
+	Payments    []database.Payment
+}
+
+func (d *Dashboard) handleOverview(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/admin/" {
+		http.NotFound(w, r)
+		return
+	}
+	var errs []string
+	data := overviewData{}
+
+	stats, err := d.DB.GetStatistics()
+	if err != nil || stats == nil {
+		errs = append(errs, "Could not load user statistics.")
+	}
+	data.Stats = stats
+
+	if generations, err := d.DB.GetGenerationsSince(time.Now().Add(-healthWindow), healthMaxRows); err != nil {
+		errs = append(errs, "Could not load generations.")
+	} else {
+		data.Generations = len(generations)
+		for _, h := range modelHealth(d.Models, generations) {
+			data.Failed += h.Failed
+			if h.Status == healthDown || h.Status == healthDegraded {
+				data.Problems = append(data.Problems, h)
+			}
+		}
+	}
+
+	if jobs, err := d.DB.GetBroadcastJobs(5); err != nil {
+		errs = append(errs, "Could not load broadcasts.")
+	} else {
+		data.Broadcasts = broadcastRows(jobs)
+	}
+
+	if payments, err := d.DB.GetRecentPayments(10); err != nil {
+		errs = append(errs, "Could not load payments.")
+	} else {
+		data.Payments = payments
+	}
+	d.render(w, "overview", "Overview", errs, data)
+}
+
+type generationsData struct {
+	Items []database.Generation
+	Page  int
+	Prev  int
+	Next  int
+}
+
+func (d *Dashboard) handleGenerations(w http.ResponseWriter, r *http.Request) {
+	page := pageParam(r)
+	var errs []string
+	items, err := d.DB.GetRecentGenerations(page*pageSize, pageSize)
+	if err != nil {
+		errs = append(errs, "Could not load generations.")
+	}
+	data := generationsData{Items: items, Page: page + 1, Prev: page - 1, Next: -1}
+	if len(items) == pageSize {
+		data.Next = page + 1
+	}
+	d.render(w, "generations", "Recent generations", errs, data)
+}
+
+type paymentsData struct {
+	Payments []database.Payment
+	Manual   []database.ManualPayment
+}
+
+func (d *Dashboard) handlePayments(w http.ResponseWriter, r *http.Request) {
+	var errs []string
+	data := paymentsData{}
+	var err error
+	if data.Payments, err = d.DB.GetRecentPayments(pageSize); err != nil {
+		errs = append(errs, "Could not load payments.")
+	}
+	if data.Manual, err = d.DB.GetRecentManualPayments(pageSize); err != nil {
+		errs = append(errs, "Could not load manual payments.")
+	}
+	d.render(w, "payments", "Payments", errs, data)
+}
+
+type userRow struct {
+	database.User
+	Status string
+}
+
+type usersData struct {
+	Query string
+	Users []userRow
+}
+
+func (d *Dashboard) handleUsers(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	var errs []string
+	users, err := d.DB.SearchUsers(query, pageSize)
+	if err != nil {
+		errs = append(errs, "Could not search users.")
+	}
+	now := time.Now()
+	data := usersData{Query: query}
+	for _, u := range users {
+		status := "active"
+		switch {
+		case u.IsBanned:
+			status = "banned"
+		case u.IsSuspended(now):
+			status = "suspended until " + formatTime(u.SuspendedUntil)
+		case !u.HumanVerified:
+			status = "unverified"
+		}
+		data.Users = append(data.Users, userRow{User: u, Status: status})
+	}
+	d.render(w, "users", "Users", errs, data)
+}
+
+type broadcastRow struct {
+	database.BroadcastJob
+	Progress float64
+	Stalled  bool
+}
+
+func broadcastRows(jobs []database.BroadcastJob) []broadcastRow {
+	rows := make([]broadcastRow, len(jobs))
+	for i, job := range jobs {
+		rows[i] = broadcastRow{BroadcastJob: job}
+		if job.Total > 0 {
+			rows[i].Progress = float64(job.Sent+job.Failed) / float64(job.Total)
+		}
+		rows[i].Stalled = job.Status == database.BroadcastRunning && job.UpdatedAt != nil && time.Since(*job.UpdatedAt) > broadcastStallAfter
+	}
+	return rows
+}
+
+func (d *Dashboard) handleBroadcasts(w http.ResponseWriter, r *http.Request) {
+	var errs []string
+	jobs, err := d.DB.GetBroadcastJobs(pageSize)
+	if err != nil {
+		errs = append(errs, "Could not load broadcasts.")
+	}
+	d.render(w, "broadcasts", "Broadcasts", errs, broadcastRows(jobs))
+}
+
+type modelsData struct {
+	Window string
+	Models []ModelHealth
+}
+
+func (d *Dashboard) handleModels(w http.ResponseWriter, r *http.Request) {
+	var errs []string
+	generations, err := d.DB.GetGenerationsSince(time.Now().Add(-healthWindow), healthMaxRows)
+	if err != nil {
+		errs = append(errs, "Could not load generations.")
+	}
+	d.render(w, "models", "Model health", errs, modelsData{Window: "24h", Models: modelHealth(d.Models, generations)})
+}
+
+func pageParam(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 0 {
+		return 0
+	}
+	return page
+}
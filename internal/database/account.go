@@ -0,0 +1,150 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Fungsi untuk /mydata (ekspor data user) dan /deleteme (hapus akun).
+
+// ErrAccountDeleted dikembalikan saat menulis saldo user yang sudah menghapus akunnya.
+var ErrAccountDeleted = errors.New("account deleted")
+
+// IsDeleted: user sudah menghapus akunnya dengan /deleteme dan belum memulai lagi lewat /start.
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt != nil
+}
+
+// GetUserGenerations mengambil riwayat generate satu user, terbaru dulu.
+func (c *Client) GetUserGenerations(telegramID int64, offset, limit int) ([]Generation, error) {
+	var results []Generation
+	_, err := c.From("generations").Select("*", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Order("id", &postgrest.OrderOpts{Ascending: false}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get generations of user %d: %v", telegramID, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetUserManualPayments mengambil transfer manual satu user, terbaru dulu.
+func (c *Client) GetUserManualPayments(telegramID int64, limit int) ([]ManualPayment, error) {
+	var results []ManualPayment
+	_, err := c.From("manual_payments").Select("*", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get manual payments of user %d: %v", telegramID, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetUserPromoRedemptions mengambil kode promo yang pernah dipakai user.
+func (c *Client) GetUserPromoRedemptions(telegramID int64, limit int) ([]PromoRedemption, error) {
+	var results []PromoRedemption
+	_, err := c.From("promo_redemptions").Select("*", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Order("redeemed_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get promo redemptions of user %d: %v", telegramID, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetUserReferralRewards mengambil semua hadiah referral yang diterima user (sebagai referrer maupun bonus daftar).
+func (c *Client) GetUserReferralRewards(telegramID int64, limit int) ([]ReferralReward, error) {
+	var results []ReferralReward
+	_, err := c.From("referral_rewards").Select("*", "exact", false).
+		Eq("beneficiary_id", strconv.FormatInt(telegramID, 10)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get referral rewards received by user %d: %v", telegramID, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// AnonymizeUser menghapus data pribadi user untuk /deleteme. Riwayat generate dihapus, prompt di
+// prompt_flags dikosongkan, dan baris users direset ke nilai user baru dengan saldo nol.
+//
+// Yang sengaja disimpan: catatan keuangan (payments, manual_payments, credit_transactions,
+// referral_rewards, promo_redemptions) untuk pembukuan, serta telegram_id, status ban/suspend,
+// human_verified dan referrer_id agar hapus akun tidak bisa dipakai untuk menghindari ban atau
+// mengulang bonus user baru. Urutannya dibuat agar aman diulang jika salah satu langkah gagal.
+//
+// Setelah deleted_at diisi, UpdateUser, perubahan saldo, spend_limits dan isi ulang free credit
+// tidak lagi menulis baris ini sampai user memulai lagi dengan /start (RestoreUser).
+func (c *Client) AnonymizeUser(telegramID int64, now time.Time) error {
+	id := strconv.FormatInt(telegramID, 10)
+
+	var generations []Generation
+	if _, err := c.From("generations").Delete("", "exact").Eq("telegram_id", id).ExecuteTo(&generations); err != nil {
+		log.Printf("ERROR: Failed to delete generations of user %d: %v", telegramID, err)
+		return err
+	}
+
+	var flags []PromptFlag
+	if _, err := c.From("prompt_flags").
+		Update(map[string]interface{}{"prompt": "", "match": ""}, "", "exact").
+		Eq("telegram_id", id).
+		ExecuteTo(&flags); err != nil {
+		log.Printf("ERROR: Failed to redact prompt flags of user %d: %v", telegramID, err)
+		return err
+	}
+
+	var users []User
+	_, err := c.From("users").Update(map[string]interface{}{
+		"username":              "",
+		"custom_settings":       "",
+		"language_code":         "en",
+		"aspect_ratio":          "1:1",
+		"num_outputs":           1,
+		"paid_credits":          0,
+		"free_credits":          0,
+		"diamonds":              0,
+		"balances":              map[string]int{},
+		"generated_image_count": 0,
+		"is_premium":            false,
+		"premium_expires_at":    nil,
+		"notify_free_credits":   false,
+		"spend_limits":          SpendLimits{},
+		"deleted_at":            now.UTC(),
+	}, "", "exact").Eq("telegram_id", id).ExecuteTo(&users)
+	if err != nil {
+		log.Printf("ERROR: Failed to anonymize user %d: %v", telegramID, err)
+		return err
+	}
+	return nil
+}
+
+// RestoreUser mengaktifkan lagi akun yang sudah dihapus saat user mengirim /start. Barisnya sudah
+// direset oleh AnonymizeUser, jadi user mulai dengan profil baru tanpa bonus user baru.
+// restored false berarti akun tidak sedang dihapus.
+func (c *Client) RestoreUser(telegramID int64) (restored bool, err error) {
+	var results []User
+	_, err = c.From("users").Update(map[string]interface{}{"deleted_at": nil}, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Not("deleted_at", "is", "null").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to restore deleted user %d: %v", telegramID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// Peran staf bot. Izin tiap peran ditentukan di paket bot.
+const (
+	RoleOwner   = "owner"   // semua izin, termasuk /grant dan /revoke
+	RoleAdmin   = "admin"   // semua izin kecuali mengatur peran
+	RoleSupport = "support" // melihat dan memoderasi user, tanpa memindahkan saldo
+	RoleFinance = "finance" // saldo, refund, pembayaran manual, promo, hadiah referral
+)
+
+// Roles adalah daftar peran yang valid, urut dari yang paling tinggi.
+var Roles = []string{RoleOwner, RoleAdmin, RoleSupport, RoleFinance}
+
+// ValidRole memeriksa apakah role termasuk Roles.
+func ValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminRole adalah peran satu staf, diberikan lewat /grant.
+type AdminRole struct {
+	TelegramID int64      `json:"telegram_id"`
+	Role       string     `json:"role"`
+	GrantedBy  int64      `json:"granted_by"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+// GetAdminRoles mengambil semua peran staf yang tersimpan.
+func (c *Client) GetAdminRoles() ([]AdminRole, error) {
+	var results []AdminRole
+	_, err := c.From("admin_roles").Select("*", "exact", false).ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get admin roles: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// SetAdminRole memberikan atau mengganti peran seorang staf.
+func (c *Client) SetAdminRole(role *AdminRole) error {
+	var results []AdminRole
+	_, err := c.From("admin_roles").Insert(role, true, "telegram_id", "", "exact").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to set role %s for user %d: %v", role.Role, role.TelegramID, err)
+	}
+	return err
+}
+
+// DeleteAdminRole mencabut peran staf. deleted false berarti user memang tidak punya peran.
+func (c *Client) DeleteAdminRole(telegramID int64) (deleted bool, err error) {
+	var results []AdminRole
+	_, err = c.From("admin_roles").Delete("", "exact").Eq("telegram_id", strconv.FormatInt(telegramID, 10)).ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to delete role of user %d: %v", telegramID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
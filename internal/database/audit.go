@@ -0,0 +1,96 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Jenis aksi di tabel admin_audit_log.
+const (
+	AuditAddCredits      = "addcredits"
+	AuditBroadcast       = "broadcast"
+	AuditBroadcastGroup  = "broadcastgroup"
+	AuditRefund          = "refund"
+	AuditManualApprove   = "manual_approve" // transfer manual disetujui
+	AuditManualReject    = "manual_reject"
+	AuditPromoCreate     = "promo_create"
+	AuditPromoEnable     = "promo_enable"
+	AuditPromoDisable    = "promo_disable"
+	AuditReferralRelease = "referral_release"
+	AuditReferralDeny    = "referral_deny"
+	AuditReferralReverse = "referral_reverse"
+	AuditReferralRevAll  = "referral_reverseall"
+	AuditReferralClear   = "referral_clear" // tanda fraud referrer dihapus
+	AuditBan             = "ban"
+	AuditUnban           = "unban"
+	AuditSuspend         = "suspend"
+	AuditGrant           = "grant" // peran staf diberikan/diganti
+	AuditRevoke          = "revoke"
+	AuditExport          = "audit_export" // admin mengunduh audit log
+	AuditFlagConfirm     = "flag_confirm" // prompt terblokir dikonfirmasi
+	AuditFlagDismiss     = "flag_dismiss" // prompt salah blokir, strike dihapus
+)
+
+// AuditEntry adalah satu aksi admin. TargetID kosong untuk aksi tanpa user target (broadcast, promo).
+type AuditEntry struct {
+	ID        int64                  `json:"id,omitempty"`
+	ActorID   int64                  `json:"actor_id"`
+	Action    string                 `json:"action"`
+	TargetID  *int64                 `json:"target_id,omitempty"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	CreatedAt *time.Time             `json:"created_at,omitempty"`
+}
+
+// RecordAudit mencatat aksi admin. targetID 0 berarti tanpa target. Seperti RecordTransaction,
+// kegagalan hanya di-log karena aksinya sudah terjadi.
+func (c *Client) RecordAudit(actorID int64, action string, targetID int64, params map[string]interface{}) {
+	entry := &AuditEntry{ActorID: actorID, Action: action, Params: params}
+	if targetID != 0 {
+		entry.TargetID = &targetID
+	}
+	var results []AuditEntry
+	_, err := c.From("admin_audit_log").Insert(entry, false, "", "", "exact").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to record audit entry %s by admin %d: %v", action, actorID, err)
+	}
+}
+
+// AuditFilter membatasi hasil GetAuditEntries. Field kosong tidak memfilter.
+type AuditFilter struct {
+	ActorID  int64
+	TargetID int64
+	Action   string
+	Since    time.Time
+}
+
+// GetAuditEntries mengambil audit log yang cocok dengan filter, terbaru dulu, beserta jumlah total barisnya.
+func (c *Client) GetAuditEntries(filter AuditFilter, offset, limit int) ([]AuditEntry, int, error) {
+	query := c.From("admin_audit_log").Select("*", "exact", false)
+	if filter.ActorID != 0 {
+		query = query.Eq("actor_id", strconv.FormatInt(filter.ActorID, 10))
+	}
+	if filter.TargetID != 0 {
+		query = query.Eq("target_id", strconv.FormatInt(filter.TargetID, 10))
+	}
+	if filter.Action != "" {
+		query = query.Eq("action", filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Gte("created_at", filter.Since.UTC().Format(time.RFC3339))
+	}
+
+	var results []AuditEntry
+	count, err := query.
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Order("id", &postgrest.OrderOpts{Ascending: false}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get audit entries: %v", err)
+		return nil, 0, err
+	}
+	return results, int(count), nil
+}
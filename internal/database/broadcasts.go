@@ -0,0 +1,76 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Jenis dan status baris di tabel broadcast_jobs.
+const (
+	BroadcastUsers    = "users"  // /broadcast
+	BroadcastGroups   = "groups" // /broadcastgroup
+	BroadcastRunning  = "running"
+	BroadcastFinished = "finished"
+)
+
+// BroadcastJob adalah satu pengiriman /broadcast atau /broadcastgroup beserta progresnya.
+type BroadcastJob struct {
+	ID         int64      `json:"id,omitempty"`
+	Kind       string     `json:"kind"`
+	ActorID    int64      `json:"actor_id"`
+	Text       string     `json:"text"`
+	Photo      string     `json:"photo"`
+	Total      int        `json:"total"`
+	Sent       int        `json:"sent"`
+	Failed     int        `json:"failed"`
+	Status     string     `json:"status,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// CreateBroadcastJob menyimpan broadcast baru dengan status running.
+func (c *Client) CreateBroadcastJob(job *BroadcastJob) (*BroadcastJob, error) {
+	var results []BroadcastJob
+	_, err := c.From("broadcast_jobs").Insert(job, false, "", "", "exact").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to create %s broadcast job: %v", job.Kind, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return job, nil
+	}
+	return &results[0], nil
+}
+
+// UpdateBroadcastJob menyimpan progres broadcast, finished menandai broadcast selesai.
+func (c *Client) UpdateBroadcastJob(id int64, sent, failed int, finished bool) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	update := map[string]interface{}{"sent": sent, "failed": failed, "updated_at": now}
+	if finished {
+		update["status"] = BroadcastFinished
+		update["finished_at"] = now
+	}
+	var results []BroadcastJob
+	_, err := c.From("broadcast_jobs").Update(update, "", "exact").Eq("id", strconv.FormatInt(id, 10)).ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to update broadcast job #%d: %v", id, err)
+	}
+}
+
+// GetBroadcastJobs mengambil broadcast terbaru dulu.
+func (c *Client) GetBroadcastJobs(limit int) ([]BroadcastJob, error) {
+	var results []BroadcastJob
+	_, err := c.From("broadcast_jobs").Select("*", "exact", false).
+		Order("started_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get broadcast jobs: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// GetUsersDueFreeCredits mengambil user yang belum diisi ulang sejak periodStart,
+// diurutkan per telegram_id agar bisa dipaging dengan afterID. User yang dibanned, sedang
+// disuspend atau sudah menghapus akunnya (/deleteme) tidak ikut diisi ulang.
+func (c *Client) GetUsersDueFreeCredits(periodStart time.Time, afterID int64, limit int) ([]User, error) {
+	var results []User
+	_, err := c.From("users").Select("*", "exact", false).
+		Lt("last_free_credits_reset", periodStart.UTC().Format(time.RFC3339)).
+		Gt("telegram_id", strconv.FormatInt(afterID, 10)).
+		Eq("is_banned", "false").
+		Or("suspended_until.is.null,suspended_until.lt."+time.Now().UTC().Format(time.RFC3339), "").
+		Is("deleted_at", "null").
+		Eq("human_verified", "true").
+		Order("telegram_id", &postgrest.OrderOpts{Ascending: true}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get users due for free credits: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// RefillFreeCredits mengisi ulang free credit satu user hanya jika saldonya masih `expected`
+// dan belum diisi ulang di periode ini. Mengembalikan false jika ada perubahan di antaranya
+// (user sedang memakai kredit), sehingga bisa dicoba lagi di putaran berikutnya.
+func (c *Client) RefillFreeCredits(telegramID int64, expected, freeCredits int, periodStart, now time.Time) (bool, error) {
+	var results []User
+	update := map[string]interface{}{
+		"free_credits":            freeCredits,
+		"last_free_credits_reset": now.UTC().Format(time.RFC3339),
+	}
+	_, err := c.From("users").Update(update, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("free_credits", strconv.Itoa(expected)).
+		Lt("last_free_credits_reset", periodStart.UTC().Format(time.RFC3339)).
+		Is("deleted_at", "null").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to refill free credits for user %d: %v", telegramID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+// VerifyHuman menandai user lulus tantangan anti-bot dan memberikan free credit awal.
+// verified false berarti user sudah terverifikasi sebelumnya dan tidak ada yang diubah.
+func (c *Client) VerifyHuman(telegramID int64, freeCredits int, now time.Time) (verified bool, err error) {
+	var results []User
+	update := map[string]interface{}{
+		"human_verified":          true,
+		"free_credits":            freeCredits,
+		"last_free_credits_reset": now.UTC().Format(time.RFC3339),
+	}
+	_, err = c.From("users").Update(update, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("human_verified", "false").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to verify user %d: %v", telegramID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
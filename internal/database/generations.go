@@ -0,0 +1,73 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Jenis dan status baris di tabel generations.
+const (
+	GenerationImage     = "image"
+	GenerationVideo     = "video"
+	GenerationSucceeded = "succeeded"
+	GenerationFailed    = "failed"
+)
+
+// Generation adalah satu prediksi gambar/video, berhasil maupun gagal.
+type Generation struct {
+	ID         int64      `json:"id,omitempty"`
+	TelegramID int64      `json:"telegram_id"`
+	ModelID    string     `json:"model_id"`
+	Kind       string     `json:"kind"`
+	Prompt     string     `json:"prompt"`
+	OutputURLs []string   `json:"output_urls"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error"`
+	Cost       int        `json:"cost"`
+	DurationMS int        `json:"duration_ms"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+// RecordGeneration mencatat hasil prediksi. Kegagalan hanya di-log agar tidak mengganggu pengiriman hasil ke user.
+func (c *Client) RecordGeneration(g *Generation) {
+	if g.OutputURLs == nil {
+		g.OutputURLs = []string{}
+	}
+	var results []Generation
+	_, err := c.From("generations").Insert(g, false, "", "", "exact").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to record %s generation of user %d: %v", g.ModelID, g.TelegramID, err)
+	}
+}
+
+// GetRecentGenerations mengambil prediksi terbaru dulu.
+func (c *Client) GetRecentGenerations(offset, limit int) ([]Generation, error) {
+	var results []Generation
+	_, err := c.From("generations").Select("*", "exact", false).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Order("id", &postgrest.OrderOpts{Ascending: false}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get recent generations: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetGenerationsSince mengambil ringkasan prediksi sejak `since` (tanpa prompt dan URL) untuk menghitung kesehatan model.
+func (c *Client) GetGenerationsSince(since time.Time, limit int) ([]Generation, error) {
+	var results []Generation
+	_, err := c.From("generations").Select("model_id,kind,status,error,duration_ms,created_at", "exact", false).
+		Gte("created_at", since.UTC().Format(time.RFC3339)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get generations since %s: %v", since.Format(time.RFC3339), err)
+		return nil, err
+	}
+	return results, nil
+}
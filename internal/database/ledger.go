@@ -0,0 +1,343 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Jenis baris di tabel credit_transactions (riwayat saldo).
+const (
+	TransactionPurchase           = "purchase"            // top up Stars, Buy Me a Coffee, transfer manual
+	TransactionRefund             = "refund"              // kredit ditarik karena pembayaran di-refund
+	TransactionPromo              = "promo"               // hadiah kode promo
+	TransactionAdminCredit        = "admin_credit"        // /addcredits
+	TransactionGeneration         = "generation"          // generate gambar / remove bg / upscaler
+	TransactionVideo              = "video"               // generate video (diamond)
+	TransactionChat               = "chat"                // balasan chat AI
+	TransactionPrompt             = "prompt"              // prompt assistant
+	TransactionExchange           = "exchange"            // penukaran antar mata uang (dua baris)
+	TransactionReferralBonus      = "referral_bonus"      // bonus daftar / milestone program referral
+	TransactionReferralCommission = "referral_commission" // komisi dari pembelian Stars referee
+	TransactionReferralReversal   = "referral_reversal"   // hadiah referral ditarik admin
+	TransactionFreeRefill         = "free_refill"         // pengisian ulang free credit terjadwal
+	TransactionGiftSent           = "gift_sent"
+	TransactionGiftReceived       = "gift_received"
+	TransactionAccountDeleted     = "account_deleted" // saldo hangus karena /deleteme
+)
+
+// CreditTransaction adalah satu perubahan saldo user. Amount bertanda:
+// positif = saldo bertambah, negatif = saldo berkurang.
+type CreditTransaction struct {
+	ID             int64      `json:"id,omitempty"`
+	TelegramID     int64      `json:"telegram_id"`
+	Kind           string     `json:"kind"`
+	Currency       string     `json:"currency"` // ID mata uang wallet.json
+	Amount         int        `json:"amount"`
+	Description    string     `json:"description"`
+	CounterpartyID int64      `json:"counterparty_id,omitempty"` // user lain yang terlibat (gift)
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+}
+
+// RecordTransaction mencatat perubahan saldo ke riwayat. Kegagalan hanya di-log karena
+// saldo sudah berubah dan riwayat tidak boleh membatalkan operasi utamanya.
+func (c *Client) RecordTransaction(tx *CreditTransaction) {
+	var results []CreditTransaction
+	_, err := c.From("credit_transactions").Insert(tx, false, "", "", "exact").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to record %s transaction for user %d: %v", tx.Kind, tx.TelegramID, err)
+	}
+}
+
+// Record adalah pintasan RecordTransaction untuk transaksi tanpa lawan transaksi.
+// Amount 0 tidak dicatat.
+func (c *Client) Record(telegramID int64, kind, currency string, amount int, description string) {
+	if amount == 0 {
+		return
+	}
+	c.RecordTransaction(&CreditTransaction{
+		TelegramID:  telegramID,
+		Kind:        kind,
+		Currency:    currency,
+		Amount:      amount,
+		Description: description,
+	})
+}
+
+// GetTransactions mengambil riwayat saldo user, terbaru dulu, beserta jumlah total barisnya.
+func (c *Client) GetTransactions(telegramID int64, offset, limit int) ([]CreditTransaction, int, error) {
+	var results []CreditTransaction
+	count, err := c.From("credit_transactions").Select("*", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Order("id", &postgrest.OrderOpts{Ascending: false}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get transactions of user %d: %v", telegramID, err)
+		return nil, 0, err
+	}
+	return results, int(count), nil
+}
+
+// SumTransactions menjumlahkan Amount transaksi user dengan jenis tertentu sejak `since`.
+func (c *Client) SumTransactions(telegramID int64, kind string, since time.Time) (int, error) {
+	var results []CreditTransaction
+	_, err := c.From("credit_transactions").Select("amount", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("kind", kind).
+		Gte("created_at", since.UTC().Format(time.RFC3339)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to sum %s transactions of user %d: %v", kind, telegramID, err)
+		return 0, err
+	}
+	total := 0
+	for _, tx := range results {
+		total += tx.Amount
+	}
+	return total, nil
+}
+
+// SetBalanceIf mengubah satu kolom saldo hanya jika nilainya masih `expected`.
+// updated false berarti saldo sudah berubah sejak dibaca dan pemanggil perlu membaca ulang.
+func (c *Client) SetBalanceIf(telegramID int64, column string, expected, value int) (updated bool, err error) {
+	var results []User
+	_, err = c.From("users").Update(map[string]interface{}{column: value}, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq(column, strconv.Itoa(expected)).
+		Is("deleted_at", "null").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to update %s of user %d: %v", column, telegramID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+// ErrInsufficientBalance dikembalikan AdjustBalance jika saldo tidak cukup untuk dikurangi.
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// AdjustBalance menambahkan delta (boleh negatif) ke satu kolom saldo memakai SetBalanceIf,
+// dibaca ulang dan dicoba lagi jika saldo berubah di tengah jalan.
+func (c *Client) AdjustBalance(telegramID int64, column string, delta int) (balance int, err error) {
+	_, balance, err = c.updateBalance(telegramID, column, func(current int) (int, error) {
+		if current+delta < 0 {
+			return current, ErrInsufficientBalance
+		}
+		return current + delta, nil
+	})
+	return balance, err
+}
+
+// TakeBalance mengurangi satu kolom saldo sebanyak-banyaknya max tanpa membuatnya negatif.
+// max 0 hanya membaca saldo terbaru.
+func (c *Client) TakeBalance(telegramID int64, column string, max int) (taken, balance int, err error) {
+	before, balance, err := c.updateBalance(telegramID, column, func(current int) (int, error) {
+		take := max
+		if current < take {
+			take = current
+		}
+		if take < 0 {
+			take = 0
+		}
+		return current - take, nil
+	})
+	return before - balance, balance, err
+}
+
+// extraBalancePrefix menandai saldo currency tambahan dari wallet.json yang disimpan di jsonb balances.
+const extraBalancePrefix = "balances."
+
+// ExtraBalanceColumn mengembalikan nama "kolom" untuk AdjustBalance/TakeBalance bagi currency
+// yang tidak punya kolom sendiri di tabel users.
+func ExtraBalanceColumn(currency string) string {
+	return extraBalancePrefix + currency
+}
+
+// updateBalance membaca satu kolom angka, menghitung nilai barunya dengan next, lalu menyimpannya
+// dengan SetBalanceIf. Diulang jika kolom berubah di antaranya (penulisan bersamaan dari proses lain).
+// Saldo akun yang sudah dihapus tidak bisa diubah (ErrAccountDeleted).
+func (c *Client) updateBalance(telegramID int64, column string, next func(current int) (int, error)) (before, after int, err error) {
+	if strings.HasPrefix(column, extraBalancePrefix) {
+		return c.updateExtraBalance(telegramID, strings.TrimPrefix(column, extraBalancePrefix), next)
+	}
+	id := strconv.FormatInt(telegramID, 10)
+	for attempt := 0; attempt < 5; attempt++ {
+		var rows []map[string]interface{}
+		if _, err = c.From("users").Select(column+",deleted_at", "exact", false).Eq("telegram_id", id).ExecuteTo(&rows); err != nil {
+			log.Printf("ERROR: Failed to read %s of user %d: %v", column, telegramID, err)
+			return 0, 0, err
+		}
+		if len(rows) == 0 {
+			return 0, 0, fmt.Errorf("user %d not found", telegramID)
+		}
+		if rows[0]["deleted_at"] != nil {
+			return 0, 0, ErrAccountDeleted
+		}
+		current, _ := rows[0][column].(float64)
+		value, err := next(int(current))
+		if err != nil {
+			return int(current), int(current), err
+		}
+		if value == int(current) {
+			return value, value, nil
+		}
+		updated, err := c.SetBalanceIf(telegramID, column, int(current), value)
+		if err != nil {
+			return 0, 0, err
+		}
+		if updated {
+			return int(current), value, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("%s of user %d kept changing, giving up", column, telegramID)
+}
+
+// updateExtraBalance seperti updateBalance untuk satu currency di jsonb balances. Seluruh objek
+// balances hanya ditulis jika masih sama dengan yang dibaca, agar perubahan currency lain di
+// objek yang sama tidak tertimpa.
+func (c *Client) updateExtraBalance(telegramID int64, currency string, next func(current int) (int, error)) (before, after int, err error) {
+	id := strconv.FormatInt(telegramID, 10)
+	for attempt := 0; attempt < 5; attempt++ {
+		var rows []User
+		if _, err = c.From("users").Select("balances,deleted_at", "exact", false).Eq("telegram_id", id).ExecuteTo(&rows); err != nil {
+			log.Printf("ERROR: Failed to read balances of user %d: %v", telegramID, err)
+			return 0, 0, err
+		}
+		if len(rows) == 0 {
+			return 0, 0, fmt.Errorf("user %d not found", telegramID)
+		}
+		if rows[0].IsDeleted() {
+			return 0, 0, ErrAccountDeleted
+		}
+		balances := rows[0].Balances
+		if balances == nil {
+			balances = map[string]int{}
+		}
+		current := balances[currency]
+		value, err := next(current)
+		if err != nil {
+			return current, current, err
+		}
+		if value == current {
+			return value, value, nil
+		}
+		updated := make(map[string]int, len(balances)+1)
+		for k, v := range balances {
+			updated[k] = v
+		}
+		updated[currency] = value
+		ok, err := c.setBalancesIf(telegramID, balances, updated)
+		if err != nil {
+			return 0, 0, err
+		}
+		if ok {
+			return current, value, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("balance %s of user %d kept changing, giving up", currency, telegramID)
+}
+
+// setBalancesIf menulis jsonb balances hanya jika isinya masih `expected`.
+func (c *Client) setBalancesIf(telegramID int64, expected, value map[string]int) (updated bool, err error) {
+	current, err := json.Marshal(expected)
+	if err != nil {
+		return false, err
+	}
+	var results []User
+	_, err = c.From("users").Update(map[string]interface{}{"balances": value}, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("balances", string(current)).
+		Is("deleted_at", "null").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to update balances of user %d: %v", telegramID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+// IncrementGeneratedImages menambah generated_image_count satu user dan mengembalikan nilai barunya.
+func (c *Client) IncrementGeneratedImages(telegramID int64) (int, error) {
+	return c.AdjustBalance(telegramID, "generated_image_count", 1)
+}
+
+// GetUserByUsername mencari user berdasarkan username Telegram (tanpa '@', tidak peka huruf besar).
+func (c *Client) GetUserByUsername(username string) (*User, error) {
+	var results []User
+	_, err := c.From("users").Select("*", "exact", false).Ilike("username", escapeLike(username)).Limit(1, "").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get user @%s: %v", username, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// escapeLike meng-escape wildcard ILIKE. '_' dan '%' adalah wildcard, padahal '_' umum dipakai di username.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "_", `\_`, "%", `\%`).Replace(value)
+}
+
+// SearchUsers mencari user berdasarkan Telegram ID (angka) atau potongan username, terbaru dulu.
+// Query kosong mengembalikan user terbaru.
+func (c *Client) SearchUsers(query string, limit int) ([]User, error) {
+	q := c.From("users").Select("*", "exact", false)
+	query = strings.TrimPrefix(strings.TrimSpace(query), "@")
+	if query != "" {
+		if _, err := strconv.ParseInt(query, 10, 64); err == nil {
+			q = q.Eq("telegram_id", query)
+		} else {
+			q = q.Ilike("username", "%"+escapeLike(query)+"%")
+		}
+	}
+	var results []User
+	_, err := q.Order("created_at", &postgrest.OrderOpts{Ascending: false}).Limit(limit, "").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to search users for %q: %v", query, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// HasCompletedPurchase memeriksa apakah user pernah menyelesaikan pembayaran
+// (Stars/Buy Me a Coffee atau transfer manual yang sudah disetujui).
+func (c *Client) HasCompletedPurchase(telegramID int64) (bool, error) {
+	id := strconv.FormatInt(telegramID, 10)
+
+	var payments []Payment
+	_, err := c.From("payments").Select("charge_id", "exact", false).
+		Eq("telegram_id", id).
+		Eq("status", PaymentStatusCompleted).
+		Limit(1, "").
+		ExecuteTo(&payments)
+	if err != nil {
+		log.Printf("ERROR: Failed to check payments of user %d: %v", telegramID, err)
+		return false, err
+	}
+	if len(payments) > 0 {
+		return true, nil
+	}
+
+	var manual []ManualPayment
+	_, err = c.From("manual_payments").Select("id", "exact", false).
+		Eq("telegram_id", id).
+		Eq("status", ManualPaymentApproved).
+		Limit(1, "").
+		ExecuteTo(&manual)
+	if err != nil {
+		log.Printf("ERROR: Failed to check manual payments of user %d: %v", telegramID, err)
+		return false, err
+	}
+	return len(manual) > 0, nil
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Status baris di tabel manual_payments.
+const (
+	ManualPaymentPending  = "pending"
+	ManualPaymentApproved = "approved"
+	ManualPaymentRejected = "rejected"
+)
+
+type ManualPayment struct {
+	ID          int64      `json:"id,omitempty"`
+	TelegramID  int64      `json:"telegram_id"`
+	PackageID   string     `json:"package_id"`
+	Credits     int        `json:"credits"`
+	Price       int        `json:"price"`
+	Currency    string     `json:"currency"`
+	ProofFileID string     `json:"proof_file_id"`
+	Status      string     `json:"status"`
+	ReviewedBy  int64      `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+func (c *Client) CreateManualPayment(payment *ManualPayment) (*ManualPayment, error) {
+	var results []ManualPayment
+	_, err := c.From("manual_payments").Insert(payment, false, "", "", "exact").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to create manual payment for user %d: %v", payment.TelegramID, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return payment, nil
+	}
+	return &results[0], nil
+}
+
+// GetPendingManualPayment mengembalikan permintaan manual user yang masih menunggu review (atau nil).
+func (c *Client) GetPendingManualPayment(telegramID int64) (*ManualPayment, error) {
+	var results []ManualPayment
+	_, err := c.From("manual_payments").Select("*", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("status", ManualPaymentPending).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get pending manual payment of user %d: %v", telegramID, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// ReviewManualPayment memindahkan status dari `from` ke `to` secara bersyarat.
+// Mengembalikan nil (tanpa error) jika status sudah bukan `from`, misalnya karena
+// admin lain sudah memprosesnya lebih dulu.
+func (c *Client) ReviewManualPayment(id int64, from, to string, adminID int64) (*ManualPayment, error) {
+	update := map[string]interface{}{
+		"status":      to,
+		"reviewed_by": adminID,
+		"reviewed_at": time.Now().UTC(),
+	}
+	var results []ManualPayment
+	_, err := c.From("manual_payments").Update(update, "", "exact").
+		Eq("id", strconv.FormatInt(id, 10)).
+		Eq("status", from).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to move manual payment %d from %s to %s: %v", id, from, to, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// GetRecentManualPayments mengambil transfer manual semua user, terbaru dulu.
+func (c *Client) GetRecentManualPayments(limit int) ([]ManualPayment, error) {
+	var results []ManualPayment
+	_, err := c.From("manual_payments").Select("*", "exact", false).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get recent manual payments: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
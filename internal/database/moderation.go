@@ -0,0 +1,70 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// IsSuspended memeriksa apakah suspend user masih berlaku.
+func (u *User) IsSuspended(now time.Time) bool {
+	return u.SuspendedUntil != nil && now.Before(*u.SuspendedUntil)
+}
+
+// IsBlocked: user dibanned atau sedang disuspend.
+func (u *User) IsBlocked(now time.Time) bool {
+	return u.IsBanned || u.IsSuspended(now)
+}
+
+// Kolom is_banned, ban_reason dan suspended_until hanya ditulis lewat SetBanned dan SetSuspendedUntil
+// (/ban, /unban, /suspend dan strike otomatis). UpdateUser sengaja tidak menyertakannya: salinan user
+// yang dibaca sebelum generate panjang tidak boleh membuka kembali ban yang diberikan di tengah jalan.
+
+// SetBanned membanned atau membuka ban user. Membuka ban juga menghapus suspend.
+func (c *Client) SetBanned(telegramID int64, banned bool, reason string) error {
+	update := map[string]interface{}{
+		"is_banned":  banned,
+		"ban_reason": reason,
+	}
+	if !banned {
+		update["suspended_until"] = nil
+	}
+	return c.updateModeration(telegramID, update)
+}
+
+// SetSuspendedUntil menyuspend user sampai waktu tertentu.
+func (c *Client) SetSuspendedUntil(telegramID int64, until time.Time, reason string) error {
+	return c.updateModeration(telegramID, map[string]interface{}{
+		"suspended_until": until.UTC().Format(time.RFC3339),
+		"ban_reason":      reason,
+	})
+}
+
+func (c *Client) updateModeration(telegramID int64, update map[string]interface{}) error {
+	var results []User
+	_, err := c.From("users").Update(update, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to update moderation status of user %d: %v", telegramID, err)
+	}
+	return err
+}
+
+// GetTransactionsByKinds mengambil riwayat saldo user untuk jenis tertentu saja, terbaru dulu.
+func (c *Client) GetTransactionsByKinds(telegramID int64, kinds []string, limit int) ([]CreditTransaction, error) {
+	var results []CreditTransaction
+	_, err := c.From("credit_transactions").Select("*", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		In("kind", kinds).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get transactions of user %d: %v", telegramID, err)
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Status baris di tabel payments.
+const (
+	PaymentStatusPending   = "pending"   // sudah tercatat, kredit/premium belum diberikan
+	PaymentStatusCompleted = "completed" // kredit/premium sudah diberikan
+	PaymentStatusFailed    = "failed"    // gagal diberikan, perlu dicek admin
+	PaymentStatusRefunded  = "refunded"  // Stars sudah dikembalikan lewat refundStarPayment
+)
+
+// Jenis produk yang dibayar.
+const (
+	ProductTypeCredits      = "credits"
+	ProductTypeSubscription = "subscription"
+)
+
+type Payment struct {
+	ChargeID         string     `json:"charge_id"` // telegram_payment_charge_id
+	ProviderChargeID string     `json:"provider_charge_id"`
+	TelegramID       int64      `json:"telegram_id"`
+	Payload          string     `json:"payload"`
+	ProductType      string     `json:"product_type"`
+	Currency         string     `json:"currency"`
+	Amount           int        `json:"amount"`
+	Credits          int        `json:"credits"`
+	Status           string     `json:"status"`
+	CreatedAt        *time.Time `json:"created_at,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	RefundedAt       *time.Time `json:"refunded_at,omitempty"`
+	RefundedBy       int64      `json:"refunded_by,omitempty"` // 0 = refund otomatis
+	RefundReason     string     `json:"refund_reason,omitempty"`
+	CreditsReversed  int        `json:"credits_reversed,omitempty"`
+}
+
+// isDuplicateKey mendeteksi pelanggaran unique/primary key (kode Postgres 23505).
+func isDuplicateKey(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "(23505)")
+}
+
+// InsertPayment mencatat pembayaran baru. Jika charge ID sudah pernah tercatat,
+// inserted bernilai false tanpa error sehingga pemanggil bisa mengabaikan update duplikat.
+func (c *Client) InsertPayment(payment *Payment) (inserted bool, err error) {
+	var results []Payment
+	_, err = c.From("payments").Insert(payment, false, "", "", "exact").ExecuteTo(&results)
+	if isDuplicateKey(err) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to record payment %s for user %d: %v", payment.ChargeID, payment.TelegramID, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// SetPaymentStatus memperbarui status pembayaran (completed_at diisi saat completed).
+func (c *Client) SetPaymentStatus(chargeID, status string) error {
+	update := map[string]interface{}{"status": status}
+	if status == PaymentStatusCompleted {
+		update["completed_at"] = time.Now().UTC()
+	}
+	var results []Payment
+	_, err := c.From("payments").Update(update, "", "exact").Eq("charge_id", chargeID).ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to set payment %s status to %s: %v", chargeID, status, err)
+	}
+	return err
+}
+
+// GetPaymentByChargeID mengambil satu pembayaran, nil jika tidak ada.
+func (c *Client) GetPaymentByChargeID(chargeID string) (*Payment, error) {
+	var results []Payment
+	_, err := c.From("payments").Select("*", "exact", false).Eq("charge_id", chargeID).ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get payment %s: %v", chargeID, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// GetUserPayments mengambil riwayat pembayaran user, terbaru dulu.
+func (c *Client) GetUserPayments(telegramID int64, limit int) ([]Payment, error) {
+	var results []Payment
+	_, err := c.From("payments").Select("*", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get payments of user %d: %v", telegramID, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetRecentPayments mengambil pembayaran semua user, terbaru dulu.
+func (c *Client) GetRecentPayments(limit int) ([]Payment, error) {
+	var results []Payment
+	_, err := c.From("payments").Select("*", "exact", false).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get recent payments: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// MarkPaymentRefunded menandai pembayaran sebagai refunded. Update bersyarat (status belum refunded)
+// sehingga dua refund bersamaan tidak sama-sama tercatat; updated false jika sudah refunded.
+func (c *Client) MarkPaymentRefunded(chargeID string, refundedBy int64, reason string, creditsReversed int) (updated bool, err error) {
+	update := map[string]interface{}{
+		"status":           PaymentStatusRefunded,
+		"refunded_at":      time.Now().UTC(),
+		"refunded_by":      refundedBy,
+		"refund_reason":    reason,
+		"credits_reversed": creditsReversed,
+	}
+	var results []Payment
+	_, err = c.From("payments").Update(update, "", "exact").
+		Eq("charge_id", chargeID).
+		Neq("status", PaymentStatusRefunded).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to mark payment %s as refunded: %v", chargeID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Jenis hadiah promo code.
+const (
+	PromoRewardCredits  = "credits"  // kredit berbayar langsung
+	PromoRewardDiamonds = "diamonds" // diamond langsung
+	PromoRewardBonus    = "bonus"    // bonus kredit (%) pada pembelian Stars berikutnya
+)
+
+type PromoCode struct {
+	Code         string     `json:"code"`
+	RewardType   string     `json:"reward_type"`
+	RewardAmount int        `json:"reward_amount"`
+	MaxUses      int        `json:"max_uses"`       // 0 = tanpa batas
+	PerUserLimit int        `json:"per_user_limit"` // 0 = tanpa batas
+	Uses         int        `json:"uses"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	Campaign     string     `json:"campaign"`
+	Active       bool       `json:"active"`
+	CreatedBy    int64      `json:"created_by"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+type PromoRedemption struct {
+	ID           int64      `json:"id,omitempty"`
+	Code         string     `json:"code"`
+	TelegramID   int64      `json:"telegram_id"`
+	Campaign     string     `json:"campaign"`
+	RewardType   string     `json:"reward_type"`
+	RewardAmount int        `json:"reward_amount"`
+	UseNumber    int        `json:"use_number,omitempty"`  // pemakaian ke-n oleh user ini, 0 jika kode tanpa batas per user
+	ChargeID     string     `json:"charge_id,omitempty"`   // pembayaran Stars yang memakai bonus
+	ConsumedAt   *time.Time `json:"consumed_at,omitempty"` // hanya untuk bonus
+	RedeemedAt   *time.Time `json:"redeemed_at,omitempty"`
+}
+
+// CreatePromoCode menyimpan promo code baru; created false jika kode sudah ada.
+func (c *Client) CreatePromoCode(promo *PromoCode) (created bool, err error) {
+	var results []PromoCode
+	_, err = c.From("promo_codes").Insert(promo, false, "", "", "exact").ExecuteTo(&results)
+	if isDuplicateKey(err) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to create promo code %s: %v", promo.Code, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// GetPromoCode mengambil promo code, nil jika tidak ada.
+func (c *Client) GetPromoCode(code string) (*PromoCode, error) {
+	var results []PromoCode
+	_, err := c.From("promo_codes").Select("*", "exact", false).Eq("code", code).ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get promo code %s: %v", code, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// ListPromoCodes mengambil promo code terbaru, opsional difilter per campaign.
+func (c *Client) ListPromoCodes(campaign string, limit int) ([]PromoCode, error) {
+	query := c.From("promo_codes").Select("*", "exact", false)
+	if campaign != "" {
+		query = query.Eq("campaign", campaign)
+	}
+	var results []PromoCode
+	_, err := query.Order("created_at", &postgrest.OrderOpts{Ascending: false}).Limit(limit, "").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to list promo codes: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// SetPromoCodeActive mengaktifkan/menonaktifkan promo code; updated false jika kode tidak ada.
+func (c *Client) SetPromoCodeActive(code string, active bool) (updated bool, err error) {
+	var results []PromoCode
+	_, err = c.From("promo_codes").Update(map[string]interface{}{"active": active}, "", "exact").
+		Eq("code", code).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to set promo code %s active=%v: %v", code, active, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+// IncrementPromoUses menaikkan counter uses secara bersyarat (optimistic lock pada nilai lama),
+// sehingga dua redeem bersamaan tidak bisa melewati max_uses. updated false jika nilai sudah berubah.
+func (c *Client) IncrementPromoUses(code string, current int) (updated bool, err error) {
+	var results []PromoCode
+	_, err = c.From("promo_codes").Update(map[string]interface{}{"uses": current + 1}, "", "exact").
+		Eq("code", code).
+		Eq("uses", strconv.Itoa(current)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to increment uses of promo code %s: %v", code, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+// DecrementPromoUses mengembalikan counter uses jika pencatatan redeem gagal.
+func (c *Client) DecrementPromoUses(code string, current int) error {
+	var results []PromoCode
+	_, err := c.From("promo_codes").Update(map[string]interface{}{"uses": current - 1}, "", "exact").
+		Eq("code", code).
+		Eq("uses", strconv.Itoa(current)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to decrement uses of promo code %s: %v", code, err)
+	}
+	return err
+}
+
+// CountUserPromoRedemptions menghitung berapa kali user sudah memakai sebuah kode.
+func (c *Client) CountUserPromoRedemptions(code string, telegramID int64) (int, error) {
+	var results []PromoRedemption
+	_, err := c.From("promo_redemptions").Select("id", "exact", false).
+		Eq("code", code).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to count redemptions of %s by user %d: %v", code, telegramID, err)
+		return 0, err
+	}
+	return len(results), nil
+}
+
+// InsertPromoRedemption mencatat pemakaian promo. inserted false berarti UseNumber yang sama sudah
+// tercatat (redeem bersamaan dari user yang sama), sehingga batas per user tidak terlewati.
+func (c *Client) InsertPromoRedemption(redemption *PromoRedemption) (inserted bool, err error) {
+	var results []PromoRedemption
+	_, err = c.From("promo_redemptions").Insert(redemption, false, "", "", "exact").ExecuteTo(&results)
+	if isDuplicateKey(err) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to record redemption of %s by user %d: %v", redemption.Code, redemption.TelegramID, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// GetPendingPromoBonus mengambil bonus pembelian yang belum terpakai milik user (yang paling lama), nil jika tidak ada.
+func (c *Client) GetPendingPromoBonus(telegramID int64) (*PromoRedemption, error) {
+	var results []PromoRedemption
+	_, err := c.From("promo_redemptions").Select("*", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("reward_type", PromoRewardBonus).
+		Is("consumed_at", "null").
+		Order("redeemed_at", &postgrest.OrderOpts{Ascending: true}).
+		Limit(1, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get pending promo bonus of user %d: %v", telegramID, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// ConsumePromoBonus menandai bonus sudah dipakai oleh pembayaran chargeID (bersyarat: belum dipakai).
+func (c *Client) ConsumePromoBonus(id int64, chargeID string) (updated bool, err error) {
+	update := map[string]interface{}{
+		"consumed_at": time.Now().UTC(),
+		"charge_id":   chargeID,
+	}
+	var results []PromoRedemption
+	_, err = c.From("promo_redemptions").Update(update, "", "exact").
+		Eq("id", strconv.FormatInt(id, 10)).
+		Is("consumed_at", "null").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to consume promo bonus %d: %v", id, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+// GetPromoRedemptions mengambil redemption untuk statistik, difilter per kode atau per campaign.
+func (c *Client) GetPromoRedemptions(code, campaign string) ([]PromoRedemption, error) {
+	query := c.From("promo_redemptions").Select("*", "exact", false)
+	if code != "" {
+		query = query.Eq("code", code)
+	}
+	if campaign != "" {
+		query = query.Eq("campaign", campaign)
+	}
+	var results []PromoRedemption
+	_, err := query.ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get promo redemptions (code=%s, campaign=%s): %v", code, campaign, err)
+		return nil, err
+	}
+	return results, nil
+}
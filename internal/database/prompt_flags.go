@@ -0,0 +1,104 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Status tinjauan prompt yang diblokir.
+const (
+	FlagPending   = "pending"
+	FlagConfirmed = "confirmed"
+	FlagDismissed = "dismissed" // salah blokir: tidak lagi dihitung sebagai strike
+)
+
+// PromptFlag adalah satu prompt yang diblokir pemeriksaan safety atau ditolak filter NSFW model.
+type PromptFlag struct {
+	ID         int64      `json:"id,omitempty"`
+	TelegramID int64      `json:"telegram_id"`
+	ModelID    string     `json:"model_id"`
+	Prompt     string     `json:"prompt"`
+	Category   string     `json:"category"`
+	Source     string     `json:"source"`
+	Match      string     `json:"match"`
+	Strike     bool       `json:"strike"`
+	Status     string     `json:"status,omitempty"`
+	ReviewedBy *int64     `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+func (c *Client) CreatePromptFlag(flag *PromptFlag) (*PromptFlag, error) {
+	var results []PromptFlag
+	_, err := c.From("prompt_flags").Insert(flag, false, "", "", "exact").ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to record prompt flag for user %d: %v", flag.TelegramID, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return flag, nil
+	}
+	return &results[0], nil
+}
+
+// CountStrikes menghitung strike user sejak waktu tertentu. Flag yang sudah di-dismiss admin tidak dihitung.
+func (c *Client) CountStrikes(telegramID int64, since time.Time) (int, error) {
+	var results []PromptFlag
+	count, err := c.From("prompt_flags").
+		Select("id", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("strike", "true").
+		Neq("status", FlagDismissed).
+		Gte("created_at", since.UTC().Format(time.RFC3339)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to count strikes for user %d: %v", telegramID, err)
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// GetPromptFlags mengambil flag terbaru dulu. telegramID 0 = semua user, status kosong = semua status.
+func (c *Client) GetPromptFlags(telegramID int64, status string, limit int) ([]PromptFlag, int, error) {
+	query := c.From("prompt_flags").Select("*", "exact", false)
+	if telegramID != 0 {
+		query = query.Eq("telegram_id", strconv.FormatInt(telegramID, 10))
+	}
+	if status != "" {
+		query = query.Eq("status", status)
+	}
+	var results []PromptFlag
+	count, err := query.
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get prompt flags: %v", err)
+		return nil, 0, err
+	}
+	return results, int(count), nil
+}
+
+// ReviewPromptFlag menyimpan keputusan admin. Mengembalikan nil jika flag tidak ditemukan.
+func (c *Client) ReviewPromptFlag(id int64, status string, reviewerID int64) (*PromptFlag, error) {
+	var results []PromptFlag
+	_, err := c.From("prompt_flags").
+		Update(map[string]interface{}{
+			"status":      status,
+			"reviewed_by": reviewerID,
+			"reviewed_at": time.Now().UTC(),
+		}, "", "exact").
+		Eq("id", strconv.FormatInt(id, 10)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to review prompt flag %d: %v", id, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
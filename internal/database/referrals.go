@@ -0,0 +1,284 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Jenis hadiah referral.
+const (
+	ReferralRewardSignup     = "signup"     // bonus untuk referee saat daftar lewat link
+	ReferralRewardMilestone  = "milestone"  // bonus untuk referrer saat referee mencapai milestone
+	ReferralRewardCommission = "commission" // komisi referrer dari pembelian Stars referee
+)
+
+// Status baris di tabel referral_rewards.
+const (
+	ReferralRewardPaid     = "paid"
+	ReferralRewardFailed   = "failed"   // tercatat tapi saldo gagal ditambahkan
+	ReferralRewardHeld     = "held"     // ditahan deteksi fraud, menunggu keputusan admin
+	ReferralRewardDenied   = "denied"   // ditolak (otomatis oleh deteksi fraud atau oleh admin)
+	ReferralRewardReversed = "reversed" // sudah dibayar lalu ditarik kembali oleh admin
+)
+
+// ReferralReward adalah satu hadiah dari program referral. Kombinasi (referee_id, kind, reference)
+// unik sehingga milestone/komisi yang sama tidak pernah dibayar dua kali.
+type ReferralReward struct {
+	ID            int64      `json:"id,omitempty"`
+	BeneficiaryID int64      `json:"beneficiary_id"` // penerima hadiah (referrer, atau referee untuk signup)
+	ReferrerID    int64      `json:"referrer_id"`
+	RefereeID     int64      `json:"referee_id"`
+	Kind          string     `json:"kind"`
+	Reference     string     `json:"reference"` // ID milestone, charge ID pembayaran, atau "signup"
+	Currency      string     `json:"currency"`
+	Amount        int        `json:"amount"`
+	Status        string     `json:"status"`
+	RiskScore     int        `json:"risk_score"`
+	RiskReasons   string     `json:"risk_reasons,omitempty"` // sinyal fraud yang terdeteksi, dipisah koma
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+}
+
+// InsertReferralReward mencatat hadiah. inserted false tanpa error berarti hadiah ini sudah pernah tercatat.
+func (c *Client) InsertReferralReward(reward *ReferralReward) (inserted bool, err error) {
+	var results []ReferralReward
+	_, err = c.From("referral_rewards").Insert(reward, false, "", "", "exact").ExecuteTo(&results)
+	if isDuplicateKey(err) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to record %s referral reward for user %d: %v", reward.Kind, reward.BeneficiaryID, err)
+		return false, err
+	}
+	if len(results) > 0 {
+		*reward = results[0]
+	}
+	return true, nil
+}
+
+func (c *Client) SetReferralRewardStatus(id int64, status string) error {
+	var results []ReferralReward
+	_, err := c.From("referral_rewards").Update(map[string]interface{}{"status": status}, "", "exact").
+		Eq("id", strconv.FormatInt(id, 10)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to set status of referral reward %d: %v", id, err)
+	}
+	return err
+}
+
+// GetReferralRewardsOf mengambil semua hadiah yang diterima user sebagai referrer.
+func (c *Client) GetReferralRewardsOf(referrerID int64) ([]ReferralReward, error) {
+	var results []ReferralReward
+	id := strconv.FormatInt(referrerID, 10)
+	_, err := c.From("referral_rewards").Select("*", "exact", false).
+		Eq("referrer_id", id).
+		Eq("beneficiary_id", id).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get referral rewards of user %d: %v", referrerID, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetMilestoneRewards mengambil semua hadiah milestone yang sudah dibayar (untuk leaderboard).
+func (c *Client) GetMilestoneRewards() ([]ReferralReward, error) {
+	var results []ReferralReward
+	_, err := c.From("referral_rewards").Select("referrer_id,referee_id", "exact", false).
+		Eq("kind", ReferralRewardMilestone).
+		Eq("status", ReferralRewardPaid).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get referral milestone rewards: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetReferees mengambil user yang mendaftar lewat link referral user ini, terbaru dulu.
+func (c *Client) GetReferees(referrerID int64) ([]User, error) {
+	var results []User
+	_, err := c.From("users").Select("*", "exact", false).
+		Eq("referrer_id", strconv.FormatInt(referrerID, 10)).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get referees of user %d: %v", referrerID, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetUsersByIDs mengambil beberapa user sekaligus.
+func (c *Client) GetUsersByIDs(ids []int64) ([]User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = strconv.FormatInt(id, 10)
+	}
+	var results []User
+	_, err := c.From("users").Select("*", "exact", false).In("telegram_id", values).ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get %d users: %v", len(ids), err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// GetPurchasers mengembalikan ID user (dari daftar ids) yang punya pembayaran Stars/BMAC selesai.
+func (c *Client) GetPurchasers(ids []int64) (map[int64]bool, error) {
+	purchasers := make(map[int64]bool)
+	if len(ids) == 0 {
+		return purchasers, nil
+	}
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = strconv.FormatInt(id, 10)
+	}
+	var results []Payment
+	_, err := c.From("payments").Select("telegram_id", "exact", false).
+		In("telegram_id", values).
+		Eq("status", PaymentStatusCompleted).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get purchasers: %v", err)
+		return nil, err
+	}
+	for _, p := range results {
+		purchasers[p.TelegramID] = true
+	}
+	return purchasers, nil
+}
+
+func (c *Client) GetReferralReward(id int64) (*ReferralReward, error) {
+	var results []ReferralReward
+	_, err := c.From("referral_rewards").Select("*", "exact", false).
+		Eq("id", strconv.FormatInt(id, 10)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get referral reward %d: %v", id, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// GetReferralRewardByReference mengambil hadiah berdasarkan jenis dan referensinya,
+// misalnya komisi dari satu charge ID.
+func (c *Client) GetReferralRewardByReference(kind, reference string) (*ReferralReward, error) {
+	var results []ReferralReward
+	_, err := c.From("referral_rewards").Select("*", "exact", false).
+		Eq("kind", kind).
+		Eq("reference", reference).
+		Limit(1, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get %s referral reward for %s: %v", kind, reference, err)
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// SetReferralRewardStatusIf mengubah status hanya jika status saat ini masih `from`,
+// agar satu hadiah tidak diproses dua kali oleh dua admin sekaligus.
+func (c *Client) SetReferralRewardStatusIf(id int64, from, to string) (updated bool, err error) {
+	var results []ReferralReward
+	_, err = c.From("referral_rewards").Update(map[string]interface{}{"status": to}, "", "exact").
+		Eq("id", strconv.FormatInt(id, 10)).
+		Eq("status", from).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to set status of referral reward %d: %v", id, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+// GetReferralRewardsByReferrer mengambil hadiah dari undangan seorang referrer (termasuk bonus
+// daftar milik referee), terbaru dulu. status kosong berarti semua status.
+func (c *Client) GetReferralRewardsByReferrer(referrerID int64, status string, limit int) ([]ReferralReward, error) {
+	var results []ReferralReward
+	query := c.From("referral_rewards").Select("*", "exact", false).
+		Eq("referrer_id", strconv.FormatInt(referrerID, 10))
+	if status != "" {
+		query = query.Eq("status", status)
+	}
+	_, err := query.Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get referral rewards from referrer %d: %v", referrerID, err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// ReferralFlag menandai referrer yang perlu diperiksa admin.
+type ReferralFlag struct {
+	ReferrerID int64      `json:"referrer_id"`
+	Score      int        `json:"score"`
+	Reasons    string     `json:"reasons"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+// FlagReferrer menandai referrer. inserted false berarti referrer sudah ditandai sebelumnya.
+func (c *Client) FlagReferrer(flag *ReferralFlag) (inserted bool, err error) {
+	var results []ReferralFlag
+	_, err = c.From("referral_flags").Insert(flag, false, "", "", "exact").ExecuteTo(&results)
+	if isDuplicateKey(err) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to flag referrer %d: %v", flag.ReferrerID, err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Client) IsReferrerFlagged(referrerID int64) (bool, error) {
+	var results []ReferralFlag
+	_, err := c.From("referral_flags").Select("referrer_id", "exact", false).
+		Eq("referrer_id", strconv.FormatInt(referrerID, 10)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to check flag of referrer %d: %v", referrerID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+func (c *Client) GetReferralFlags() ([]ReferralFlag, error) {
+	var results []ReferralFlag
+	_, err := c.From("referral_flags").Select("*", "exact", false).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get referral flags: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// ClearReferralFlag menghapus tanda referrer setelah diperiksa admin.
+func (c *Client) ClearReferralFlag(referrerID int64) (cleared bool, err error) {
+	var results []ReferralFlag
+	_, err = c.From("referral_flags").Delete("", "exact").
+		Eq("referrer_id", strconv.FormatInt(referrerID, 10)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to clear flag of referrer %d: %v", referrerID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// SpendLimits adalah pengaman belanja yang diatur user lewat /limits. Nilai 0 = nonaktif.
+type SpendLimits struct {
+	Daily        int  `json:"daily,omitempty"`         // maksimal kredit terpakai per 24 jam
+	Weekly       int  `json:"weekly,omitempty"`        // maksimal kredit terpakai per 7 hari
+	ConfirmAbove int  `json:"confirm_above,omitempty"` // generate dengan biaya >= nilai ini minta konfirmasi
+	LowBalance   *int `json:"low_balance,omitempty"`   // nil = pakai default config, 0 = tanpa notifikasi
+	LowNotified  bool `json:"low_notified,omitempty"`  // notifikasi saldo rendah sudah dikirim
+}
+
+// SetSpendLimits hanya memperbarui kolom spend_limits agar tidak menimpa saldo yang sedang berubah.
+func (c *Client) SetSpendLimits(telegramID int64, limits SpendLimits) error {
+	var results []User
+	_, err := c.From("users").Update(map[string]interface{}{"spend_limits": limits}, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Is("deleted_at", "null").
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to update spend limits of user %d: %v", telegramID, err)
+	}
+	return err
+}
+
+// spendingKinds adalah jenis transaksi yang dihitung sebagai belanja untuk /limits. Penukaran
+// (exchange) dan gift hanya memindahkan saldo, bukan memakainya.
+var spendingKinds = []string{TransactionGeneration, TransactionVideo, TransactionChat, TransactionPrompt}
+
+// SumSpent menjumlahkan pengeluaran user (generate, video, chat AI, prompt assistant) dalam satu mata uang sejak `since`.
+func (c *Client) SumSpent(telegramID int64, currency string, since time.Time) (int, error) {
+	var results []CreditTransaction
+	_, err := c.From("credit_transactions").Select("amount", "exact", false).
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("currency", currency).
+		In("kind", spendingKinds).
+		Gte("created_at", since.UTC().Format(time.RFC3339)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to sum spending of user %d: %v", telegramID, err)
+		return 0, err
+	}
+	spent := 0
+	for _, tx := range results {
+		spent -= tx.Amount
+	}
+	return spent, nil
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"log"
+	"strconv"
+	"time"
+)
+
+// GetLapsedPremiumUsers mengambil user premium yang masa aktifnya sudah lewat sebelum `before`.
+// User premium tanpa premium_expires_at (diset manual) tidak ikut terambil.
+func (c *Client) GetLapsedPremiumUsers(before time.Time) ([]User, error) {
+	var results []User
+	_, err := c.From("users").Select("*", "exact", false).
+		Eq("is_premium", "true").
+		Lt("premium_expires_at", before.UTC().Format(time.RFC3339)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to get lapsed premium users: %v", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// SetSubscription menyimpan kolom langganan user (is_premium, premium_expires_at, subscription_charge_id,
+// subscription_period_charge_id, subscription_canceled) tanpa menyentuh kolom lain.
+func (c *Client) SetSubscription(user *User) error {
+	return c.updateSubscription(user.TelegramID, map[string]interface{}{
+		"is_premium":                    user.IsPremium,
+		"premium_expires_at":            user.PremiumExpiresAt,
+		"subscription_charge_id":        user.SubscriptionChargeID,
+		"subscription_period_charge_id": user.PeriodChargeID,
+		"subscription_canceled":         user.SubscriptionCanceled,
+	})
+}
+
+// SetSubscriptionCanceled hanya mengubah status perpanjangan otomatis.
+func (c *Client) SetSubscriptionCanceled(telegramID int64, canceled bool) error {
+	return c.updateSubscription(telegramID, map[string]interface{}{"subscription_canceled": canceled})
+}
+
+// ExpireSubscription menurunkan user ke tier free, hanya jika premium_expires_at masih sebelum `before`
+// (perpanjangan yang masuk bersamaan tidak ikut terhapus). expired false berarti tidak ada yang diubah.
+func (c *Client) ExpireSubscription(telegramID int64, before time.Time) (expired bool, err error) {
+	var results []User
+	_, err = c.From("users").Update(map[string]interface{}{
+		"is_premium":                    false,
+		"subscription_charge_id":        "",
+		"subscription_period_charge_id": "",
+		"subscription_canceled":         false,
+	}, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("is_premium", "true").
+		Lt("premium_expires_at", before.UTC().Format(time.RFC3339)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to expire subscription of user %d: %v", telegramID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+// RevokeSubscription mencabut premium karena pembayaran langganan di-refund, hanya jika chargeID
+// masih pembayaran periode yang sedang berjalan. revoked false berarti periodenya sudah berganti
+// (diperpanjang atau berakhir) dan tidak ada yang diubah.
+func (c *Client) RevokeSubscription(telegramID int64, chargeID string) (revoked bool, err error) {
+	var results []User
+	_, err = c.From("users").Update(map[string]interface{}{
+		"is_premium":                    false,
+		"premium_expires_at":            nil,
+		"subscription_charge_id":        "",
+		"subscription_period_charge_id": "",
+		"subscription_canceled":         false,
+	}, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		Eq("subscription_period_charge_id", chargeID).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to revoke subscription of user %d: %v", telegramID, err)
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+func (c *Client) updateSubscription(telegramID int64, update map[string]interface{}) error {
+	var results []User
+	_, err := c.From("users").Update(update, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(telegramID, 10)).
+		ExecuteTo(&results)
+	if err != nil {
+		log.Printf("ERROR: Failed to update subscription of user %d: %v", telegramID, err)
+	}
+	return err
+}
@@ -25,6 +25,19 @@ type User struct {
 	AspectRatio         string    `json:"aspect_ratio"` // <-- Tambahkan ini
 	NumOutputs          int       `json:"num_outputs"`
 	CustomSettings       string    `json:"custom_settings,omitempty"`
+	PremiumExpiresAt     *time.Time `json:"premium_expires_at"`     // nil = premium tanpa batas waktu (diset manual)
+	SubscriptionChargeID string     `json:"subscription_charge_id"` // charge ID pembayaran pertama langganan Stars
+	PeriodChargeID       string     `json:"subscription_period_charge_id"` // charge ID pembayaran periode langganan yang sedang berjalan
+	SubscriptionCanceled bool       `json:"subscription_canceled"`  // true = tidak diperpanjang otomatis
+	IsBanned             bool       `json:"is_banned"`
+	BanReason            string     `json:"ban_reason,omitempty"`
+	SuspendedUntil       *time.Time `json:"suspended_until,omitempty"` // diblokir sementara sampai waktu ini
+	Balances             map[string]int `json:"balances,omitempty"` // mata uang wallet.json selain kolom bawaan
+	NotifyFreeCredits    bool           `json:"notify_free_credits"` // kirim pesan saat free credit diisi ulang
+	SpendLimits          SpendLimits    `json:"spend_limits"`        // batas belanja & notifikasi saldo rendah
+	HumanVerified        bool           `json:"human_verified"`      // lulus tantangan anti-bot (atau tidak perlu)
+	DeletedAt            *time.Time     `json:"deleted_at,omitempty"` // diisi /deleteme, baris tidak bisa ditulis lagi
+	CreatedAt            *time.Time     `json:"created_at,omitempty"`
 }
 
 type Group struct {
@@ -79,10 +92,25 @@ func (c *Client) CreateUser(user *User) (*User, error) {
 	return &results[0], nil
 }
 
+// UpdateUser hanya menyimpan pengaturan user. Kolom lain punya fungsinya sendiri agar salinan user
+// yang sudah lama dibaca tidak menimpa perubahan dari proses lain: saldo lewat AdjustBalance/TakeBalance
+// (paket wallet), langganan lewat SetSubscription, ban/suspend lewat SetBanned/SetSuspendedUntil,
+// spend_limits lewat SetSpendLimits. Kolom yang tidak ada di sini tidak akan pernah tertimpa.
+// Akun yang sudah dihapus (/deleteme) tidak ikut diperbarui.
 func (c *Client) UpdateUser(user *User) error {
 	var results []User
-	// PERBAIKAN: Menambahkan _ untuk menangani nilai kembalian kedua
-	_, err := c.From("users").Update(user, "", "exact").Eq("telegram_id", strconv.FormatInt(user.TelegramID, 10)).ExecuteTo(&results)
+	update := map[string]interface{}{
+		"username":            user.Username,
+		"language_code":       user.LanguageCode,
+		"aspect_ratio":        user.AspectRatio,
+		"num_outputs":         user.NumOutputs,
+		"custom_settings":     user.CustomSettings,
+		"notify_free_credits": user.NotifyFreeCredits,
+	}
+	_, err := c.From("users").Update(update, "", "exact").
+		Eq("telegram_id", strconv.FormatInt(user.TelegramID, 10)).
+		Is("deleted_at", "null").
+		ExecuteTo(&results)
 	if err != nil {
 		log.Printf("ERROR: Failed to update user %d: %v", user.TelegramID, err)
 	}
@@ -95,9 +123,10 @@ type Statistics struct {
 	PremiumUsers   int `json:"premium_users"`
 }
 
+// GetAllUsers mengambil penerima broadcast: semua user kecuali yang sudah menghapus akunnya.
 func (c *Client) GetAllUsers() ([]User, error) {
 	var results []User
-	_, err := c.From("users").Select("*", "exact", false).ExecuteTo(&results)
+	_, err := c.From("users").Select("*", "exact", false).Is("deleted_at", "null").ExecuteTo(&results)
 	if err != nil {
 		log.Printf("ERROR: Failed to get all users: %v", err)
 		return nil, err
@@ -105,12 +134,13 @@ func (c *Client) GetAllUsers() ([]User, error) {
 	return results, nil
 }
 
+// GetStatistics menghitung user untuk /stats dan dashboard. Akun yang sudah dihapus tidak dihitung.
 func (c *Client) GetStatistics() (*Statistics, error) {
     var stats Statistics
     
     // Total Users
     var totalUsers []map[string]interface{}
-    _, err := c.From("users").Select("count", "exact", true).ExecuteTo(&totalUsers)
+    _, err := c.From("users").Select("count", "exact", true).Is("deleted_at", "null").ExecuteTo(&totalUsers)
     if err != nil || len(totalUsers) == 0 {
         return nil, err
     }
@@ -119,7 +149,7 @@ func (c *Client) GetStatistics() (*Statistics, error) {
     // New Users Today (UTC)
     var newUsersToday []map[string]interface{}
     today := time.Now().UTC().Format("2006-01-02")
-    _, err = c.From("users").Select("count", "exact", true).Gte("created_at", today).ExecuteTo(&newUsersToday)
+    _, err = c.From("users").Select("count", "exact", true).Gte("created_at", today).Is("deleted_at", "null").ExecuteTo(&newUsersToday)
     if err != nil || len(newUsersToday) == 0 {
         return nil, err
     }
@@ -127,7 +157,7 @@ func (c *Client) GetStatistics() (*Statistics, error) {
 
     // Premium Users
     var premiumUsers []map[string]interface{}
-    _, err = c.From("users").Select("count", "exact", true).Eq("is_premium", "true").ExecuteTo(&premiumUsers)
+    _, err = c.From("users").Select("count", "exact", true).Eq("is_premium", "true").Is("deleted_at", "null").ExecuteTo(&premiumUsers)
     if err != nil || len(premiumUsers) == 0 {
         return nil, err
     }
@@ -0,0 +1,293 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"telegram-ai-bot/internal/config"
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Kode klaim menghubungkan pembelian di Buy Me a Coffee dengan akun Telegram.
+// Format: TG-<telegram id base36>-<6 hex checksum HMAC>, user menuliskannya di kolom pesan saat checkout.
+var claimCodePattern = regexp.MustCompile(`(?i)\bTG-([0-9A-Z]+)-([0-9A-F]{6})\b`)
+
+// Event BMAC yang kita proses (pembelian produk di shop / "extras").
+const bmacEventExtrasPurchase = "extras_purchase.created"
+
+type bmacEvent struct {
+	Type     string                 `json:"type"`
+	LiveMode bool                   `json:"live_mode"`
+	EventID  interface{}            `json:"event_id"`
+	Data     map[string]interface{} `json:"data"`
+}
+
+// BMACEnabled bernilai true jika webhook BMAC dikonfigurasi (secret tersedia).
+func (ph *PaymentHandler) BMACEnabled() bool {
+	return ph.BMACSecret != ""
+}
+
+// ClaimCode mengembalikan kode klaim BMAC untuk user.
+func (ph *PaymentHandler) ClaimCode(telegramID int64) string {
+	id := strings.ToUpper(strconv.FormatInt(telegramID, 36))
+	return fmt.Sprintf("TG-%s-%s", id, ph.claimChecksum(id))
+}
+
+func (ph *PaymentHandler) claimChecksum(id string) string {
+	mac := hmac.New(sha256.New, []byte(ph.BMACSecret))
+	mac.Write([]byte("bmac-claim:" + id))
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil))[:6])
+}
+
+// parseClaimCode mencari kode klaim yang valid di dalam teks bebas.
+func (ph *PaymentHandler) parseClaimCode(text string) (int64, bool) {
+	for _, match := range claimCodePattern.FindAllStringSubmatch(text, -1) {
+		id := strings.ToUpper(match[1])
+		if !hmac.Equal([]byte(ph.claimChecksum(id)), []byte(strings.ToUpper(match[2]))) {
+			continue
+		}
+		telegramID, err := strconv.ParseInt(id, 36, 64)
+		if err == nil && telegramID > 0 {
+			return telegramID, true
+		}
+	}
+	return 0, false
+}
+
+func (ph *PaymentHandler) verifyBMACSignature(body []byte, signature string) bool {
+	expected, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(ph.BMACSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// BMACWebhookHandler menerima webhook Buy Me a Coffee (header x-signature-sha256 = HMAC-SHA256 body).
+// Selalu membalas 200 untuk event yang valid agar BMAC tidak mengirim ulang event yang sudah ditangani.
+func (ph *PaymentHandler) BMACWebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !ph.verifyBMACSignature(body, r.Header.Get("X-Signature-Sha256")) {
+			log.Printf("WARN: Rejected BMAC webhook with invalid signature from %s", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var event bmacEvent
+		if err := json.Unmarshal(body, &event); err != nil || event.Data == nil {
+			log.Printf("WARN: Could not parse BMAC webhook body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		ph.handleBMACEvent(&event)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (ph *PaymentHandler) handleBMACEvent(event *bmacEvent) {
+	if event.Type != bmacEventExtrasPurchase {
+		log.Printf("INFO: Ignoring BMAC event of type '%s'", event.Type)
+		return
+	}
+	if !event.LiveMode {
+		log.Printf("INFO: Ignoring BMAC test event %v", event.EventID)
+		return
+	}
+
+	purchaseID := stringValue(event.Data["id"])
+	if purchaseID == "" {
+		purchaseID = stringValue(event.EventID)
+	}
+	if purchaseID == "" {
+		log.Println("WARN: BMAC purchase without an id, ignoring")
+		return
+	}
+
+	credits, products, unknown := ph.matchBMACPackages(event.Data)
+	telegramID, claimed := ph.parseClaimCode(strings.Join(collectStrings(event.Data), "\n"))
+	if credits == 0 || !claimed {
+		log.Printf("WARN: BMAC purchase %s could not be matched (credits=%d, claimed=%v)", purchaseID, credits, claimed)
+		ph.notifyAdminsBMACUnmatched(purchaseID, event.Data, products, credits, claimed)
+		return
+	}
+	// Sebagian item tidak dikenal: seluruh pembelian diserahkan ke admin agar bagian yang tidak
+	// dikenal tidak hilang begitu saja
+	if unknown > 0 {
+		log.Printf("WARN: BMAC purchase %s has %d unknown item(s) (%s), left for manual review", purchaseID, unknown, strings.Join(products, ", "))
+		ph.notifyAdminsBMACUnmatched(purchaseID, event.Data, products, credits, claimed)
+		ph.Bot.Send(tgbotapi.NewMessage(telegramID, ph.Localizer.Get(ph.getUserLang(telegramID), "bmac_error_admin")))
+		return
+	}
+
+	amount, _ := strconv.ParseFloat(stringValue(event.Data["total_amount_charged"]), 64)
+	if amount == 0 {
+		amount, _ = strconv.ParseFloat(stringValue(event.Data["amount"]), 64)
+	}
+	payment := &database.Payment{
+		ChargeID:    "bmac:" + purchaseID,
+		TelegramID:  telegramID,
+		Payload:     "bmac:" + strings.Join(products, ","),
+		ProductType: database.ProductTypeCredits,
+		Currency:    strings.ToUpper(stringValue(event.Data["currency"])),
+		Amount:      int(math.Round(amount * 100)), // dalam sen
+		Credits:     credits,
+		Status:      database.PaymentStatusPending,
+	}
+
+	inserted, err := ph.DB.InsertPayment(payment)
+	if err != nil {
+		ph.notifyAdminsBMACUnmatched(purchaseID, event.Data, products, credits, claimed)
+		return
+	}
+	if !inserted {
+		log.Printf("INFO: Ignoring duplicate BMAC purchase %s", purchaseID)
+		return
+	}
+
+	user, err := ph.DB.GetUserByTelegramID(telegramID)
+	if err == nil && user != nil {
+		user.PaidCredits, err = ph.DB.AdjustBalance(telegramID, wallet.PaidCredits, credits)
+	}
+	if err != nil || user == nil {
+		log.Printf("ERROR: BMAC purchase %s for user %d could not be credited", purchaseID, telegramID)
+		ph.DB.SetPaymentStatus(payment.ChargeID, database.PaymentStatusFailed)
+		ph.Bot.Send(tgbotapi.NewMessage(telegramID, ph.Localizer.Get(ph.getUserLang(telegramID), "bmac_error_admin")))
+		ph.notifyAdminsBMACUnmatched(purchaseID, event.Data, products, credits, claimed)
+		return
+	}
+	ph.DB.SetPaymentStatus(payment.ChargeID, database.PaymentStatusCompleted)
+	ph.DB.Record(telegramID, database.TransactionPurchase, wallet.PaidCredits, credits, "Buy Me a Coffee")
+	log.Printf("INFO: BMAC purchase %s credited %d credits to user %d", purchaseID, credits, telegramID)
+
+	args := map[string]string{
+		"credits": strconv.Itoa(credits),
+		"balance": strconv.Itoa(user.PaidCredits + user.FreeCredits),
+	}
+	msg := tgbotapi.NewMessage(telegramID, ph.Localizer.Getf(user.LanguageCode, "bmac_success_notification", args))
+	msg.ParseMode = "HTML"
+	ph.Bot.Send(msg)
+}
+
+// matchBMACPackages mencocokkan item yang dibeli dengan bmac_packages.json,
+// lewat ID produk di product_url (.../e/<id>) atau nama produk. unknown adalah jumlah item
+// yang tidak cocok dengan paket mana pun (ditulis "?judul" di products).
+func (ph *PaymentHandler) matchBMACPackages(data map[string]interface{}) (credits int, products []string, unknown int) {
+	items, _ := data["extras"].([]interface{})
+	if extra, ok := data["extra"].(map[string]interface{}); ok {
+		items = append(items, extra)
+	}
+
+	for _, raw := range items {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pkg := ph.findBMACPackage(item)
+		if pkg == nil {
+			products = append(products, "?"+stringValue(item["title"]))
+			unknown++
+			continue
+		}
+		quantity, _ := strconv.Atoi(stringValue(item["quantity"]))
+		if quantity <= 0 {
+			quantity = 1
+		}
+		credits += pkg.CreditsAmount * quantity
+		products = append(products, pkg.ProductName)
+	}
+	return credits, products, unknown
+}
+
+func (ph *PaymentHandler) findBMACPackage(item map[string]interface{}) *config.BMACCreditPackage {
+	ids := []string{stringValue(item["id"]), stringValue(item["extra_id"]), stringValue(item["reward_id"])}
+	title := strings.TrimSpace(stringValue(item["title"]))
+	for i := range ph.BMACPackages {
+		pkg := &ph.BMACPackages[i]
+		for _, id := range ids {
+			if id != "" && strings.HasSuffix(strings.TrimRight(pkg.ProductURL, "/"), "/e/"+id) {
+				return pkg
+			}
+		}
+		if title != "" && strings.EqualFold(pkg.ProductName, title) {
+			return pkg
+		}
+	}
+	return nil
+}
+
+func (ph *PaymentHandler) notifyAdminsBMACUnmatched(purchaseID string, data map[string]interface{}, products []string, credits int, claimed bool) {
+	lang := "en"
+	args := map[string]string{
+		"purchase_id": html.EscapeString(purchaseID),
+		"supporter":   html.EscapeString(stringValue(data["supporter_name"]) + " " + stringValue(data["supporter_email"])),
+		"products":    html.EscapeString(strings.Join(products, ", ")),
+		"credits":     strconv.Itoa(credits),
+		"claimed":     strconv.FormatBool(claimed),
+	}
+	text := ph.Localizer.Getf(lang, "bmac_admin_unmatched", args)
+	for _, adminID := range ph.AdminIDs {
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ParseMode = "HTML"
+		ph.Bot.Send(msg)
+	}
+}
+
+// collectStrings mengumpulkan semua nilai string (rekursif) dari payload, tempat kode klaim
+// bisa muncul: catatan pembeli, jawaban pertanyaan checkout, dsb.
+func collectStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case map[string]interface{}:
+		var out []string
+		for _, item := range v {
+			out = append(out, collectStrings(item)...)
+		}
+		return out
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			out = append(out, collectStrings(item)...)
+		}
+		return out
+	}
+	return nil
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"telegram-ai-bot/internal/config"
 	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/referral"
+	"telegram-ai-bot/internal/wallet"
 	"telegram-ai-bot/internal/localization"
 	
 
@@ -30,10 +32,18 @@ type PaymentHandler struct {
 	BMACPackages []config.BMACCreditPackage
 	Token      string
 	ManualInfo string
+	Subscription SubscriptionPlan
+	RefundMaxSpentPercent int // batas kredit terpakai (%) yang masih boleh di-refund tanpa force
+	ManualPackages []ManualPackage
+	AdminIDs       []int64 // penerima bukti transfer manual
+	BMACSecret     string  // secret webhook Buy Me a Coffee, juga dipakai untuk checksum kode klaim
+	Referrals      *referral.Program // komisi referrer dari pembelian Stars
 }
 
-func NewPaymentHandler(bot *tgbotapi.BotAPI, db *database.Client, loc *localization.Localizer, token, manualInfo, packagesFile,  bmacPackagesFile string) *PaymentHandler {
+func NewPaymentHandler(bot *tgbotapi.BotAPI, db *database.Client, loc *localization.Localizer, token, manualInfo, packagesFile,  bmacPackagesFile, subscriptionFile, manualPackagesFile string, refundMaxSpentPercent int, adminIDs []int64, bmacSecret string, referrals *referral.Program) *PaymentHandler {
 	packages := loadPackages(packagesFile)
+	subscription := loadSubscriptionPlan(subscriptionFile)
+	manualPackages := loadManualPackages(manualPackagesFile)
 	bmacPackages := config.LoadBMACPackages(bmacPackagesFile) 
 	return &PaymentHandler{
 		Bot:        bot,
@@ -43,6 +53,12 @@ func NewPaymentHandler(bot *tgbotapi.BotAPI, db *database.Client, loc *localizat
 		BMACPackages: bmacPackages,
 		Token:      token,
 		ManualInfo: manualInfo,
+		Subscription: subscription,
+		RefundMaxSpentPercent: refundMaxSpentPercent,
+		ManualPackages: manualPackages,
+		AdminIDs:       adminIDs,
+		BMACSecret:     bmacSecret,
+		Referrals:      referrals,
 	}
 }
 
@@ -153,7 +169,7 @@ func (ph *PaymentHandler) ShowStarsPackages(chatID int64, messageID int) {
 
 func (ph *PaymentHandler) ShowBMACPackages(chatID int64, messageID int) {
 	lang := ph.getUserLang(chatID)
-	text := ph.Localizer.Get(lang, "topup_bmac_select_package") + "\n\n" + ph.Localizer.Get(lang, "topup_bmac_instructions")
+	text := ph.Localizer.Get(lang, "topup_bmac_select_package") + "\n\n" + ph.Localizer.Getf(lang, "topup_bmac_claim_instructions", map[string]string{"code": ph.ClaimCode(chatID)})
 	var rows [][]tgbotapi.InlineKeyboardButton
 	for _, pkg := range ph.BMACPackages {
 		buttonText := fmt.Sprintf("%s (%d Credits)", pkg.ProductName, pkg.CreditsAmount)
@@ -180,61 +196,111 @@ func (ph *PaymentHandler) getUserLang(userID int64) string {
     return "en"
 }
 
-func (ph *PaymentHandler) ShowManualPaymentInfo(chatID int64, messageID int) {
-	lang := ph.getUserLang(chatID)
-	text := ph.ManualInfo
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "back_button"), "topup_back_to_manual"),
-		),
-	)
-
-	msg := tgbotapi.NewEditMessageText(chatID, messageID, text)
-	msg.ReplyMarkup = &keyboard
-	msg.ParseMode = "html"
-	ph.Bot.Send(msg)
-}
-
 func (ph *PaymentHandler) HandlePreCheckoutQuery(query *tgbotapi.PreCheckoutQuery) {
-	ok := true
+	errorKey := ph.validatePreCheckout(query)
 	preCheckoutConfig := tgbotapi.PreCheckoutConfig{
 		PreCheckoutQueryID: query.ID,
-		OK:                 ok,
+		OK:                 errorKey == "",
+	}
+	if errorKey != "" {
+		log.Printf("WARN: Rejected pre-checkout from user %d for payload '%s' (%d %s): %s", query.From.ID, query.InvoicePayload, query.TotalAmount, query.Currency, errorKey)
+		preCheckoutConfig.ErrorMessage = ph.Localizer.Get(ph.getUserLang(query.From.ID), errorKey)
 	}
 	ph.Bot.Request(preCheckoutConfig)
 }
 
+// HandleSuccessfulPayment mencatat pembayaran ke tabel payments lebih dulu (charge ID sebagai
+// primary key), baru kemudian memberikan kredit/premium. Update duplikat dari Telegram
+// gagal di-insert dan diabaikan, sehingga kredit hanya diberikan sekali.
 func (ph *PaymentHandler) HandleSuccessfulPayment(message *tgbotapi.Message) {
 	paymentInfo := message.SuccessfulPayment
 	userID := message.From.ID
-	lang := "en"
+	lang := ph.getUserLang(userID)
+
+	payment := &database.Payment{
+		ChargeID:         paymentInfo.TelegramPaymentChargeID,
+		ProviderChargeID: paymentInfo.ProviderPaymentChargeID,
+		TelegramID:       userID,
+		Payload:          paymentInfo.InvoicePayload,
+		ProductType:      database.ProductTypeCredits,
+		Currency:         paymentInfo.Currency,
+		Amount:           paymentInfo.TotalAmount,
+		Status:           database.PaymentStatusPending,
+	}
 
-	var creditsToAdd int
-	for _, pkg := range ph.Packages {
-		if pkg.ID == paymentInfo.InvoicePayload {
-			creditsToAdd = pkg.CreditsAmount
-			break
+	var creditsToAdd, bonusCredits int
+	var bonus *database.PromoRedemption
+	if isSubscriptionPayload(paymentInfo.InvoicePayload) {
+		payment.ProductType = database.ProductTypeSubscription
+	} else {
+		for _, pkg := range ph.Packages {
+			if pkg.ID == paymentInfo.InvoicePayload {
+				creditsToAdd = pkg.CreditsAmount
+				break
+			}
+		}
+		if creditsToAdd == 0 {
+			log.Printf("ERROR: Successful payment for unknown payload '%s' from user %d (charge %s)", paymentInfo.InvoicePayload, userID, payment.ChargeID)
+		}
+		if creditsToAdd > 0 && paymentInfo.Currency == "XTR" {
+			// Bonus promo ikut dicatat di payment.Credits agar ikut ditarik saat refund
+			bonus, bonusCredits = ph.pendingPromoBonus(userID, creditsToAdd)
+			creditsToAdd += bonusCredits
+		}
+		payment.Credits = creditsToAdd
+	}
+
+	inserted, err := ph.DB.InsertPayment(payment)
+	if err != nil {
+		log.Printf("ERROR: Payment %s from user %d could not be recorded, nothing was credited", payment.ChargeID, userID)
+		if !ph.autoRefund(payment, "payment_not_recorded", false) {
+			ph.Bot.Send(tgbotapi.NewMessage(userID, ph.Localizer.Get(lang, "topup_error_admin")))
 		}
+		return
+	}
+	if !inserted {
+		log.Printf("INFO: Ignoring duplicate successful_payment %s from user %d", payment.ChargeID, userID)
+		return
+	}
+
+	var delivered bool
+	if payment.ProductType == database.ProductTypeSubscription {
+		delivered = ph.handleSubscriptionPayment(message)
+	} else if creditsToAdd > 0 {
+		delivered = ph.deliverCredits(userID, creditsToAdd)
 	}
 
-	if creditsToAdd == 0 {
-		log.Printf("ERROR: Successful payment for unknown payload '%s' from user %d", paymentInfo.InvoicePayload, userID)
+	if delivered {
+		ph.DB.SetPaymentStatus(payment.ChargeID, database.PaymentStatusCompleted)
+		if bonus != nil {
+			ph.consumePromoBonus(bonus, payment.ChargeID, bonusCredits)
+		}
+		if paymentInfo.Currency == "XTR" && creditsToAdd > 0 {
+			// Komisi dihitung dari kredit paket saja, tanpa bonus promo
+			ph.Referrals.OnPurchase(userID, creditsToAdd-bonusCredits, payment.ChargeID)
+		}
 		return
 	}
+	ph.DB.SetPaymentStatus(payment.ChargeID, database.PaymentStatusFailed)
+	if !ph.autoRefund(payment, "delivery_failed", true) {
+		ph.Bot.Send(tgbotapi.NewMessage(userID, ph.Localizer.Get(lang, "topup_error_admin")))
+	}
+}
 
+func (ph *PaymentHandler) deliverCredits(userID int64, creditsToAdd int) bool {
 	user, err := ph.DB.GetUserByTelegramID(userID)
 	if err != nil || user == nil {
 		log.Printf("ERROR: User %d paid successfully but could not be found in DB", userID)
-		return
+		return false
 	}
+	lang := user.LanguageCode
 
-	user.PaidCredits += creditsToAdd
-	err = ph.DB.UpdateUser(user)
+	user.PaidCredits, err = ph.DB.AdjustBalance(userID, wallet.PaidCredits, creditsToAdd)
 	if err != nil {
 		log.Printf("ERROR: Failed to add %d credits to user %d after successful payment", creditsToAdd, userID)
-		ph.Bot.Send(tgbotapi.NewMessage(userID, ph.Localizer.Get(lang, "topup_error_admin")))
-		return
+		return false
 	}
+	ph.DB.Record(userID, database.TransactionPurchase, wallet.PaidCredits, creditsToAdd, "Telegram Stars")
 
 	log.Printf("INFO: User %d successfully purchased %d credits.", userID, creditsToAdd)
 	args := map[string]string{
@@ -246,15 +312,20 @@ func (ph *PaymentHandler) HandleSuccessfulPayment(message *tgbotapi.Message) {
 	msg := tgbotapi.NewMessage(userID, successText)
 	msg.ParseMode = "Markdown" 
 	ph.Bot.Send(msg)
+	return true
 }
 
 func (ph *PaymentHandler) ShowManualPaymentOptions(chatID int64, messageID int) {
 	lang := ph.getUserLang(chatID)
 	text := ph.Localizer.Get(lang, "topup_manual_select_method")
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		///tgbotapi.NewInlineKeyboardRow(
-			///tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "button_bmac"), "topup_bmac"),
-		///),
+	var rows [][]tgbotapi.InlineKeyboardButton
+	// BMAC hanya ditampilkan jika webhook aktif, karena kredit ditambahkan otomatis lewat webhook
+	if ph.BMACEnabled() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "button_bmac"), "topup_bmac"),
+		))
+	}
+	rows = append(rows,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "button_manual_transfer"), "topup_transfer_bank"),
 		),
@@ -262,6 +333,7 @@ func (ph *PaymentHandler) ShowManualPaymentOptions(chatID int64, messageID int)
 			tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "back_button"), "topup_back_to_main"),
 		),
 	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 
 	msg := tgbotapi.NewEditMessageText(chatID, messageID, text)
 	msg.ReplyMarkup = &keyboard
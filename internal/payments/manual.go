@@ -0,0 +1,269 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ManualPackage adalah paket kredit yang dibayar lewat transfer bank lalu dicek admin.
+type ManualPackage struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Price         int    `json:"price"`
+	Currency      string `json:"currency"`
+	PriceLabel    string `json:"price_label"`
+	CreditsAmount int    `json:"credits_amount"`
+}
+
+func loadManualPackages(file string) []ManualPackage {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read manual packages file %s: %v", file, err)
+	}
+	var packages []ManualPackage
+	if err := json.Unmarshal(data, &packages); err != nil {
+		log.Fatalf("FATAL: Could not parse manual packages file %s: %v", file, err)
+	}
+	log.Printf("INFO: Loaded %d manual payment packages", len(packages))
+	return packages
+}
+
+func (ph *PaymentHandler) findManualPackage(packageID string) *ManualPackage {
+	for i := range ph.ManualPackages {
+		if ph.ManualPackages[i].ID == packageID {
+			return &ph.ManualPackages[i]
+		}
+	}
+	return nil
+}
+
+// ShowManualPaymentInfo menampilkan info rekening (MANUAL_PAYMENT_INFO) beserta pilihan paket.
+func (ph *PaymentHandler) ShowManualPaymentInfo(chatID int64, messageID int) {
+	lang := ph.getUserLang(chatID)
+	text := ph.ManualInfo + "\n\n" + ph.Localizer.Get(lang, "manual_select_package")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, pkg := range ph.ManualPackages {
+		buttonText := fmt.Sprintf("%s — %s (%d Credits)", pkg.Title, pkg.PriceLabel, pkg.CreditsAmount)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonText, "manual_pkg:"+pkg.ID),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "back_button"), "topup_back_to_manual"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	msg.ReplyMarkup = &keyboard
+	msg.ParseMode = "html"
+	ph.Bot.Send(msg)
+}
+
+// PromptManualProof meminta user mengirim bukti transfer untuk paket yang dipilih.
+// Mengembalikan false jika paket tidak dikenal atau user masih punya permintaan yang menunggu review.
+func (ph *PaymentHandler) PromptManualProof(chatID int64, userID int64, messageID int, packageID string) bool {
+	lang := ph.getUserLang(userID)
+	pkg := ph.findManualPackage(packageID)
+	if pkg == nil {
+		log.Printf("WARN: Invalid manual package ID selected: %s", packageID)
+		return false
+	}
+
+	pending, err := ph.DB.GetPendingManualPayment(userID)
+	if err != nil {
+		ph.Bot.Send(tgbotapi.NewMessage(chatID, ph.Localizer.Get(lang, "manual_error")))
+		return false
+	}
+	if pending != nil {
+		ph.Bot.Send(tgbotapi.NewMessage(chatID, ph.Localizer.Getf(lang, "manual_already_pending", map[string]string{"id": strconv.FormatInt(pending.ID, 10)})))
+		return false
+	}
+
+	args := map[string]string{
+		"package": html.EscapeString(pkg.Title),
+		"price":   html.EscapeString(pkg.PriceLabel),
+		"credits": strconv.Itoa(pkg.CreditsAmount),
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "back_button"), "topup_transfer_bank"),
+		),
+	)
+	msg := tgbotapi.NewEditMessageText(chatID, messageID, ph.Localizer.Getf(lang, "manual_send_proof", args))
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = &keyboard
+	ph.Bot.Send(msg)
+	return true
+}
+
+// SubmitManualProof menyimpan bukti transfer sebagai permintaan baru lalu meneruskannya ke admin.
+// Mengembalikan false jika pesan bukan gambar (user diminta mengirim ulang).
+func (ph *PaymentHandler) SubmitManualProof(message *tgbotapi.Message, packageID string) bool {
+	userID := message.From.ID
+	lang := ph.getUserLang(userID)
+
+	var proofFileID string
+	isDocument := false
+	if len(message.Photo) > 0 {
+		proofFileID = message.Photo[len(message.Photo)-1].FileID
+	} else if message.Document != nil && strings.HasPrefix(message.Document.MimeType, "image/") {
+		proofFileID = message.Document.FileID
+		isDocument = true
+	}
+	if proofFileID == "" {
+		ph.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, ph.Localizer.Get(lang, "manual_proof_invalid")))
+		return false
+	}
+
+	pkg := ph.findManualPackage(packageID)
+	if pkg == nil {
+		ph.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, ph.Localizer.Get(lang, "manual_error")))
+		return true
+	}
+
+	request, err := ph.DB.CreateManualPayment(&database.ManualPayment{
+		TelegramID:  userID,
+		PackageID:   pkg.ID,
+		Credits:     pkg.CreditsAmount,
+		Price:       pkg.Price,
+		Currency:    pkg.Currency,
+		ProofFileID: proofFileID,
+		Status:      database.ManualPaymentPending,
+	})
+	if err != nil {
+		ph.Bot.Send(tgbotapi.NewMessage(message.Chat.ID, ph.Localizer.Get(lang, "manual_error")))
+		return true
+	}
+	log.Printf("INFO: Manual payment #%d submitted by user %d for package %s", request.ID, userID, pkg.ID)
+
+	ph.notifyAdminsManualPayment(request, pkg, message.From, proofFileID, isDocument)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, ph.Localizer.Getf(lang, "manual_submitted", map[string]string{"id": strconv.FormatInt(request.ID, 10)}))
+	msg.ParseMode = "HTML"
+	ph.Bot.Send(msg)
+	return true
+}
+
+func (ph *PaymentHandler) notifyAdminsManualPayment(request *database.ManualPayment, pkg *ManualPackage, from *tgbotapi.User, proofFileID string, isDocument bool) {
+	lang := "en"
+	username := "-"
+	if from.UserName != "" {
+		username = "@" + from.UserName
+	}
+	args := map[string]string{
+		"id":       strconv.FormatInt(request.ID, 10),
+		"user_id":  strconv.FormatInt(from.ID, 10),
+		"name":     html.EscapeString(strings.TrimSpace(from.FirstName + " " + from.LastName)),
+		"username": html.EscapeString(username),
+		"package":  html.EscapeString(pkg.Title),
+		"price":    html.EscapeString(pkg.PriceLabel),
+		"credits":  strconv.Itoa(pkg.CreditsAmount),
+	}
+	caption := ph.Localizer.Getf(lang, "manual_admin_request", args)
+	idStr := strconv.FormatInt(request.ID, 10)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "manual_button_approve"), "manual_approve:"+idStr),
+			tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "manual_button_reject"), "manual_reject:"+idStr),
+		),
+	)
+
+	if len(ph.AdminIDs) == 0 {
+		log.Printf("WARN: Manual payment #%d submitted but no admins are configured", request.ID)
+	}
+	for _, adminID := range ph.AdminIDs {
+		var err error
+		if isDocument {
+			doc := tgbotapi.NewDocument(adminID, tgbotapi.FileID(proofFileID))
+			doc.Caption = caption
+			doc.ParseMode = "HTML"
+			doc.ReplyMarkup = keyboard
+			_, err = ph.Bot.Send(doc)
+		} else {
+			photo := tgbotapi.NewPhoto(adminID, tgbotapi.FileID(proofFileID))
+			photo.Caption = caption
+			photo.ParseMode = "HTML"
+			photo.ReplyMarkup = keyboard
+			_, err = ph.Bot.Send(photo)
+		}
+		if err != nil {
+			log.Printf("ERROR: Failed to forward manual payment #%d to admin %d: %v", request.ID, adminID, err)
+		}
+	}
+}
+
+// ReviewManualPayment dipanggil saat admin menekan Approve/Reject. Perubahan status bersyarat
+// (hanya dari pending) memastikan kredit diberikan tepat sekali walau beberapa admin menekan tombol.
+func (ph *PaymentHandler) ReviewManualPayment(callback *tgbotapi.CallbackQuery, requestID int64, approve bool) {
+	adminID := callback.From.ID
+	lang := "en"
+
+	status := database.ManualPaymentRejected
+	if approve {
+		status = database.ManualPaymentApproved
+	}
+
+	request, err := ph.DB.ReviewManualPayment(requestID, database.ManualPaymentPending, status, adminID)
+	if err != nil {
+		ph.Bot.Send(tgbotapi.NewMessage(callback.Message.Chat.ID, ph.Localizer.Get(lang, "manual_error")))
+		return
+	}
+	if request == nil {
+		ph.Bot.Send(tgbotapi.NewMessage(callback.Message.Chat.ID, ph.Localizer.Getf(lang, "manual_admin_already_reviewed", map[string]string{"id": strconv.FormatInt(requestID, 10)})))
+		return
+	}
+
+	user, err := ph.DB.GetUserByTelegramID(request.TelegramID)
+	if err != nil || user == nil {
+		log.Printf("ERROR: User %d of manual payment #%d not found", request.TelegramID, requestID)
+		ph.DB.ReviewManualPayment(requestID, status, database.ManualPaymentPending, adminID)
+		ph.Bot.Send(tgbotapi.NewMessage(callback.Message.Chat.ID, ph.Localizer.Get(lang, "manual_error")))
+		return
+	}
+
+	if approve {
+		if _, err := ph.DB.AdjustBalance(user.TelegramID, wallet.PaidCredits, request.Credits); err != nil {
+			// Kembalikan ke pending agar admin bisa mencoba lagi
+			ph.DB.ReviewManualPayment(requestID, status, database.ManualPaymentPending, adminID)
+			ph.Bot.Send(tgbotapi.NewMessage(callback.Message.Chat.ID, ph.Localizer.Get(lang, "manual_error")))
+			return
+		}
+		ph.DB.Record(user.TelegramID, database.TransactionPurchase, wallet.PaidCredits, request.Credits, fmt.Sprintf("Manual transfer #%d", requestID))
+	}
+	log.Printf("INFO: Admin %d %s manual payment #%d of user %d (%d credits)", adminID, status, requestID, request.TelegramID, request.Credits)
+	auditAction := database.AuditManualReject
+	if approve {
+		auditAction = database.AuditManualApprove
+	}
+	ph.DB.RecordAudit(adminID, auditAction, request.TelegramID, map[string]interface{}{"request_id": requestID, "credits": request.Credits})
+
+	// Update caption pesan admin agar terlihat sudah diproses
+	reviewer := callback.From.UserName
+	if reviewer == "" {
+		reviewer = strconv.FormatInt(adminID, 10)
+	}
+	statusLine := ph.Localizer.Getf(lang, "manual_admin_"+status, map[string]string{"admin": reviewer})
+	edit := tgbotapi.NewEditMessageCaption(callback.Message.Chat.ID, callback.Message.MessageID, callback.Message.Caption+"\n\n"+statusLine)
+	ph.Bot.Send(edit)
+
+	userLang := user.LanguageCode
+	args := map[string]string{
+		"id":      strconv.FormatInt(requestID, 10),
+		"credits": strconv.Itoa(request.Credits),
+		"balance": strconv.Itoa(user.PaidCredits + user.FreeCredits),
+	}
+	msg := tgbotapi.NewMessage(user.TelegramID, ph.Localizer.Getf(userLang, "manual_user_"+status, args))
+	msg.ParseMode = "HTML"
+	ph.Bot.Send(msg)
+}
@@ -0,0 +1,190 @@
+package payments
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// PromoError menjelaskan kenapa promo code ditolak. Key adalah key di file locales.
+type PromoError struct {
+	Key  string
+	Args map[string]string
+}
+
+func (e *PromoError) Error() string {
+	return fmt.Sprintf("promo rejected (%s): %v", e.Key, e.Args)
+}
+
+// Batas bonus persen agar salah ketik admin tidak memberi kredit berlipat-lipat.
+const maxPromoBonusPercent = 200
+
+// NormalizePromoCode menyeragamkan kode (tidak case-sensitive).
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidatePromoCode memeriksa format kode dan hadiah sebelum dibuat admin.
+func ValidatePromoCode(promo *database.PromoCode) error {
+	if len(promo.Code) < 3 || len(promo.Code) > 32 || strings.IndexFunc(promo.Code, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+	}) >= 0 {
+		return &PromoError{Key: "promo_admin_invalid_code"}
+	}
+	switch promo.RewardType {
+	case database.PromoRewardCredits, database.PromoRewardDiamonds:
+		if promo.RewardAmount <= 0 {
+			return &PromoError{Key: "promo_admin_invalid_amount"}
+		}
+	case database.PromoRewardBonus:
+		if promo.RewardAmount <= 0 || promo.RewardAmount > maxPromoBonusPercent {
+			return &PromoError{Key: "promo_admin_invalid_amount"}
+		}
+	default:
+		return &PromoError{Key: "promo_admin_invalid_type"}
+	}
+	if promo.MaxUses < 0 || promo.PerUserLimit < 0 {
+		return &PromoError{Key: "promo_admin_invalid_limit"}
+	}
+	return nil
+}
+
+// RedeemPromo memakai promo code untuk user. Kredit/diamond langsung ditambahkan,
+// sedangkan bonus disimpan sampai pembelian Stars berikutnya.
+func (ph *PaymentHandler) RedeemPromo(userID int64, rawCode string) (*database.PromoRedemption, error) {
+	code := NormalizePromoCode(rawCode)
+	args := map[string]string{"code": code}
+
+	promo, err := ph.DB.GetPromoCode(code)
+	if err != nil {
+		return nil, &PromoError{Key: "promo_error_generic"}
+	}
+	if promo == nil || !promo.Active {
+		return nil, &PromoError{Key: "promo_error_invalid", Args: args}
+	}
+	if promo.ExpiresAt != nil && time.Now().After(*promo.ExpiresAt) {
+		return nil, &PromoError{Key: "promo_error_expired", Args: args}
+	}
+
+	user, err := ph.DB.GetUserByTelegramID(userID)
+	if err != nil || user == nil {
+		return nil, &PromoError{Key: "promo_error_generic"}
+	}
+
+	useNumber := 0
+	if promo.PerUserLimit > 0 {
+		used, err := ph.DB.CountUserPromoRedemptions(code, userID)
+		if err != nil {
+			return nil, &PromoError{Key: "promo_error_generic"}
+		}
+		if used >= promo.PerUserLimit {
+			return nil, &PromoError{Key: "promo_error_already_used", Args: args}
+		}
+		// Nomor pemakaian unik per user: redeem bersamaan mendapat nomor yang sama dan hanya satu yang tercatat
+		useNumber = used + 1
+	}
+	if promo.RewardType == database.PromoRewardBonus {
+		// Hanya satu bonus pembelian yang bisa menunggu dalam satu waktu
+		pending, err := ph.DB.GetPendingPromoBonus(userID)
+		if err != nil {
+			return nil, &PromoError{Key: "promo_error_generic"}
+		}
+		if pending != nil {
+			return nil, &PromoError{Key: "promo_error_bonus_pending", Args: map[string]string{"code": pending.Code}}
+		}
+	}
+
+	// Klaim satu kuota pemakaian; ulangi jika ada redeem lain yang bersamaan
+	claimed := false
+	for attempt := 0; attempt < 3 && !claimed; attempt++ {
+		if promo.MaxUses > 0 && promo.Uses >= promo.MaxUses {
+			return nil, &PromoError{Key: "promo_error_exhausted", Args: args}
+		}
+		claimed, err = ph.DB.IncrementPromoUses(code, promo.Uses)
+		if err != nil {
+			return nil, &PromoError{Key: "promo_error_generic"}
+		}
+		if !claimed {
+			promo, err = ph.DB.GetPromoCode(code)
+			if err != nil || promo == nil {
+				return nil, &PromoError{Key: "promo_error_generic"}
+			}
+		}
+	}
+	if !claimed {
+		return nil, &PromoError{Key: "promo_error_generic"}
+	}
+
+	redemption := &database.PromoRedemption{
+		Code:         code,
+		TelegramID:   userID,
+		Campaign:     promo.Campaign,
+		RewardType:   promo.RewardType,
+		RewardAmount: promo.RewardAmount,
+		UseNumber:    useNumber,
+	}
+	inserted, err := ph.DB.InsertPromoRedemption(redemption)
+	if err != nil || !inserted {
+		ph.DB.DecrementPromoUses(code, promo.Uses+1)
+		if err == nil {
+			return nil, &PromoError{Key: "promo_error_already_used", Args: args}
+		}
+		return nil, &PromoError{Key: "promo_error_generic"}
+	}
+
+	if promo.RewardType != database.PromoRewardBonus {
+		if _, err := ph.DB.AdjustBalance(userID, promoCurrency(promo.RewardType), promo.RewardAmount); err != nil {
+			log.Printf("ERROR: Promo %s redeemed by user %d was recorded but the reward (%d %s) could not be applied", code, userID, promo.RewardAmount, promo.RewardType)
+			return nil, &PromoError{Key: "promo_error_generic"}
+		}
+		ph.DB.Record(userID, database.TransactionPromo, promoCurrency(promo.RewardType), promo.RewardAmount, "Promo "+code)
+	}
+
+	log.Printf("INFO: User %d redeemed promo code %s (%d %s, campaign '%s')", userID, code, promo.RewardAmount, promo.RewardType, promo.Campaign)
+	return redemption, nil
+}
+
+// promoCurrency adalah mata uang wallet yang bertambah oleh hadiah promo.
+func promoCurrency(rewardType string) string {
+	if rewardType == database.PromoRewardDiamonds {
+		return wallet.Diamonds
+	}
+	return wallet.PaidCredits
+}
+
+// pendingPromoBonus menghitung bonus kredit dari promo yang menunggu untuk pembelian Stars.
+func (ph *PaymentHandler) pendingPromoBonus(userID int64, credits int) (*database.PromoRedemption, int) {
+	bonus, err := ph.DB.GetPendingPromoBonus(userID)
+	if err != nil || bonus == nil {
+		return nil, 0
+	}
+	extra := credits * bonus.RewardAmount / 100
+	if extra <= 0 {
+		return nil, 0
+	}
+	return bonus, extra
+}
+
+// consumePromoBonus menandai bonus sudah dipakai setelah kredit pembelian berhasil diberikan.
+func (ph *PaymentHandler) consumePromoBonus(bonus *database.PromoRedemption, chargeID string, extra int) {
+	updated, err := ph.DB.ConsumePromoBonus(bonus.ID, chargeID)
+	if err != nil || !updated {
+		log.Printf("WARN: Promo bonus %d (%s) of user %d was applied to %s but could not be marked as consumed", bonus.ID, bonus.Code, bonus.TelegramID, chargeID)
+		return
+	}
+	log.Printf("INFO: Promo bonus %s (+%d credits) applied to payment %s of user %d", bonus.Code, extra, chargeID, bonus.TelegramID)
+
+	args := map[string]string{
+		"code":    bonus.Code,
+		"percent": strconv.Itoa(bonus.RewardAmount),
+		"credits": strconv.Itoa(extra),
+	}
+	ph.Bot.Send(tgbotapi.NewMessage(bonus.TelegramID, ph.Localizer.Getf(ph.getUserLang(bonus.TelegramID), "promo_bonus_applied", args)))
+}
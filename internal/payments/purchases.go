@@ -0,0 +1,106 @@
+package payments
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Jumlah pembayaran terakhir yang ditampilkan di /purchases.
+const purchaseHistoryLimit = 20
+
+// expectedPrice mengembalikan harga (dalam Stars) untuk payload invoice yang kita terbitkan.
+func (ph *PaymentHandler) expectedPrice(payload string) (int, bool) {
+	if isSubscriptionPayload(payload) {
+		if strings.TrimPrefix(payload, subscriptionPayloadPrefix) != ph.Subscription.ID {
+			return 0, false
+		}
+		return ph.Subscription.StarsAmount, true
+	}
+	for _, pkg := range ph.Packages {
+		if pkg.ID == payload {
+			return pkg.StarsAmount, true
+		}
+	}
+	return 0, false
+}
+
+// validatePreCheckout mengembalikan key locale alasan penolakan, atau "" jika pembayaran boleh lanjut.
+func (ph *PaymentHandler) validatePreCheckout(query *tgbotapi.PreCheckoutQuery) string {
+	if query.Currency != "XTR" {
+		return "precheckout_error_unknown_product"
+	}
+	price, ok := ph.expectedPrice(query.InvoicePayload)
+	if !ok {
+		return "precheckout_error_unknown_product"
+	}
+	if query.TotalAmount != price {
+		// Invoice lama dengan harga yang sudah berubah
+		return "precheckout_error_price_changed"
+	}
+
+	user, err := ph.DB.GetUserByTelegramID(query.From.ID)
+	if err != nil {
+		return "precheckout_error_try_again"
+	}
+	if user == nil {
+		return "precheckout_error_no_account"
+	}
+	if user.IsBlocked(time.Now()) {
+		return "precheckout_error_banned"
+	}
+	return ""
+}
+
+// ShowPurchaseHistory menampilkan riwayat pembayaran user (/purchases).
+func (ph *PaymentHandler) ShowPurchaseHistory(chatID int64, userID int64) {
+	lang := ph.getUserLang(userID)
+
+	payments, err := ph.DB.GetUserPayments(userID, purchaseHistoryLimit)
+	if err != nil {
+		log.Printf("ERROR: Could not load purchase history for user %d: %v", userID, err)
+		ph.Bot.Send(tgbotapi.NewMessage(chatID, ph.Localizer.Get(lang, "purchases_error")))
+		return
+	}
+	if len(payments) == 0 {
+		ph.Bot.Send(tgbotapi.NewMessage(chatID, ph.Localizer.Get(lang, "purchases_empty")))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(ph.Localizer.Get(lang, "purchases_title"))
+	sb.WriteString("\n\n")
+	for _, p := range payments {
+		date := "-"
+		if p.CreatedAt != nil {
+			date = p.CreatedAt.UTC().Format("2006-01-02 15:04")
+		}
+
+		var product string
+		if p.ProductType == database.ProductTypeSubscription {
+			product = ph.Localizer.Get(lang, "purchases_item_subscription")
+		} else {
+			product = ph.Localizer.Getf(lang, "purchases_item_credits", map[string]string{"credits": strconv.Itoa(p.Credits)})
+		}
+
+		amount := fmt.Sprintf("%d %s", p.Amount, p.Currency)
+		if p.Currency == "XTR" {
+			amount = fmt.Sprintf("%d ⭐", p.Amount)
+		}
+
+		sb.WriteString(fmt.Sprintf("• %s — %s — %s (%s)\n  <code>%s</code>\n", date, amount, html.EscapeString(product), ph.Localizer.Get(lang, "purchases_status_"+p.Status), html.EscapeString(p.ChargeID)))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(ph.Localizer.Get(lang, "purchases_footer"))
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	ph.Bot.Send(msg)
+}
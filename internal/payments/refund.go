@@ -0,0 +1,178 @@
+package payments
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// RefundError menjelaskan kenapa refund ditolak. Key adalah key di file locales.
+type RefundError struct {
+	Key  string
+	Args map[string]string
+}
+
+func (e *RefundError) Error() string {
+	return fmt.Sprintf("refund rejected (%s): %v", e.Key, e.Args)
+}
+
+// refundStars mengembalikan Stars ke user lewat refundStarPayment.
+func (ph *PaymentHandler) refundStars(userID int64, chargeID string) error {
+	params := tgbotapi.Params{"telegram_payment_charge_id": chargeID}
+	params.AddNonZero64("user_id", userID)
+	_, err := ph.Bot.MakeRequest("refundStarPayment", params)
+	return err
+}
+
+// RefundPayment dipakai oleh admin (/refund). Stars dikembalikan lalu kredit/premium
+// yang sudah diberikan ditarik kembali. Jika kredit dari pembayaran ini sudah terpakai
+// lebih dari RefundMaxSpentPercent, refund ditolak kecuali force bernilai true.
+func (ph *PaymentHandler) RefundPayment(chargeID string, adminID int64, force bool) (*database.Payment, error) {
+	payment, err := ph.DB.GetPaymentByChargeID(chargeID)
+	if err != nil {
+		return nil, &RefundError{Key: "refund_error_generic"}
+	}
+	if payment == nil {
+		return nil, &RefundError{Key: "refund_error_not_found", Args: map[string]string{"charge_id": chargeID}}
+	}
+	if payment.Status == database.PaymentStatusRefunded {
+		return nil, &RefundError{Key: "refund_error_already_refunded", Args: map[string]string{"charge_id": chargeID}}
+	}
+	if payment.Currency != "XTR" {
+		return nil, &RefundError{Key: "refund_error_not_stars", Args: map[string]string{"charge_id": chargeID}}
+	}
+
+	user, err := ph.DB.GetUserByTelegramID(payment.TelegramID)
+	if err != nil || user == nil {
+		return nil, &RefundError{Key: "refund_error_generic"}
+	}
+
+	// Hanya pembayaran yang sudah diberikan yang perlu ditarik kembali
+	delivered := payment.Status == database.PaymentStatusCompleted
+	creditsToReverse := 0
+	if delivered && payment.ProductType == database.ProductTypeCredits && payment.Credits > 0 {
+		spent := 0
+		if user.PaidCredits < payment.Credits {
+			spent = payment.Credits - user.PaidCredits
+		}
+		if spent*100 > ph.RefundMaxSpentPercent*payment.Credits && !force {
+			return nil, &RefundError{Key: "refund_error_spent", Args: map[string]string{
+				"charge_id": chargeID,
+				"spent":     strconv.Itoa(spent),
+				"credits":   strconv.Itoa(payment.Credits),
+				"threshold": strconv.Itoa(ph.RefundMaxSpentPercent),
+			}}
+		}
+		creditsToReverse = payment.Credits - spent
+	}
+
+	if err := ph.refundStars(payment.TelegramID, chargeID); err != nil {
+		log.Printf("ERROR: refundStarPayment failed for charge %s (user %d): %v", chargeID, payment.TelegramID, err)
+		return nil, &RefundError{Key: "refund_error_telegram", Args: map[string]string{"error": err.Error()}}
+	}
+
+	if updated, err := ph.DB.MarkPaymentRefunded(chargeID, adminID, "admin", creditsToReverse); err != nil || !updated {
+		// Stars sudah kembali; jangan tarik kredit dua kali jika refund lain sudah tercatat
+		log.Printf("WARN: Charge %s was refunded on Telegram but not marked in DB (updated=%v)", chargeID, updated)
+		if err == nil {
+			return nil, &RefundError{Key: "refund_error_already_refunded", Args: map[string]string{"charge_id": chargeID}}
+		}
+	}
+
+	if delivered {
+		ph.reverseDelivery(user, payment, creditsToReverse)
+	}
+	payment.Status = database.PaymentStatusRefunded
+	payment.CreditsReversed = creditsToReverse
+
+	log.Printf("INFO: Admin %d refunded charge %s (%d %s) of user %d, %d credits reversed", adminID, chargeID, payment.Amount, payment.Currency, payment.TelegramID, creditsToReverse)
+	ph.notifyRefund(user, payment, "refund_processed")
+	return payment, nil
+}
+
+// reverseDelivery menarik kembali kredit atau premium yang diberikan oleh pembayaran.
+func (ph *PaymentHandler) reverseDelivery(user *database.User, payment *database.Payment, creditsToReverse int) {
+	switch payment.ProductType {
+	case database.ProductTypeCredits:
+		// Diambil dari saldo terbaru, tidak pernah negatif
+		taken, _, err := ph.DB.TakeBalance(user.TelegramID, wallet.PaidCredits, creditsToReverse)
+		if err != nil {
+			log.Printf("ERROR: Refund of %s succeeded but reversing delivery for user %d failed", payment.ChargeID, user.TelegramID)
+			return
+		}
+		ph.DB.Record(user.TelegramID, database.TransactionRefund, wallet.PaidCredits, -taken, "Refund "+payment.ChargeID)
+		// Komisi referrer dari pembelian ini ikut ditarik
+		if ph.Referrals != nil {
+			ph.Referrals.OnRefund(payment.ChargeID)
+		}
+	case database.ProductTypeSubscription:
+		// Hanya pembayaran periode yang sedang berjalan yang mencabut premium. Refund periode
+		// sebelumnya cukup tercatat di payments tanpa menyentuh periode yang sedang dibayar.
+		if user.PeriodChargeID != payment.ChargeID {
+			log.Printf("INFO: Refunded subscription charge %s of user %d is not the current period (%s), premium kept", payment.ChargeID, user.TelegramID, user.PeriodChargeID)
+			return
+		}
+		subscriptionID := user.SubscriptionChargeID
+		revoked, err := ph.DB.RevokeSubscription(user.TelegramID, payment.ChargeID)
+		if err != nil {
+			log.Printf("ERROR: Refund of %s succeeded but reversing delivery for user %d failed", payment.ChargeID, user.TelegramID)
+			return
+		}
+		if !revoked {
+			return
+		}
+		params := tgbotapi.Params{
+			"telegram_payment_charge_id": subscriptionID,
+			"is_canceled":                "true",
+		}
+		params.AddNonZero64("user_id", user.TelegramID)
+		if _, err := ph.Bot.MakeRequest("editUserStarSubscription", params); err != nil {
+			log.Printf("WARN: Could not cancel subscription of user %d after refund: %v", user.TelegramID, err)
+		}
+		user.IsPremium = false
+		user.PremiumExpiresAt = nil
+		user.SubscriptionChargeID = ""
+		user.PeriodChargeID = ""
+		user.SubscriptionCanceled = false
+	}
+}
+
+// autoRefund mengembalikan Stars ketika kredit/premium gagal diberikan.
+// Mengembalikan true jika Stars berhasil dikembalikan.
+func (ph *PaymentHandler) autoRefund(payment *database.Payment, reason string, recorded bool) bool {
+	if payment.Currency != "XTR" {
+		return false
+	}
+	if err := ph.refundStars(payment.TelegramID, payment.ChargeID); err != nil {
+		log.Printf("ERROR: Automatic refund of charge %s for user %d failed (%s): %v", payment.ChargeID, payment.TelegramID, reason, err)
+		return false
+	}
+	if recorded {
+		ph.DB.MarkPaymentRefunded(payment.ChargeID, 0, reason, 0)
+	}
+	log.Printf("INFO: Automatically refunded charge %s of user %d (%s)", payment.ChargeID, payment.TelegramID, reason)
+
+	user := &database.User{TelegramID: payment.TelegramID, LanguageCode: ph.getUserLang(payment.TelegramID)}
+	ph.notifyRefund(user, payment, "refund_auto")
+	return true
+}
+
+func (ph *PaymentHandler) notifyRefund(user *database.User, payment *database.Payment, key string) {
+	lang := user.LanguageCode
+	if lang == "" {
+		lang = "en"
+	}
+	args := map[string]string{
+		"amount":    strconv.Itoa(payment.Amount),
+		"charge_id": payment.ChargeID,
+		"credits":   strconv.Itoa(payment.CreditsReversed),
+	}
+	msg := tgbotapi.NewMessage(user.TelegramID, ph.Localizer.Getf(lang, key, args))
+	msg.ParseMode = "HTML"
+	ph.Bot.Send(msg)
+}
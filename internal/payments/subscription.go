@@ -0,0 +1,330 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Telegram hanya menerima subscription_period 2592000 detik (30 hari) untuk invoice Stars.
+const subscriptionPeriod = 30 * 24 * time.Hour
+
+const subscriptionPayloadPrefix = "premium_sub:"
+
+// SubscriptionPlan adalah produk langganan Premium yang dijual lewat Stars.
+type SubscriptionPlan struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	StarsAmount int    `json:"stars_amount"`
+	GraceDays   int    `json:"grace_days"` // toleransi setelah expired sambil menunggu pembayaran perpanjangan
+}
+
+func loadSubscriptionPlan(file string) SubscriptionPlan {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read subscription file %s: %v", file, err)
+	}
+	var plan SubscriptionPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		log.Fatalf("FATAL: Could not parse subscription file %s: %v", file, err)
+	}
+	if plan.ID == "" || plan.StarsAmount <= 0 {
+		log.Fatalf("FATAL: Subscription plan in %s needs an id and a positive stars_amount", file)
+	}
+	log.Printf("INFO: Loaded subscription plan '%s' (%d Stars / 30 days)", plan.ID, plan.StarsAmount)
+	return plan
+}
+
+func (p SubscriptionPlan) gracePeriod() time.Duration {
+	return time.Duration(p.GraceDays) * 24 * time.Hour
+}
+
+// IsPremiumActive bernilai true jika user premium dan belum melewati masa tenggang.
+// Premium yang diset manual (tanpa premium_expires_at) selalu aktif.
+func (ph *PaymentHandler) IsPremiumActive(user *database.User) bool {
+	if !user.IsPremium {
+		return false
+	}
+	if user.PremiumExpiresAt == nil {
+		return true
+	}
+	return time.Now().Before(user.PremiumExpiresAt.Add(ph.Subscription.gracePeriod()))
+}
+
+func isSubscriptionPayload(payload string) bool {
+	return strings.HasPrefix(payload, subscriptionPayloadPrefix)
+}
+
+// createSubscriptionLink membuat link invoice Stars berulang.
+// Invoice dengan subscription_period hanya bisa dibuat lewat createInvoiceLink.
+func (ph *PaymentHandler) createSubscriptionLink() (string, error) {
+	prices, err := json.Marshal([]tgbotapi.LabeledPrice{
+		{Label: ph.Subscription.Title, Amount: ph.Subscription.StarsAmount},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	params := tgbotapi.Params{
+		"title":       ph.Subscription.Title,
+		"description": ph.Subscription.Description,
+		"payload":     subscriptionPayloadPrefix + ph.Subscription.ID,
+		"currency":    "XTR",
+		"prices":      string(prices),
+	}
+	params.AddNonZero("subscription_period", int(subscriptionPeriod.Seconds()))
+
+	resp, err := ph.Bot.MakeRequest("createInvoiceLink", params)
+	if err != nil {
+		return "", err
+	}
+	var link string
+	if err := json.Unmarshal(resp.Result, &link); err != nil {
+		return "", err
+	}
+	return link, nil
+}
+
+// SendSubscriptionInvoice mengirim tombol pembayaran langganan Premium.
+func (ph *PaymentHandler) SendSubscriptionInvoice(chatID int64, userID int64) {
+	lang := ph.getUserLang(userID)
+
+	user, err := ph.DB.GetUserByTelegramID(userID)
+	if err == nil && user != nil && ph.IsPremiumActive(user) && user.SubscriptionChargeID != "" {
+		// Sudah berlangganan: tampilkan status saja agar tidak terjadi langganan ganda
+		ph.ShowSubscriptionStatus(chatID, userID)
+		return
+	}
+
+	link, err := ph.createSubscriptionLink()
+	if err != nil {
+		log.Printf("ERROR: Failed to create subscription invoice link for user %d: %v", userID, err)
+		ph.Bot.Send(tgbotapi.NewMessage(chatID, ph.Localizer.Get(lang, "subscription_error")))
+		return
+	}
+
+	args := map[string]string{
+		"title": ph.Subscription.Title,
+		"stars": strconv.Itoa(ph.Subscription.StarsAmount),
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(ph.Localizer.Getf(lang, "subscription_button_pay", args), link),
+		),
+	)
+	msg := tgbotapi.NewMessage(chatID, ph.Localizer.Getf(lang, "subscription_invoice_text", args))
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = &keyboard
+	ph.Bot.Send(msg)
+}
+
+// handleSubscriptionPayment memproses pembayaran pertama maupun perpanjangan otomatis.
+// Mengembalikan false jika premium gagal diaktifkan.
+func (ph *PaymentHandler) handleSubscriptionPayment(message *tgbotapi.Message) bool {
+	paymentInfo := message.SuccessfulPayment
+	userID := message.From.ID
+	lang := ph.getUserLang(userID)
+
+	user, err := ph.DB.GetUserByTelegramID(userID)
+	if err != nil || user == nil {
+		log.Printf("ERROR: User %d paid for a subscription but could not be found in DB", userID)
+		return false
+	}
+
+	now := time.Now().UTC()
+	// Perpanjangan = masih ada langganan Stars yang belum lewat masa tenggang.
+	// Masa aktif baru dihitung dari expired sebelumnya agar tidak bergeser.
+	renewal := user.SubscriptionChargeID != "" && user.PremiumExpiresAt != nil && ph.IsPremiumActive(user)
+
+	start := now
+	if user.PremiumExpiresAt != nil && (renewal || user.PremiumExpiresAt.After(now)) {
+		start = user.PremiumExpiresAt.UTC()
+	}
+	expiresAt := start.Add(subscriptionPeriod)
+
+	user.IsPremium = true
+	user.PremiumExpiresAt = &expiresAt
+	// Setiap pembayaran (pertama maupun perpanjangan) menjadi pembayaran periode yang berjalan
+	user.PeriodChargeID = paymentInfo.TelegramPaymentChargeID
+	if !renewal {
+		user.SubscriptionChargeID = paymentInfo.TelegramPaymentChargeID
+		user.SubscriptionCanceled = false
+	}
+
+	if err := ph.DB.SetSubscription(user); err != nil {
+		log.Printf("ERROR: Failed to activate subscription for user %d (charge %s)", userID, paymentInfo.TelegramPaymentChargeID)
+		return false
+	}
+
+	key := "subscription_activated"
+	if renewal {
+		key = "subscription_renewed"
+	}
+	log.Printf("INFO: User %d subscription %s until %s (charge %s)", userID, strings.TrimPrefix(key, "subscription_"), expiresAt.Format(time.RFC3339), paymentInfo.TelegramPaymentChargeID)
+
+	msg := tgbotapi.NewMessage(userID, ph.Localizer.Getf(lang, key, map[string]string{"expires": formatSubscriptionTime(expiresAt)}))
+	msg.ParseMode = "HTML"
+	ph.Bot.Send(msg)
+	return true
+}
+
+// ShowSubscriptionStatus menampilkan status langganan beserta tombol aksi yang relevan.
+func (ph *PaymentHandler) ShowSubscriptionStatus(chatID int64, userID int64, messageID ...int) {
+	lang := ph.getUserLang(userID)
+	user, err := ph.DB.GetUserByTelegramID(userID)
+	if err != nil || user == nil {
+		return
+	}
+
+	var text string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	now := time.Now()
+
+	switch {
+	case ph.IsPremiumActive(user) && user.PremiumExpiresAt == nil:
+		text = ph.Localizer.Get(lang, "subscription_status_lifetime")
+
+	case ph.IsPremiumActive(user) && now.After(*user.PremiumExpiresAt):
+		args := map[string]string{
+			"expires":     formatSubscriptionTime(*user.PremiumExpiresAt),
+			"grace_until": formatSubscriptionTime(user.PremiumExpiresAt.Add(ph.Subscription.gracePeriod())),
+		}
+		text = ph.Localizer.Getf(lang, "subscription_status_grace", args)
+
+	case ph.IsPremiumActive(user):
+		renew := ph.Localizer.Get(lang, "subscription_renew_on")
+		if user.SubscriptionCanceled || user.SubscriptionChargeID == "" {
+			renew = ph.Localizer.Get(lang, "subscription_renew_off")
+		}
+		args := map[string]string{
+			"expires": formatSubscriptionTime(*user.PremiumExpiresAt),
+			"renew":   renew,
+		}
+		text = ph.Localizer.Getf(lang, "subscription_status_active", args)
+
+		if user.SubscriptionChargeID != "" {
+			if user.SubscriptionCanceled {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "subscription_button_resume"), "sub_resume"),
+				))
+			} else {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Get(lang, "subscription_button_cancel"), "sub_cancel"),
+				))
+			}
+		}
+
+	default:
+		args := map[string]string{
+			"title":       ph.Subscription.Title,
+			"description": ph.Subscription.Description,
+			"stars":       strconv.Itoa(ph.Subscription.StarsAmount),
+		}
+		text = ph.Localizer.Getf(lang, "subscription_status_none", args)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(ph.Localizer.Getf(lang, "subscription_button_subscribe", args), "sub_buy"),
+		))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if len(messageID) > 0 {
+		msg := tgbotapi.NewEditMessageText(chatID, messageID[0], text)
+		msg.ParseMode = "HTML"
+		if len(rows) > 0 {
+			msg.ReplyMarkup = &keyboard
+		}
+		ph.Bot.Send(msg)
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	if len(rows) > 0 {
+		msg.ReplyMarkup = &keyboard
+	}
+	ph.Bot.Send(msg)
+}
+
+// SetSubscriptionCanceled mematikan / menghidupkan kembali perpanjangan otomatis di sisi Telegram.
+// Premium tetap aktif sampai premium_expires_at.
+func (ph *PaymentHandler) SetSubscriptionCanceled(chatID int64, userID int64, messageID int, canceled bool) {
+	lang := ph.getUserLang(userID)
+	user, err := ph.DB.GetUserByTelegramID(userID)
+	if err != nil || user == nil || user.SubscriptionChargeID == "" || !ph.IsPremiumActive(user) {
+		ph.ShowSubscriptionStatus(chatID, userID, messageID)
+		return
+	}
+
+	params := tgbotapi.Params{
+		"telegram_payment_charge_id": user.SubscriptionChargeID,
+	}
+	params.AddNonZero64("user_id", userID)
+	params["is_canceled"] = strconv.FormatBool(canceled)
+
+	if _, err := ph.Bot.MakeRequest("editUserStarSubscription", params); err != nil {
+		log.Printf("ERROR: editUserStarSubscription failed for user %d (canceled=%v): %v", userID, canceled, err)
+		ph.Bot.Send(tgbotapi.NewMessage(chatID, ph.Localizer.Get(lang, "subscription_error")))
+		return
+	}
+
+	user.SubscriptionCanceled = canceled
+	if err := ph.DB.SetSubscriptionCanceled(userID, canceled); err != nil {
+		ph.Bot.Send(tgbotapi.NewMessage(chatID, ph.Localizer.Get(lang, "subscription_error")))
+		return
+	}
+	log.Printf("INFO: User %d set subscription canceled=%v", userID, canceled)
+
+	key := "subscription_resumed"
+	if canceled {
+		key = "subscription_canceled"
+	}
+	ph.Bot.Send(tgbotapi.NewMessage(chatID, ph.Localizer.Getf(lang, key, map[string]string{"expires": formatSubscriptionTime(*user.PremiumExpiresAt)})))
+	ph.ShowSubscriptionStatus(chatID, userID, messageID)
+}
+
+// StartSubscriptionWatcher menurunkan user ke tier free secara berkala
+// setelah langganan lewat masa tenggang tanpa pembayaran perpanjangan.
+func (ph *PaymentHandler) StartSubscriptionWatcher(interval time.Duration) {
+	go func() {
+		ph.expireLapsedSubscriptions()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ph.expireLapsedSubscriptions()
+		}
+	}()
+}
+
+func (ph *PaymentHandler) expireLapsedSubscriptions() {
+	before := time.Now().Add(-ph.Subscription.gracePeriod())
+	users, err := ph.DB.GetLapsedPremiumUsers(before)
+	if err != nil {
+		return
+	}
+	for i := range users {
+		user := &users[i]
+		if expired, err := ph.DB.ExpireSubscription(user.TelegramID, before); err != nil || !expired {
+			continue
+		}
+		log.Printf("INFO: Premium subscription of user %d lapsed, downgraded to free", user.TelegramID)
+
+		lang := user.LanguageCode
+		if lang == "" {
+			lang = "en"
+		}
+		msg := tgbotapi.NewMessage(user.TelegramID, ph.Localizer.Get(lang, "subscription_expired"))
+		ph.Bot.Send(msg)
+	}
+}
+
+func formatSubscriptionTime(t time.Time) string {
+	return fmt.Sprintf("%s UTC", t.UTC().Format("2006-01-02 15:04"))
+}
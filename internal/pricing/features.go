@@ -0,0 +1,48 @@
+package pricing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+)
+
+// Fitur non-model yang biayanya diatur di pricing.json.
+const (
+	FeatureChat        = "chat"         // balasan chat mode (teks)
+	FeatureChatVision  = "chat_vision"  // balasan chat mode untuk foto
+	FeaturePromptText  = "prompt_text"  // prompt assistant: ide teks -> prompt
+	FeaturePromptImage = "prompt_image" // prompt assistant: gambar -> prompt
+)
+
+// Catatan: output_tokens hanya diketahui untuk chat (biaya dihitung setelah jawaban diterima).
+// Prompt assistant menampilkan biaya sebelum generate, sehingga dihitung dengan output_tokens = 0.
+
+// Features memetakan nama fitur ke aturan biayanya.
+type Features map[string]Rule
+
+// LoadFeatures membaca pricing.json. Fitur yang tidak ada memakai biaya 1.
+func LoadFeatures(file string) Features {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read pricing file %s: %v", file, err)
+	}
+	var features Features
+	if err := json.Unmarshal(data, &features); err != nil {
+		log.Fatalf("FATAL: Could not parse pricing file %s: %v", file, err)
+	}
+	for name, rule := range features {
+		if err := rule.Validate(); err != nil {
+			log.Fatalf("FATAL: Invalid pricing for feature '%s' in %s: %v", name, file, err)
+		}
+	}
+	log.Printf("INFO: Loaded pricing for %d features", len(features))
+	return features
+}
+
+// Rule mengembalikan aturan biaya sebuah fitur.
+func (f Features) Rule(feature string) Rule {
+	if rule, ok := f[feature]; ok {
+		return rule
+	}
+	return Flat(1, false)
+}
@@ -0,0 +1,168 @@
+// Package pricing menghitung biaya generasi dari parameter yang benar-benar dikirim,
+// sehingga preview di dashboard, cek saldo, dan pemotongan kredit memakai angka yang sama.
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Input khusus yang bukan parameter model. Selain ini, Inputs berisi parameter
+// model apa adanya (misal "resolution", "duration", "num_inference_steps").
+const (
+	InputNumOutputs   = "num_outputs"   // jumlah gambar/video yang dihasilkan
+	InputInputImages  = "input_images"  // jumlah gambar input yang dikirim user
+	InputOutputTokens = "output_tokens" // perkiraan token jawaban (chat / prompt assistant)
+)
+
+// Inputs adalah nilai yang dipakai untuk menghitung biaya.
+type Inputs map[string]interface{}
+
+// Rule adalah aturan biaya, ditulis di models.json (field "pricing") atau pricing.json.
+//
+//	unit  = (base + Σ term) × Π multiplier, dibulatkan ke atas lalu dibatasi min/max
+//	total = unit × num_outputs (jika per_output)
+type Rule struct {
+	Base        float64      `json:"base"`
+	PerOutput   bool         `json:"per_output,omitempty"`
+	Terms       []Term       `json:"terms,omitempty"`
+	Multipliers []Multiplier `json:"multipliers,omitempty"`
+	Min         int          `json:"min,omitempty"`
+	Max         int          `json:"max,omitempty"` // 0 = tanpa batas
+}
+
+// Term menambah biaya linear dari input numerik, misal durasi video atau jumlah step.
+type Term struct {
+	Input string  `json:"input"`
+	Rate  float64 `json:"rate"`           // biaya per unit (atau per step jika Step diisi)
+	Over  float64 `json:"over,omitempty"` // hanya bagian di atas nilai ini yang dihitung
+	Step  float64 `json:"step,omitempty"` // dibulatkan ke atas per step, misal per 1000 token
+}
+
+// Multiplier mengalikan biaya berdasarkan nilai sebuah input, misal resolusi.
+// Nilai yang tidak terdaftar memakai faktor 1.
+type Multiplier struct {
+	Input  string             `json:"input"`
+	Values map[string]float64 `json:"values"`
+}
+
+// Flat membuat aturan biaya tetap, dipakai untuk model lama yang hanya punya "cost".
+func Flat(cost int, perOutput bool) Rule {
+	return Rule{Base: float64(cost), PerOutput: perOutput}
+}
+
+// Validate memeriksa aturan saat config dimuat.
+func (r Rule) Validate() error {
+	if r.Base < 0 || r.Min < 0 || r.Max < 0 {
+		return fmt.Errorf("base, min and max must not be negative")
+	}
+	if r.Max > 0 && r.Min > r.Max {
+		return fmt.Errorf("min (%d) is greater than max (%d)", r.Min, r.Max)
+	}
+	for _, t := range r.Terms {
+		if t.Input == "" {
+			return fmt.Errorf("term without input")
+		}
+		if t.Rate < 0 || t.Over < 0 || t.Step < 0 {
+			return fmt.Errorf("term %s: rate, over and step must not be negative", t.Input)
+		}
+	}
+	for _, m := range r.Multipliers {
+		if m.Input == "" || len(m.Values) == 0 {
+			return fmt.Errorf("multiplier needs an input and at least one value")
+		}
+		for value, factor := range m.Values {
+			if factor < 0 {
+				return fmt.Errorf("multiplier %s=%s must not be negative", m.Input, value)
+			}
+		}
+	}
+	return nil
+}
+
+// UnitCost menghitung biaya per output (sebelum multiplier tier user).
+func (r Rule) UnitCost(in Inputs) int {
+	cost := r.Base
+	for _, t := range r.Terms {
+		value, ok := number(in[t.Input])
+		if !ok {
+			continue
+		}
+		amount := math.Max(0, value-t.Over)
+		if t.Step > 0 {
+			amount = math.Ceil(amount/t.Step - 1e-9)
+		}
+		cost += amount * t.Rate
+	}
+	for _, m := range r.Multipliers {
+		raw, ok := in[m.Input]
+		if !ok || raw == nil {
+			continue
+		}
+		if factor, ok := m.Values[formatValue(raw)]; ok {
+			cost *= factor
+		}
+	}
+
+	unit := int(math.Ceil(cost - 1e-9))
+	if unit < r.Min {
+		unit = r.Min
+	}
+	if r.Max > 0 && unit > r.Max {
+		unit = r.Max
+	}
+	return unit
+}
+
+// Units adalah pengali untuk biaya per output (jumlah output jika per_output, selain itu 1).
+func (r Rule) Units(in Inputs) int {
+	if !r.PerOutput {
+		return 1
+	}
+	if n, ok := number(in[InputNumOutputs]); ok && n > 1 {
+		return int(n)
+	}
+	return 1
+}
+
+// EstimateTokens memperkirakan jumlah token dari teks (±4 karakter per token),
+// karena API Replicate tidak mengembalikan jumlah token.
+func EstimateTokens(text string) int {
+	return (len([]rune(text)) + 3) / 4
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		// Nilai seperti "5s", "1080p" atau "4 MP" tetap bisa dipakai sebagai angka
+		trimmed := strings.TrimRight(strings.TrimSpace(v), "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ ")
+		f, err := strconv.ParseFloat(trimmed, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,203 @@
+package referral
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	identicalUsageSample = 10  // referee lain yang dibandingkan pola generatenya
+	usageHistoryRows     = 100 // baris riwayat saldo yang dibaca per referee
+)
+
+// Assessment adalah hasil penilaian risiko satu hadiah referral.
+type Assessment struct {
+	Score   int
+	Reasons []string
+}
+
+func (a *Assessment) add(signal string, weight int) {
+	if weight == 0 {
+		return
+	}
+	a.Score += weight
+	a.Reasons = append(a.Reasons, signal)
+}
+
+// screen menilai bonus daftar/milestone dan mengisi status serta skor risikonya.
+// generations adalah jumlah generate milestone, 0 untuk bonus daftar.
+func (p *Program) screen(reward *database.ReferralReward, referrer, referee *database.User, generations int) {
+	reward.Status = database.ReferralRewardPaid
+	fraud := p.Rules.Fraud
+	if !fraud.Enabled() {
+		return
+	}
+
+	a := p.assess(referrer, referee, generations)
+	reward.RiskScore = a.Score
+	reward.RiskReasons = strings.Join(a.Reasons, ",")
+	switch {
+	case a.Score >= fraud.DenyScore:
+		reward.Status = database.ReferralRewardDenied
+	case a.Score >= fraud.HoldScore:
+		reward.Status = database.ReferralRewardHeld
+	}
+}
+
+func (p *Program) assess(referrer, referee *database.User, generations int) Assessment {
+	fraud := p.Rules.Fraud
+	var a Assessment
+
+	if fraud.NewAccountMinID > 0 && referee.TelegramID >= fraud.NewAccountMinID {
+		a.add(SignalNewAccount, fraud.Weights[SignalNewAccount])
+	}
+	if fraud.Weights[SignalPremium] != 0 && p.isPremium(referee.TelegramID) {
+		a.add(SignalPremium, fraud.Weights[SignalPremium])
+	}
+	if flagged, _ := p.DB.IsReferrerFlagged(referrer.TelegramID); flagged {
+		a.add(SignalFlaggedReferrer, fraud.Weights[SignalFlaggedReferrer])
+	}
+
+	referees, err := p.DB.GetReferees(referrer.TelegramID)
+	if err != nil {
+		return a
+	}
+	if p.isBurst(referee, referees) {
+		a.add(SignalBurst, fraud.Weights[SignalBurst])
+	}
+	if generations > 0 {
+		if p.hasIdenticalUsage(referee, referees, generations) {
+			a.add(SignalIdenticalUsage, fraud.Weights[SignalIdenticalUsage])
+		}
+		if p.hasInactiveReferees(referee, referees) {
+			a.add(SignalInactiveReferees, fraud.Weights[SignalInactiveReferees])
+		}
+	}
+	return a
+}
+
+// isBurst: banyak akun mendaftar lewat referrer yang sama di sekitar waktu daftar referee.
+func (p *Program) isBurst(referee *database.User, referees []database.User) bool {
+	fraud := p.Rules.Fraud
+	if fraud.BurstSignups <= 0 || referee.CreatedAt == nil {
+		return false
+	}
+	window := time.Duration(fraud.BurstWindowMinutes) * time.Minute
+	count := 0
+	for _, u := range referees {
+		if u.CreatedAt == nil {
+			continue
+		}
+		diff := u.CreatedAt.Sub(*referee.CreatedAt)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= window {
+			count++
+		}
+	}
+	return count >= fraud.BurstSignups
+}
+
+// hasIdenticalUsage: generate pertama referee sama persis (jenis, biaya, model) dengan referee lain dari referrer yang sama.
+func (p *Program) hasIdenticalUsage(referee *database.User, referees []database.User, generations int) bool {
+	fraud := p.Rules.Fraud
+	if fraud.IdenticalUsageMin <= 1 {
+		return false
+	}
+	fingerprint := p.usageFingerprint(referee.TelegramID, generations)
+	if fingerprint == "" {
+		return false
+	}
+
+	matches, compared := 1, 0
+	for _, u := range referees {
+		if u.TelegramID == referee.TelegramID || u.GeneratedImageCount < generations {
+			continue
+		}
+		if compared == identicalUsageSample {
+			break
+		}
+		compared++
+		if p.usageFingerprint(u.TelegramID, generations) == fingerprint {
+			matches++
+		}
+	}
+	return matches >= fraud.IdenticalUsageMin
+}
+
+// usageFingerprint merangkum n pemakaian saldo pertama user dari riwayat saldo.
+func (p *Program) usageFingerprint(telegramID int64, n int) string {
+	transactions, _, err := p.DB.GetTransactions(telegramID, 0, usageHistoryRows)
+	if err != nil {
+		return ""
+	}
+	var spends []string
+	// Riwayat terbaru dulu, dibaca dari belakang agar urut sejak daftar
+	for i := len(transactions) - 1; i >= 0 && len(spends) < n; i-- {
+		tx := transactions[i]
+		if tx.Amount >= 0 {
+			continue
+		}
+		spends = append(spends, fmt.Sprintf("%s:%d:%s", tx.Kind, tx.Amount, tx.Description))
+	}
+	if len(spends) < n {
+		return ""
+	}
+	return strings.Join(spends, "|")
+}
+
+// hasInactiveReferees: sebagian besar referee lama referrer berhenti tepat di salah satu milestone.
+func (p *Program) hasInactiveReferees(referee *database.User, referees []database.User) bool {
+	fraud := p.Rules.Fraud
+	if fraud.InactiveMinReferees <= 0 || len(p.Rules.Milestones) == 0 {
+		return false
+	}
+	milestoneAt := make(map[int]bool)
+	first := p.Rules.Milestones[0].Generations
+	for _, m := range p.Rules.Milestones {
+		milestoneAt[m.Generations] = true
+		if m.Generations < first {
+			first = m.Generations
+		}
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -fraud.InactiveAfterDays)
+	total, stopped := 0, 0
+	for _, u := range referees {
+		if u.TelegramID == referee.TelegramID || u.CreatedAt == nil || u.CreatedAt.After(cutoff) || u.GeneratedImageCount < first {
+			continue
+		}
+		total++
+		if milestoneAt[u.GeneratedImageCount] {
+			stopped++
+		}
+	}
+	return total >= fraud.InactiveMinReferees && float64(stopped)/float64(total) >= fraud.InactiveRatio
+}
+
+// isPremium membaca flag Telegram Premium lewat getChatMember (library belum punya field is_premium).
+func (p *Program) isPremium(telegramID int64) bool {
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", telegramID)
+	params.AddNonZero64("user_id", telegramID)
+	resp, err := p.Bot.MakeRequest("getChatMember", params)
+	if err != nil {
+		return false
+	}
+	var member struct {
+		User struct {
+			IsPremium bool `json:"is_premium"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(resp.Result, &member); err != nil {
+		return false
+	}
+	return member.User.IsPremium
+}
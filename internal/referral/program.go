@@ -0,0 +1,306 @@
+package referral
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"telegram-ai-bot/internal/database"
+	"telegram-ai-bot/internal/localization"
+	"telegram-ai-bot/internal/wallet"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// leaderboardTTL adalah lama cache leaderboard sebelum dihitung ulang.
+const leaderboardTTL = 10 * time.Minute
+
+// Program membayar hadiah referral dan menyediakan data dashboard/leaderboard.
+type Program struct {
+	Bot       *tgbotapi.BotAPI
+	DB        *database.Client
+	Localizer *localization.Localizer
+	Wallet    *wallet.Config
+	Rules     *Rules
+	AdminIDs  []int64 // penerima notifikasi referrer yang ditandai fraud
+
+	leaderboardMu      sync.Mutex
+	leaderboard        []LeaderboardEntry
+	leaderboardUpdated time.Time
+}
+
+func NewProgram(bot *tgbotapi.BotAPI, db *database.Client, loc *localization.Localizer, walletConfig *wallet.Config, rules *Rules, adminIDs []int64) *Program {
+	return &Program{Bot: bot, DB: db, Localizer: loc, Wallet: walletConfig, Rules: rules, AdminIDs: adminIDs}
+}
+
+// OnSignup memberi bonus daftar ke user baru yang datang lewat link referral.
+func (p *Program) OnSignup(referee *database.User) {
+	if referee.ReferrerID == 0 || p.Rules.SignupBonus.Amount == 0 {
+		return
+	}
+	referrer := p.referrer(referee)
+	if referrer == nil {
+		return
+	}
+	reward := &database.ReferralReward{
+		BeneficiaryID: referee.TelegramID,
+		ReferrerID:    referee.ReferrerID,
+		RefereeID:     referee.TelegramID,
+		Kind:          database.ReferralRewardSignup,
+		Reference:     database.ReferralRewardSignup,
+		Currency:      p.Rules.SignupBonus.Currency,
+		Amount:        p.Rules.SignupBonus.Amount,
+	}
+	p.screen(reward, referrer, referee, 0)
+	p.grant(reward, referee, "referral_signup_bonus", nil)
+}
+
+// OnGeneration dipanggil setelah GeneratedImageCount referee bertambah.
+func (p *Program) OnGeneration(referee *database.User) {
+	if referee.ReferrerID == 0 {
+		return
+	}
+	for _, m := range p.Rules.Milestones {
+		if m.Generations != referee.GeneratedImageCount || m.Reward.Amount == 0 {
+			continue
+		}
+		referrer := p.referrer(referee)
+		if referrer == nil {
+			return
+		}
+		reward := &database.ReferralReward{
+			BeneficiaryID: referrer.TelegramID,
+			ReferrerID:    referrer.TelegramID,
+			RefereeID:     referee.TelegramID,
+			Kind:          database.ReferralRewardMilestone,
+			Reference:     m.ID,
+			Currency:      m.Reward.Currency,
+			Amount:        m.Reward.Amount,
+		}
+		p.screen(reward, referrer, referee, m.Generations)
+		p.grant(reward, referrer, "referral_milestone_bonus", map[string]string{
+			"referee":     MaskName(referee),
+			"generations": strconv.Itoa(m.Generations),
+		})
+	}
+}
+
+// OnPurchase membayar komisi ke referrer dari kredit yang dibeli referee dengan Stars.
+func (p *Program) OnPurchase(refereeID int64, credits int, chargeID string) {
+	commission := credits * p.Rules.CommissionPercent / 100
+	if commission <= 0 {
+		return
+	}
+	referee, err := p.DB.GetUserByTelegramID(refereeID)
+	if err != nil || referee == nil || referee.ReferrerID == 0 {
+		return
+	}
+	referrer := p.referrer(referee)
+	if referrer == nil {
+		return
+	}
+	p.grant(&database.ReferralReward{
+		BeneficiaryID: referrer.TelegramID,
+		ReferrerID:    referrer.TelegramID,
+		RefereeID:     referee.TelegramID,
+		Kind:          database.ReferralRewardCommission,
+		Reference:     chargeID,
+		Currency:      wallet.PaidCredits,
+		Amount:        commission,
+		Status:        database.ReferralRewardPaid,
+	}, referrer, "referral_commission", map[string]string{
+		"referee": MaskName(referee),
+		"percent": strconv.Itoa(p.Rules.CommissionPercent),
+	})
+}
+
+func (p *Program) referrer(referee *database.User) *database.User {
+	referrer, err := p.DB.GetUserByTelegramID(referee.ReferrerID)
+	if err != nil || referrer == nil || referrer.IsBanned {
+		return nil
+	}
+	return referrer
+}
+
+// grant mencatat hadiah (sekali per referee/jenis/referensi) sesuai statusnya: hadiah paid langsung
+// dibayar, hadiah held/denied tidak menambah saldo dan referrernya ditandai untuk diperiksa admin.
+func (p *Program) grant(reward *database.ReferralReward, beneficiary *database.User, messageKey string, args map[string]string) {
+	inserted, err := p.DB.InsertReferralReward(reward)
+	if err != nil || !inserted {
+		return
+	}
+
+	switch reward.Status {
+	case database.ReferralRewardPaid:
+		p.pay(reward, beneficiary, messageKey, args)
+	case database.ReferralRewardHeld:
+		log.Printf("WARN: Referral %s reward %d for user %d held (score %d: %s)", reward.Kind, reward.ID, beneficiary.TelegramID, reward.RiskScore, reward.RiskReasons)
+		p.notify(beneficiary, reward, "referral_reward_held", nil)
+		p.flag(reward)
+	case database.ReferralRewardDenied:
+		log.Printf("WARN: Referral %s reward %d for user %d denied (score %d: %s)", reward.Kind, reward.ID, beneficiary.TelegramID, reward.RiskScore, reward.RiskReasons)
+		p.flag(reward)
+	}
+}
+
+// pay menambah saldo penerima hadiah yang sudah berstatus paid.
+func (p *Program) pay(reward *database.ReferralReward, beneficiary *database.User, messageKey string, args map[string]string) {
+	if _, err := p.DB.AdjustBalance(beneficiary.TelegramID, reward.Currency, reward.Amount); err != nil {
+		log.Printf("ERROR: Referral %s reward %d for user %d could not be credited: %v", reward.Kind, reward.ID, beneficiary.TelegramID, err)
+		p.DB.SetReferralRewardStatus(reward.ID, database.ReferralRewardFailed)
+		return
+	}
+	kind := database.TransactionReferralBonus
+	if reward.Kind == database.ReferralRewardCommission {
+		kind = database.TransactionReferralCommission
+	}
+	p.DB.Record(beneficiary.TelegramID, kind, reward.Currency, reward.Amount, fmt.Sprintf("Referral %s %d", reward.Kind, reward.RefereeID))
+	log.Printf("INFO: Referral %s reward: %d %s to user %d (referee %d)", reward.Kind, reward.Amount, reward.Currency, beneficiary.TelegramID, reward.RefereeID)
+	p.notify(beneficiary, reward, messageKey, args)
+}
+
+// notify mengirim pesan tentang hadiah ke user; {amount}, {currency} dan {symbol} diisi dari hadiahnya.
+func (p *Program) notify(user *database.User, reward *database.ReferralReward, messageKey string, args map[string]string) {
+	lang := user.LanguageCode
+	if lang == "" {
+		lang = "en"
+	}
+	if args == nil {
+		args = make(map[string]string)
+	}
+	args["amount"] = strconv.Itoa(reward.Amount)
+	args["currency"] = p.Wallet.DisplayName(reward.Currency, lang)
+	args["symbol"] = p.Wallet.Symbol(reward.Currency)
+	msg := tgbotapi.NewMessage(user.TelegramID, p.Localizer.Getf(lang, messageKey, args))
+	msg.ParseMode = "HTML"
+	p.Bot.Send(msg)
+}
+
+// Stats adalah ringkasan dashboard referral seorang referrer.
+type Stats struct {
+	Invited   int            // user yang daftar lewat link
+	Active    int            // referee yang mencapai minimal satu milestone
+	Converted int            // referee yang pernah membeli
+	Earnings  map[string]int // total hadiah per mata uang
+	Recent    []database.User
+}
+
+func (p *Program) Stats(referrerID int64, recent int) (*Stats, error) {
+	referees, err := p.DB.GetReferees(referrerID)
+	if err != nil {
+		return nil, err
+	}
+	rewards, err := p.DB.GetReferralRewardsOf(referrerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{Invited: len(referees), Earnings: make(map[string]int)}
+	active := make(map[int64]bool)
+	for _, r := range rewards {
+		if r.Status != database.ReferralRewardPaid {
+			continue
+		}
+		stats.Earnings[r.Currency] += r.Amount
+		if r.Kind == database.ReferralRewardMilestone {
+			active[r.RefereeID] = true
+		}
+	}
+	stats.Active = len(active)
+
+	ids := make([]int64, len(referees))
+	for i, u := range referees {
+		ids[i] = u.TelegramID
+	}
+	purchasers, err := p.DB.GetPurchasers(ids)
+	if err == nil {
+		stats.Converted = len(purchasers)
+	}
+
+	if len(referees) > recent {
+		referees = referees[:recent]
+	}
+	stats.Recent = referees
+	return stats, nil
+}
+
+// LeaderboardEntry adalah satu baris leaderboard (peringkat dari jumlah referee aktif).
+type LeaderboardEntry struct {
+	TelegramID int64
+	Name       string
+	Active     int
+}
+
+// Leaderboard mengembalikan referrer teratas, di-cache selama leaderboardTTL.
+func (p *Program) Leaderboard() ([]LeaderboardEntry, error) {
+	p.leaderboardMu.Lock()
+	defer p.leaderboardMu.Unlock()
+	if p.leaderboard != nil && time.Since(p.leaderboardUpdated) < leaderboardTTL {
+		return p.leaderboard, nil
+	}
+
+	rewards, err := p.DB.GetMilestoneRewards()
+	if err != nil {
+		return nil, err
+	}
+	referees := make(map[int64]map[int64]bool)
+	for _, r := range rewards {
+		if referees[r.ReferrerID] == nil {
+			referees[r.ReferrerID] = make(map[int64]bool)
+		}
+		referees[r.ReferrerID][r.RefereeID] = true
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(referees))
+	for id, set := range referees {
+		entries = append(entries, LeaderboardEntry{TelegramID: id, Active: len(set)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Active != entries[j].Active {
+			return entries[i].Active > entries[j].Active
+		}
+		return entries[i].TelegramID < entries[j].TelegramID
+	})
+	if len(entries) > p.Rules.LeaderboardSize {
+		entries = entries[:p.Rules.LeaderboardSize]
+	}
+
+	ids := make([]int64, len(entries))
+	for i, e := range entries {
+		ids[i] = e.TelegramID
+	}
+	users, _ := p.DB.GetUsersByIDs(ids)
+	names := make(map[int64]string)
+	for i := range users {
+		names[users[i].TelegramID] = MaskName(&users[i])
+	}
+	for i := range entries {
+		entries[i].Name = names[entries[i].TelegramID]
+		if entries[i].Name == "" {
+			entries[i].Name = maskID(entries[i].TelegramID)
+		}
+	}
+
+	p.leaderboard = entries
+	p.leaderboardUpdated = time.Now()
+	return entries, nil
+}
+
+// MaskName menyamarkan identitas user untuk ditampilkan ke user lain.
+func MaskName(user *database.User) string {
+	if len(user.Username) > 2 {
+		return "@" + user.Username[:2] + "***"
+	}
+	return maskID(user.TelegramID)
+}
+
+func maskID(id int64) string {
+	s := strconv.FormatInt(id, 10)
+	if len(s) > 4 {
+		s = s[len(s)-4:]
+	}
+	return "user ***" + s
+}
@@ -0,0 +1,152 @@
+package referral
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"telegram-ai-bot/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	ErrRewardNotFound = errors.New("referral reward not found")
+	ErrRewardStatus   = errors.New("referral reward has a different status")
+)
+
+// flag menandai referrer dari hadiah yang ditahan/ditolak dan memberi tahu admin
+// saat referrer pertama kali ditandai.
+func (p *Program) flag(reward *database.ReferralReward) {
+	inserted, err := p.DB.FlagReferrer(&database.ReferralFlag{
+		ReferrerID: reward.ReferrerID,
+		Score:      reward.RiskScore,
+		Reasons:    reward.RiskReasons,
+	})
+	if err != nil || !inserted {
+		return
+	}
+
+	text := p.Localizer.Getf("en", "referral_admin_flagged", map[string]string{
+		"referrer": strconv.FormatInt(reward.ReferrerID, 10),
+		"referee":  strconv.FormatInt(reward.RefereeID, 10),
+		"reward":   strconv.FormatInt(reward.ID, 10),
+		"kind":     reward.Kind,
+		"status":   reward.Status,
+		"score":    strconv.Itoa(reward.RiskScore),
+		"reasons":  reward.RiskReasons,
+	})
+	for _, adminID := range p.AdminIDs {
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ParseMode = "HTML"
+		p.Bot.Send(msg)
+	}
+}
+
+// Release membayar hadiah yang ditahan setelah diperiksa admin.
+func (p *Program) Release(rewardID int64) (*database.ReferralReward, error) {
+	reward, beneficiary, err := p.transition(rewardID, database.ReferralRewardHeld, database.ReferralRewardPaid)
+	if err != nil {
+		return nil, err
+	}
+	reward.Status = database.ReferralRewardPaid
+	p.pay(reward, beneficiary, "referral_reward_released", nil)
+	log.Printf("INFO: Held referral reward %d released", rewardID)
+	return reward, nil
+}
+
+// Deny menolak hadiah yang ditahan.
+func (p *Program) Deny(rewardID int64) (*database.ReferralReward, error) {
+	reward, _, err := p.transition(rewardID, database.ReferralRewardHeld, database.ReferralRewardDenied)
+	if err != nil {
+		return nil, err
+	}
+	reward.Status = database.ReferralRewardDenied
+	log.Printf("INFO: Held referral reward %d denied", rewardID)
+	return reward, nil
+}
+
+// Reverse menarik kembali hadiah yang sudah dibayar. Jika saldo penerima sudah terpakai,
+// hanya sisa saldonya yang ditarik; taken adalah jumlah yang benar-benar ditarik.
+func (p *Program) Reverse(rewardID int64) (reward *database.ReferralReward, taken int, err error) {
+	reward, beneficiary, err := p.transition(rewardID, database.ReferralRewardPaid, database.ReferralRewardReversed)
+	if err != nil {
+		return nil, 0, err
+	}
+	reward.Status = database.ReferralRewardReversed
+
+	taken, _, err = p.DB.TakeBalance(beneficiary.TelegramID, reward.Currency, reward.Amount)
+	if err != nil {
+		log.Printf("ERROR: Referral reward %d marked reversed but balance of user %d could not be adjusted: %v", rewardID, beneficiary.TelegramID, err)
+		return reward, 0, err
+	}
+
+	p.DB.Record(beneficiary.TelegramID, database.TransactionReferralReversal, reward.Currency, -taken, fmt.Sprintf("Referral %s %d", reward.Kind, reward.RefereeID))
+	log.Printf("INFO: Referral reward %d reversed, took back %d %s from user %d", rewardID, taken, reward.Currency, beneficiary.TelegramID)
+	if taken > 0 {
+		p.notify(beneficiary, &database.ReferralReward{Amount: taken, Currency: reward.Currency}, "referral_reward_reversed", nil)
+	}
+	return reward, taken, nil
+}
+
+// OnRefund membatalkan komisi dari pembelian yang di-refund: komisi yang sudah dibayar
+// ditarik kembali (tidak pernah membuat saldo negatif), komisi yang masih ditahan ditolak.
+func (p *Program) OnRefund(chargeID string) {
+	reward, err := p.DB.GetReferralRewardByReference(database.ReferralRewardCommission, chargeID)
+	if err != nil || reward == nil {
+		return
+	}
+	switch reward.Status {
+	case database.ReferralRewardPaid:
+		if _, taken, err := p.Reverse(reward.ID); err == nil {
+			log.Printf("INFO: Commission %d of refunded charge %s reversed, took back %d %s from user %d", reward.ID, chargeID, taken, reward.Currency, reward.BeneficiaryID)
+		}
+	case database.ReferralRewardHeld:
+		p.Deny(reward.ID)
+	}
+}
+
+// ReverseAll menarik semua hadiah paid dari undangan seorang referrer (termasuk bonus daftar referee-nya).
+func (p *Program) ReverseAll(referrerID int64) (count int, taken map[string]int, err error) {
+	rewards, err := p.DB.GetReferralRewardsByReferrer(referrerID, database.ReferralRewardPaid, 1000)
+	if err != nil {
+		return 0, nil, err
+	}
+	taken = make(map[string]int)
+	for _, r := range rewards {
+		reward, amount, err := p.Reverse(r.ID)
+		if reward == nil || err != nil {
+			continue
+		}
+		count++
+		taken[reward.Currency] += amount
+	}
+	return count, taken, nil
+}
+
+// transition mengubah status hadiah secara kondisional dan mengembalikan hadiah beserta penerimanya.
+func (p *Program) transition(rewardID int64, from, to string) (*database.ReferralReward, *database.User, error) {
+	reward, err := p.DB.GetReferralReward(rewardID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reward == nil {
+		return nil, nil, ErrRewardNotFound
+	}
+	beneficiary, err := p.DB.GetUserByTelegramID(reward.BeneficiaryID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if beneficiary == nil {
+		return nil, nil, ErrRewardNotFound
+	}
+	updated, err := p.DB.SetReferralRewardStatusIf(rewardID, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !updated {
+		return reward, nil, ErrRewardStatus
+	}
+	return reward, beneficiary, nil
+}
@@ -0,0 +1,118 @@
+// Package referral menjalankan program referral: bonus daftar untuk referee, bonus milestone
+// dan komisi pembelian untuk referrer, sesuai aturan di referral.json.
+package referral
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+
+	"telegram-ai-bot/internal/wallet"
+)
+
+// Reward adalah sejumlah saldo dalam salah satu mata uang bawaan.
+type Reward struct {
+	Currency string `json:"currency"` // free_credits, paid_credits atau diamonds
+	Amount   int    `json:"amount"`
+}
+
+// Milestone memberi hadiah ke referrer saat referee mencapai jumlah generate tertentu.
+type Milestone struct {
+	ID          string `json:"id"`
+	Generations int    `json:"generations"`
+	Reward      Reward `json:"reward"`
+}
+
+// Rules adalah isi referral.json.
+type Rules struct {
+	SignupBonus       Reward      `json:"signup_bonus"`       // untuk referee yang daftar lewat link
+	Milestones        []Milestone `json:"milestones"`         // untuk referrer
+	CommissionPercent int         `json:"commission_percent"` // % kredit yang dibeli referee dengan Stars, dibayar sebagai paid credits
+	LeaderboardSize   int         `json:"leaderboard_size"`
+	Fraud             FraudRules  `json:"fraud"`
+}
+
+// FraudRules mengatur skor risiko bonus daftar dan milestone. Setiap sinyal yang cocok menambah
+// bobotnya ke skor; skor >= HoldScore ditahan untuk admin, >= DenyScore langsung ditolak.
+// Komisi tidak dinilai karena berasal dari pembelian sungguhan.
+type FraudRules struct {
+	HoldScore int            `json:"hold_score"` // 0 = deteksi fraud mati
+	DenyScore int            `json:"deny_score"`
+	Weights   map[string]int `json:"weights"` // bobot per sinyal (Signal*), negatif = menurunkan risiko
+
+	// ID Telegram naik seiring waktu, ID di atas batas ini dianggap akun yang baru dibuat
+	NewAccountMinID int64 `json:"new_account_min_id"`
+
+	BurstSignups       int `json:"burst_signups"`        // jumlah pendaftar dari satu referrer...
+	BurstWindowMinutes int `json:"burst_window_minutes"` // ...dalam jendela waktu ini
+
+	IdenticalUsageMin int `json:"identical_usage_min"` // jumlah referee dengan pola generate yang sama persis
+
+	InactiveAfterDays   int     `json:"inactive_after_days"`   // umur minimal referee sebelum dinilai
+	InactiveMinReferees int     `json:"inactive_min_referees"` // sampel minimal
+	InactiveRatio       float64 `json:"inactive_ratio"`        // porsi referee yang berhenti tepat di milestone
+}
+
+// Sinyal fraud (kunci di FraudRules.Weights dan isi kolom risk_reasons).
+const (
+	SignalNewAccount       = "new_account"
+	SignalPremium          = "premium"
+	SignalBurst            = "burst"
+	SignalIdenticalUsage   = "identical_usage"
+	SignalInactiveReferees = "inactive_referees"
+	SignalFlaggedReferrer  = "flagged_referrer"
+)
+
+func (f FraudRules) Enabled() bool {
+	return f.HoldScore > 0
+}
+
+// columnCurrencies adalah mata uang yang punya kolom sendiri di tabel users (bisa diubah atomik).
+var columnCurrencies = map[string]bool{
+	wallet.FreeCredits: true,
+	wallet.PaidCredits: true,
+	wallet.Diamonds:    true,
+}
+
+func LoadRules(file string) *Rules {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read referral file %s: %v", file, err)
+	}
+	rules := &Rules{LeaderboardSize: 10}
+	if err := json.Unmarshal(data, rules); err != nil {
+		log.Fatalf("FATAL: Could not parse referral file %s: %v", file, err)
+	}
+
+	validate := func(name string, reward Reward) {
+		if reward.Amount < 0 || (reward.Amount > 0 && !columnCurrencies[reward.Currency]) {
+			log.Fatalf("FATAL: Referral %s has invalid reward %d %s", name, reward.Amount, reward.Currency)
+		}
+	}
+	validate("signup_bonus", rules.SignupBonus)
+	seen := make(map[string]bool)
+	for _, m := range rules.Milestones {
+		if m.ID == "" || seen[m.ID] || m.Generations <= 0 {
+			log.Fatalf("FATAL: Referral milestone '%s' must have a unique id and positive generations", m.ID)
+		}
+		seen[m.ID] = true
+		validate("milestone "+m.ID, m.Reward)
+	}
+	if rules.CommissionPercent < 0 || rules.CommissionPercent > 100 {
+		log.Fatalf("FATAL: Referral commission_percent must be between 0 and 100, got %d", rules.CommissionPercent)
+	}
+	if fraud := rules.Fraud; fraud.Enabled() {
+		if fraud.DenyScore < fraud.HoldScore {
+			log.Fatalf("FATAL: Referral fraud deny_score (%d) must not be lower than hold_score (%d)", fraud.DenyScore, fraud.HoldScore)
+		}
+		known := map[string]bool{SignalNewAccount: true, SignalPremium: true, SignalBurst: true, SignalIdenticalUsage: true, SignalInactiveReferees: true, SignalFlaggedReferrer: true}
+		for signal := range fraud.Weights {
+			if !known[signal] {
+				log.Fatalf("FATAL: Unknown referral fraud signal '%s'", signal)
+			}
+		}
+	}
+
+	log.Printf("INFO: Loaded referral rules (%d milestones, %d%% commission, fraud detection %t)", len(rules.Milestones), rules.CommissionPercent, rules.Fraud.Enabled())
+	return rules
+}
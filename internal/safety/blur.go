@@ -0,0 +1,102 @@
+package safety
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+)
+
+// blurCells: sisi terpanjang gambar diperkecil menjadi sekian sel sebelum diperbesar kembali.
+const blurCells = 24
+
+// Blur mengembalikan versi JPEG gambar yang diburamkan: dirata-rata per sel lalu diperbesar dengan
+// interpolasi bilinear agar hasilnya halus, bukan kotak-kotak. Hanya format yang didukung paket
+// image standar (JPEG, PNG, GIF) yang bisa diproses; WebP menghasilkan error.
+func Blur(data []byte) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+
+	cell := w
+	if h > cell {
+		cell = h
+	}
+	cell = (cell + blurCells - 1) / blurCells
+	sw, sh := (w+cell-1)/cell, (h+cell-1)/cell
+
+	// Rata-rata tiap sel
+	small := make([][4]float64, sw*sh)
+	counts := make([]float64, sw*sh)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, a := src.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			i := (y/cell)*sw + x/cell
+			small[i][0] += float64(r)
+			small[i][1] += float64(g)
+			small[i][2] += float64(bl)
+			small[i][3] += float64(a)
+			counts[i]++
+		}
+	}
+	for i := range small {
+		for c := 0; c < 4; c++ {
+			small[i][c] /= counts[i]
+		}
+	}
+
+	at := func(x, y int) [4]float64 {
+		if x < 0 {
+			x = 0
+		} else if x >= sw {
+			x = sw - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y >= sh {
+			y = sh - 1
+		}
+		return small[y*sw+x]
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		fy := (float64(y)+0.5)/float64(cell) - 0.5
+		y0 := int(fy)
+		if fy < 0 {
+			y0 = -1
+		}
+		ty := fy - float64(y0)
+		for x := 0; x < w; x++ {
+			fx := (float64(x)+0.5)/float64(cell) - 0.5
+			x0 := int(fx)
+			if fx < 0 {
+				x0 = -1
+			}
+			tx := fx - float64(x0)
+			p00, p10, p01, p11 := at(x0, y0), at(x0+1, y0), at(x0, y0+1), at(x0+1, y0+1)
+			var px [4]uint8
+			for c := 0; c < 4; c++ {
+				top := p00[c]*(1-tx) + p10[c]*tx
+				bottom := p01[c]*(1-tx) + p11[c]*tx
+				px[c] = uint8((top*(1-ty) + bottom*ty) / 257)
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: px[0], G: px[1], B: px[2], A: px[3]})
+		}
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("encode image: %w", err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,179 @@
+package safety
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Sumber keputusan blokir.
+const (
+	SourceBlocklist  = "blocklist"
+	SourceClassifier = "classifier"
+	SourceProvider   = "provider" // ditolak filter NSFW model di Replicate
+	SourceOutput     = "output"   // gambar hasil generate ditandai pemeriksaan output
+)
+
+// Verdict menjelaskan kenapa prompt diblokir. Match berisi kata/pola atau jawaban classifier yang cocok.
+type Verdict struct {
+	Category string
+	Source   string
+	Match    string
+}
+
+// Completer adalah bagian ReplicateClient yang dipakai classifier.
+type Completer interface {
+	CreateTextCompletion(ctx context.Context, modelID string, prompt string, systemInstruction string, temperature float64, maxTokens int) (string, error)
+}
+
+// Client adalah bagian ReplicateClient yang dipakai paket ini.
+type Client interface {
+	Completer
+	VisionCompleter
+	ImageClassifier
+}
+
+type Checker struct {
+	Policy *Policy
+	LLM    Completer
+	Images ImageModerator // nil = gambar hasil generate tidak diperiksa
+}
+
+func NewChecker(policy *Policy, client Client) *Checker {
+	return &Checker{Policy: policy, LLM: client, Images: newImageModerator(policy, client)}
+}
+
+// Check memeriksa prompt untuk model tertentu. lang adalah bahasa user. nil berarti prompt boleh dipakai.
+func (c *Checker) Check(ctx context.Context, prompt, lang, modelID string) *Verdict {
+	p := c.Policy
+	if !p.Enabled || strings.TrimSpace(prompt) == "" {
+		return nil
+	}
+	if v := p.matchBlocklist(prompt, lang, modelID); v != nil {
+		return v
+	}
+	if !p.Classifier.Enabled || c.LLM == nil {
+		return nil
+	}
+	return c.classify(ctx, prompt, modelID)
+}
+
+func (p *Policy) matchBlocklist(prompt, lang, modelID string) *Verdict {
+	langs := append([]string{"*", lang}, p.DefaultLanguages...)
+	normalized := " " + normalize(prompt) + " "
+
+	// Urutkan agar hasil stabil bila prompt cocok dengan beberapa kategori
+	names := make([]string, 0, len(p.Categories))
+	for name := range p.Categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if p.Allows(modelID, name) {
+			continue
+		}
+		c := p.Categories[name]
+		for _, l := range langs {
+			for _, w := range c.words[l] {
+				if strings.Contains(normalized, " "+w+" ") {
+					return &Verdict{Category: name, Source: SourceBlocklist, Match: w}
+				}
+			}
+			for _, re := range c.patterns[l] {
+				if m := re.FindString(prompt); m != "" {
+					return &Verdict{Category: name, Source: SourceBlocklist, Match: m}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+const classifierInstruction = `You are a content moderation classifier for an image generation service.
+Classify the user's image prompt into exactly one of these categories: %s.
+Answer "none" if the prompt fits none of them. Reply with the category name only, no explanation.`
+
+// classify menanyakan kategori prompt ke model teks. Jika classifier gagal, prompt tetap diizinkan
+// agar gangguan di Replicate tidak menghentikan semua generate.
+func (c *Checker) classify(ctx context.Context, prompt, modelID string) *Verdict {
+	var candidates []string
+	for name := range c.Policy.Categories {
+		if !c.Policy.Allows(modelID, name) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Strings(candidates)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.Policy.Classifier.TimeoutSeconds)*time.Second)
+	defer cancel()
+	system := fmt.Sprintf(classifierInstruction, strings.Join(candidates, ", "))
+	answer, err := c.LLM.CreateTextCompletion(ctx, c.Policy.Classifier.Model, prompt, system, 0, 64)
+	if err != nil {
+		log.Printf("WARN: Prompt classifier failed, allowing prompt: %v", err)
+		return nil
+	}
+
+	if category := parseLabel(answer, candidates); category != "" {
+		return &Verdict{Category: category, Source: SourceClassifier, Match: strconv.Quote(strings.TrimSpace(answer))}
+	}
+	return nil
+}
+
+// parseLabel mengambil kata pertama jawaban model dan mencocokkannya dengan kategori. Kosong jika tidak cocok (termasuk "none").
+func parseLabel(answer string, candidates []string) string {
+	label := strings.ToLower(strings.TrimFunc(strings.TrimSpace(answer), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}))
+	if fields := strings.Fields(label); len(fields) > 0 {
+		label = strings.Trim(fields[0], `."'`)
+	}
+	for _, name := range candidates {
+		if label == name {
+			return name
+		}
+	}
+	return ""
+}
+
+// IsProviderRejection mendeteksi prediksi yang gagal karena filter NSFW bawaan model di Replicate.
+func IsProviderRejection(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nsfw") || strings.Contains(msg, "safety checker") || strings.Contains(msg, "flagged as sensitive")
+}
+
+// leet mengganti karakter pengganti huruf yang sering dipakai untuk mengakali blocklist.
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// normalize menurunkan huruf, mengganti angka/simbol pengganti huruf, dan menyisakan kata dipisah satu spasi.
+func normalize(s string) string {
+	s = leet.Replace(strings.ToLower(s))
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,233 @@
+package safety
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Aksi untuk gambar hasil generate yang ditandai pemeriksaan output.
+const (
+	OutputAllow    = "allow"
+	OutputSpoiler  = "spoiler"  // dikirim dengan has_spoiler, user harus mengetuk untuk melihat
+	OutputBlur     = "blur"     // dikirim versi blur, file asli tidak bisa diunduh
+	OutputWithhold = "withhold" // tidak dikirim sama sekali
+)
+
+// Provider pemeriksaan output.
+const (
+	OutputProviderVision     = "vision"     // model vision (Gemini dll.) menjawab kategori
+	OutputProviderClassifier = "classifier" // model klasifikasi gambar di Replicate, labelnya dipetakan ke kategori
+	OutputProviderStub       = "stub"       // tanpa panggilan jaringan, untuk pengujian lokal
+)
+
+// OutputPolicy adalah bagian "output" di safety.json.
+type OutputPolicy struct {
+	Enabled        bool              `json:"enabled"`
+	Provider       string            `json:"provider"`
+	Model          string            `json:"model"`
+	ImageParam     string            `json:"image_param"`   // classifier: nama input gambar, default "image"
+	Labels         map[string]string `json:"labels"`        // classifier: label output model -> kategori
+	StubCategory   string            `json:"stub_category"` // stub: kategori untuk semua gambar, kosong = semua aman
+	TimeoutSeconds int               `json:"timeout_seconds"`
+	Private        ChatOutputPolicy  `json:"private"`
+	Group          ChatOutputPolicy  `json:"group"`
+}
+
+// ChatOutputPolicy menentukan aksi per jenis chat. Categories menimpa Default untuk kategori tertentu,
+// OnError dipakai saat pemeriksaan gagal (timeout, Replicate error).
+type ChatOutputPolicy struct {
+	Default    string            `json:"default"`
+	Categories map[string]string `json:"categories"`
+	OnError    string            `json:"on_error"`
+}
+
+// action mengembalikan aksi untuk kategori hasil pemeriksaan. Kategori kosong berarti gambar aman.
+func (c ChatOutputPolicy) action(category string) string {
+	if category == "" {
+		return OutputAllow
+	}
+	if action, ok := c.Categories[category]; ok {
+		return action
+	}
+	return c.Default
+}
+
+// defaultOutputPolicy: grup lebih ketat karena gambarnya terlihat oleh semua anggota.
+func defaultOutputPolicy() OutputPolicy {
+	return OutputPolicy{
+		Provider:       OutputProviderVision,
+		ImageParam:     "image",
+		TimeoutSeconds: 30,
+		Private:        ChatOutputPolicy{Default: OutputSpoiler, OnError: OutputAllow},
+		Group:          ChatOutputPolicy{Default: OutputWithhold, OnError: OutputSpoiler},
+	}
+}
+
+func validOutputAction(action string) bool {
+	switch action {
+	case OutputAllow, OutputSpoiler, OutputBlur, OutputWithhold:
+		return true
+	}
+	return false
+}
+
+func (p *Policy) compileOutput() error {
+	o := &p.Output
+	if !o.Enabled {
+		return nil
+	}
+	switch o.Provider {
+	case OutputProviderVision, OutputProviderClassifier:
+		if o.Model == "" {
+			return fmt.Errorf("output provider %s has no model", o.Provider)
+		}
+	case OutputProviderStub:
+		if o.StubCategory != "" {
+			if _, ok := p.Categories[o.StubCategory]; !ok {
+				return fmt.Errorf("output stub_category %s is not a category", o.StubCategory)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown output provider %s", o.Provider)
+	}
+	for label, category := range o.Labels {
+		if _, ok := p.Categories[category]; !ok {
+			return fmt.Errorf("output label %s maps to unknown category %s", label, category)
+		}
+	}
+	if o.TimeoutSeconds <= 0 {
+		o.TimeoutSeconds = 30
+	}
+	for name, chat := range map[string]ChatOutputPolicy{"private": o.Private, "group": o.Group} {
+		for _, action := range []string{chat.Default, chat.OnError} {
+			if !validOutputAction(action) {
+				return fmt.Errorf("output.%s: invalid action %q", name, action)
+			}
+		}
+		for category, action := range chat.Categories {
+			if _, ok := p.Categories[category]; !ok {
+				return fmt.Errorf("output.%s: unknown category %s", name, category)
+			}
+			if !validOutputAction(action) {
+				return fmt.Errorf("output.%s: invalid action %q for %s", name, action, category)
+			}
+		}
+	}
+	return nil
+}
+
+// ImageModerator memberi kategori untuk satu gambar. Kategori kosong berarti gambar aman.
+type ImageModerator interface {
+	ModerateImage(ctx context.Context, imageURL string) (string, error)
+}
+
+// StubModerator memberi kategori yang sama untuk setiap gambar tanpa memanggil model.
+type StubModerator struct {
+	Category string
+}
+
+func (s StubModerator) ModerateImage(ctx context.Context, imageURL string) (string, error) {
+	return s.Category, nil
+}
+
+// VisionCompleter dan ImageClassifier adalah bagian ReplicateClient yang dipakai pemeriksaan output.
+type VisionCompleter interface {
+	CreateVisionCompletion(ctx context.Context, modelID string, prompt string, imageURL string, maxOutputTokens int) (string, error)
+}
+
+type ImageClassifier interface {
+	ClassifyImage(ctx context.Context, modelID, imageURL, imageParamName string) (string, error)
+}
+
+const visionInstruction = `You are a content moderation classifier for an image generation service.
+Look at the image and classify it into exactly one of these categories: %s.
+Answer "none" if the image fits none of them. Reply with the category name only, no explanation.`
+
+type visionModerator struct {
+	llm        VisionCompleter
+	model      string
+	categories []string
+}
+
+func (v visionModerator) ModerateImage(ctx context.Context, imageURL string) (string, error) {
+	answer, err := v.llm.CreateVisionCompletion(ctx, v.model, fmt.Sprintf(visionInstruction, strings.Join(v.categories, ", ")), imageURL, 64)
+	if err != nil {
+		return "", err
+	}
+	return parseLabel(answer, v.categories), nil
+}
+
+type classifierModerator struct {
+	client ImageClassifier
+	model  string
+	param  string
+	labels map[string]string
+}
+
+func (c classifierModerator) ModerateImage(ctx context.Context, imageURL string) (string, error) {
+	label, err := c.client.ClassifyImage(ctx, c.model, imageURL, c.param)
+	if err != nil {
+		return "", err
+	}
+	// Label yang tidak dipetakan (mis. "normal") dianggap aman
+	return c.labels[strings.ToLower(strings.TrimSpace(label))], nil
+}
+
+// newImageModerator memilih implementasi sesuai output.provider. nil jika pemeriksaan output dimatikan.
+func newImageModerator(p *Policy, client interface{}) ImageModerator {
+	o := p.Output
+	if !o.Enabled {
+		return nil
+	}
+	switch o.Provider {
+	case OutputProviderStub:
+		return StubModerator{Category: o.StubCategory}
+	case OutputProviderVision:
+		llm, ok := client.(VisionCompleter)
+		if !ok {
+			break
+		}
+		var categories []string
+		for name := range p.Categories {
+			categories = append(categories, name)
+		}
+		sort.Strings(categories)
+		return visionModerator{llm: llm, model: o.Model, categories: categories}
+	case OutputProviderClassifier:
+		classifier, ok := client.(ImageClassifier)
+		if !ok {
+			break
+		}
+		labels := make(map[string]string, len(o.Labels))
+		for label, category := range o.Labels {
+			labels[strings.ToLower(label)] = category
+		}
+		return classifierModerator{client: classifier, model: o.Model, param: o.ImageParam, labels: labels}
+	}
+	log.Printf("WARN: Output moderation provider %s is not supported by this client, output checks are disabled", o.Provider)
+	return nil
+}
+
+// ReviewOutput memeriksa satu gambar hasil generate dan mengembalikan aksi untuk jenis chat tujuan beserta kategorinya.
+// err tidak nil berarti pemeriksaan gagal dan aksinya diambil dari on_error.
+func (c *Checker) ReviewOutput(ctx context.Context, imageURL string, group bool) (action, category string, err error) {
+	if c.Images == nil {
+		return OutputAllow, "", nil
+	}
+	chat := c.Policy.Output.Private
+	if group {
+		chat = c.Policy.Output.Group
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.Policy.Output.TimeoutSeconds)*time.Second)
+	defer cancel()
+	category, err = c.Images.ModerateImage(ctx, imageURL)
+	if err != nil {
+		return chat.OnError, "", err
+	}
+	return chat.action(category), category, nil
+}
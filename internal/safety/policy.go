@@ -0,0 +1,181 @@
+// Package safety memeriksa prompt sebelum dikirim ke model: blocklist kata/regex per bahasa,
+// pengecualian per model, dan (opsional) klasifikasi oleh model teks lewat Replicate.
+package safety
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"time"
+)
+
+// Aksi tangga strike.
+const (
+	StrikeWarn    = "warn"
+	StrikeSuspend = "suspend"
+	StrikeBan     = "ban"
+)
+
+// Policy adalah isi safety.json.
+type Policy struct {
+	Enabled bool `json:"enabled"`
+	// Bahasa yang blocklist-nya selalu diperiksa selain "*" dan bahasa user (prompt umumnya ditulis dalam bahasa Inggris).
+	DefaultLanguages []string               `json:"default_languages"`
+	Categories       map[string]*Category   `json:"categories"`
+	Models           map[string]ModelPolicy `json:"models"` // per ID model di models.json
+	Classifier       Classifier             `json:"classifier"`
+	Strikes          StrikePolicy           `json:"strikes"`
+	// Penolakan NSFW dari Replicate selalu dicatat untuk ditinjau admin; true = juga dihitung sebagai strike.
+	ProviderRejectionsAreStrikes bool `json:"provider_rejections_are_strikes"`
+	// Pemeriksaan gambar hasil generate sebelum dikirim ke user/grup.
+	Output OutputPolicy `json:"output"`
+}
+
+// Category adalah satu jenis konten terlarang. Words dan Patterns dikelompokkan per kode bahasa, "*" = semua bahasa.
+type Category struct {
+	AlwaysBlocked bool                `json:"always_blocked"` // tidak bisa diizinkan oleh model mana pun
+	Words         map[string][]string `json:"words"`
+	Patterns      map[string][]string `json:"patterns"`
+
+	words    map[string][]string
+	patterns map[string][]*regexp.Regexp
+}
+
+// ModelPolicy melonggarkan kebijakan untuk satu model: kategori di Allow tidak diblokir.
+type ModelPolicy struct {
+	Allow []string `json:"allow"`
+}
+
+// Classifier adalah pemeriksaan kedua oleh model teks setelah blocklist lolos.
+type Classifier struct {
+	Enabled        bool   `json:"enabled"`
+	Model          string `json:"model"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// StrikePolicy menentukan tindakan setelah sejumlah prompt terblokir dalam WindowDays hari.
+type StrikePolicy struct {
+	WindowDays int          `json:"window_days"`
+	Steps      []StrikeStep `json:"steps"`
+}
+
+type StrikeStep struct {
+	Strikes  int    `json:"strikes"`
+	Action   string `json:"action"`             // warn, suspend atau ban
+	Duration string `json:"duration,omitempty"` // untuk suspend, durasi Go (24h) atau hari (7d)
+
+	duration time.Duration
+}
+
+// SuspendFor mengembalikan lama suspend langkah ini.
+func (s StrikeStep) SuspendFor() time.Duration {
+	return s.duration
+}
+
+func LoadPolicy(file string) *Policy {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read safety policy file %s: %v", file, err)
+	}
+
+	policy := &Policy{DefaultLanguages: []string{"en"}, Strikes: StrikePolicy{WindowDays: 30}, Output: defaultOutputPolicy()}
+	if err := json.Unmarshal(data, policy); err != nil {
+		log.Fatalf("FATAL: Could not parse safety policy file %s: %v", file, err)
+	}
+	if err := policy.compile(); err != nil {
+		log.Fatalf("FATAL: Invalid safety policy in %s: %v", file, err)
+	}
+
+	if policy.Enabled {
+		log.Printf("INFO: Loaded prompt safety policy (%d categories, %d model overrides, classifier: %t)", len(policy.Categories), len(policy.Models), policy.Classifier.Enabled)
+	} else {
+		log.Println("INFO: Prompt safety checks are disabled")
+	}
+	if policy.Output.Enabled {
+		log.Printf("INFO: Output image moderation enabled (%s, private: %s, group: %s)", policy.Output.Provider, policy.Output.Private.Default, policy.Output.Group.Default)
+	}
+	return policy
+}
+
+func (p *Policy) compile() error {
+	for name, c := range p.Categories {
+		c.words = make(map[string][]string)
+		for lang, words := range c.Words {
+			for _, w := range words {
+				if n := normalize(w); n != "" {
+					c.words[lang] = append(c.words[lang], n)
+				}
+			}
+		}
+		c.patterns = make(map[string][]*regexp.Regexp)
+		for lang, patterns := range c.Patterns {
+			for _, expr := range patterns {
+				re, err := regexp.Compile("(?i)" + expr)
+				if err != nil {
+					return fmt.Errorf("category %s: invalid pattern %q: %v", name, expr, err)
+				}
+				c.patterns[lang] = append(c.patterns[lang], re)
+			}
+		}
+	}
+	for modelID, mp := range p.Models {
+		for _, category := range mp.Allow {
+			if _, ok := p.Categories[category]; !ok {
+				return fmt.Errorf("model %s allows unknown category %s", modelID, category)
+			}
+		}
+	}
+	if p.Classifier.Enabled && p.Classifier.Model == "" {
+		return fmt.Errorf("classifier is enabled but has no model")
+	}
+	if p.Classifier.TimeoutSeconds <= 0 {
+		p.Classifier.TimeoutSeconds = 15
+	}
+	if p.Strikes.WindowDays <= 0 {
+		return fmt.Errorf("strikes.window_days must be positive")
+	}
+	for i := range p.Strikes.Steps {
+		step := &p.Strikes.Steps[i]
+		if step.Strikes <= 0 || (i > 0 && step.Strikes <= p.Strikes.Steps[i-1].Strikes) {
+			return fmt.Errorf("strike steps must have increasing positive strike counts")
+		}
+		switch step.Action {
+		case StrikeWarn, StrikeBan:
+		case StrikeSuspend:
+			d, err := parseDuration(step.Duration)
+			if err != nil {
+				return fmt.Errorf("strike step %d: %v", step.Strikes, err)
+			}
+			step.duration = d
+		default:
+			return fmt.Errorf("strike step %d: unknown action %s", step.Strikes, step.Action)
+		}
+	}
+	return p.compileOutput()
+}
+
+// Allows memeriksa apakah model boleh menerima prompt dalam kategori tersebut.
+func (p *Policy) Allows(modelID, category string) bool {
+	if c, ok := p.Categories[category]; ok && c.AlwaysBlocked {
+		return false
+	}
+	for _, allowed := range p.Models[modelID].Allow {
+		if allowed == category {
+			return true
+		}
+	}
+	return false
+}
+
+// StepFor mengembalikan langkah tertinggi yang sudah dicapai dengan jumlah strike ini, nil jika belum ada.
+func (p *Policy) StepFor(strikes int) *StrikeStep {
+	var step *StrikeStep
+	for i := range p.Strikes.Steps {
+		if strikes >= p.Strikes.Steps[i].Strikes {
+			step = &p.Strikes.Steps[i]
+		}
+	}
+	return step
+}
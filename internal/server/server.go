@@ -0,0 +1,52 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// Server adalah HTTP server kecil untuk webhook dan endpoint internal lain.
+// Bot tetap memakai long polling; server ini hanya berjalan jika ada route yang didaftarkan.
+type Server struct {
+	addr   string
+	mux    *http.ServeMux
+	routes int
+}
+
+func New(addr string) *Server {
+	s := &Server{addr: addr, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	return s
+}
+
+// Handle mendaftarkan handler untuk sebuah path.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+	s.routes++
+	log.Printf("INFO: HTTP route registered: %s", pattern)
+}
+
+// Start menjalankan server di goroutine terpisah. Tidak melakukan apa-apa jika belum ada route.
+func (s *Server) Start() {
+	if s.routes == 0 {
+		log.Println("INFO: No HTTP routes registered, HTTP server not started")
+		return
+	}
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+	}
+	go func() {
+		log.Printf("INFO: HTTP server listening on %s", s.addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("FATAL: HTTP server stopped: %v", err)
+		}
+	}()
+}
@@ -173,4 +173,35 @@ func (c *ReplicateClient) CreateVisionCompletion(ctx context.Context, modelID st
 	}
 
 	return "", fmt.Errorf("unknown output format from Vision Model")
-}
\ No newline at end of file
+}
+// ClassifyImage menjalankan model klasifikasi gambar (mis. deteksi NSFW) dan mengembalikan labelnya.
+func (c *ReplicateClient) ClassifyImage(ctx context.Context, modelID, imageURL, imageParamName string) (string, error) {
+	if imageParamName == "" {
+		imageParamName = "image"
+	}
+	input := replicate.PredictionInput{imageParamName: imageURL}
+
+	output, err := c.client.Run(ctx, modelID, input, nil)
+	if err != nil {
+		log.Printf("ERROR: Image classification failed: %v", err)
+		return "", err
+	}
+
+	switch v := output.(type) {
+	case string:
+		return v, nil
+	case []interface{}:
+		if len(v) > 0 {
+			if label, ok := v[0].(string); ok {
+				return label, nil
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"label", "class", "prediction"} {
+			if label, ok := v[key].(string); ok {
+				return label, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unknown output format from classifier model")
+}
@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"fmt"
+	"log"
+
+	"telegram-ai-bot/internal/database"
+)
+
+// Perubahan saldo selalu disimpan sebagai delta per kolom (AdjustBalance/TakeBalance), bukan dengan
+// menulis ulang baris user. Salinan user yang dibaca beberapa menit lalu (mis. sebelum generate)
+// tidak boleh menimpa gift, hadiah referral atau /addcredits yang masuk di antaranya.
+
+// column mengembalikan kolom tabel users tempat saldo currency disimpan. Currency tambahan dari
+// wallet.json disimpan di jsonb balances (lihat database.ExtraBalanceColumn).
+func (c *Config) column(id string) (string, error) {
+	switch id {
+	case FreeCredits, PaidCredits, Diamonds:
+		return id, nil
+	}
+	if c.currency(id) == nil {
+		return "", fmt.Errorf("unknown currency '%s'", id)
+	}
+	return database.ExtraBalanceColumn(id), nil
+}
+
+// Credit menambah saldo sebuah currency di database lalu menyalin saldo terbarunya ke user.
+func (c *Config) Credit(db *database.Client, user *database.User, id string, amount int) error {
+	column, err := c.column(id)
+	if err != nil {
+		return err
+	}
+	balance, err := db.AdjustBalance(user.TelegramID, column, amount)
+	if err != nil {
+		return err
+	}
+	setBalance(user, id, balance)
+	return nil
+}
+
+// Debit mengurangi saldo currency, atau pool sesuai urutannya, di database. Saldo yang dipakai adalah
+// saldo terbaru di database, bukan salinan di user. Jika saldo tidak cukup tidak ada yang berubah
+// dan *InsufficientError dikembalikan.
+func (c *Config) Debit(db *database.Client, user *database.User, id string, amount int) error {
+	_, err := c.debit(db, user, id, amount)
+	return err
+}
+
+// debit menjalankan Debit dan mengembalikan rincian yang terpotong per currency.
+func (c *Config) debit(db *database.Client, user *database.User, id string, amount int) (map[string]int, error) {
+	taken, total, err := c.take(db, user, id, amount)
+	if err != nil {
+		return nil, err
+	}
+	if total < amount {
+		c.giveBack(db, user, taken)
+		return nil, &InsufficientError{Currency: id, Required: amount, Balance: total}
+	}
+	return taken, nil
+}
+
+// Charge seperti Debit, untuk biaya yang sudah terlanjur dipakai (hasil generate sudah jadi):
+// jika saldo ternyata kurang, sisa saldo tetap diambil tanpa pernah menjadi negatif.
+// Mengembalikan jumlah yang benar-benar terpotong.
+func (c *Config) Charge(db *database.Client, user *database.User, id string, amount int) (int, error) {
+	_, total, err := c.take(db, user, id, amount)
+	if err == nil && total < amount {
+		log.Printf("WARN: User %d could only be charged %d of %d %s", user.TelegramID, total, amount, id)
+	}
+	return total, err
+}
+
+// take mengambil sampai amount dari currency/pool sesuai urutannya. Jika terjadi error,
+// yang sudah terambil dikembalikan.
+func (c *Config) take(db *database.Client, user *database.User, id string, amount int) (taken map[string]int, total int, err error) {
+	currencies := []string{id}
+	if pool := c.pool(id); pool != nil {
+		currencies = pool.Currencies
+	} else if c.currency(id) == nil {
+		return nil, 0, fmt.Errorf("unknown currency '%s'", id)
+	}
+
+	taken = make(map[string]int)
+	left := amount
+	for _, cur := range currencies {
+		column, err := c.column(cur)
+		if err != nil {
+			c.giveBack(db, user, taken)
+			return nil, 0, err
+		}
+		// Currency yang tidak perlu dipotong tetap dibaca agar saldo pool di user ikut terbaru
+		n, balance, err := db.TakeBalance(user.TelegramID, column, left)
+		if err != nil {
+			c.giveBack(db, user, taken)
+			return nil, 0, err
+		}
+		setBalance(user, cur, balance)
+		taken[cur] = n
+		left -= n
+	}
+	return taken, amount - left, nil
+}
+
+func (c *Config) giveBack(db *database.Client, user *database.User, taken map[string]int) {
+	for cur, n := range taken {
+		if n == 0 {
+			continue
+		}
+		if err := c.Credit(db, user, cur, n); err != nil {
+			log.Printf("ERROR: Could not return %d %s to user %d: %v", n, cur, user.TelegramID, err)
+		}
+	}
+}
+
+// Apply menukar saldo user untuk menerima toAmount unit To dan menyimpannya ke database.
+func (c *Config) Apply(db *database.Client, user *database.User, ex *Exchange, toAmount int) (spent int, err error) {
+	if toAmount <= 0 || toAmount%ex.Step() != 0 {
+		return 0, fmt.Errorf("amount %d is not a multiple of %d", toAmount, ex.Step())
+	}
+	spent = ex.Cost(toAmount)
+	taken, err := c.debit(db, user, ex.From, spent)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.Credit(db, user, ex.To, toAmount); err != nil {
+		// Kembalikan yang sudah dipotong agar penukaran tidak setengah jalan
+		c.giveBack(db, user, taken)
+		return 0, err
+	}
+	return spent, nil
+}
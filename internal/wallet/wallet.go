@@ -0,0 +1,251 @@
+// Package wallet membungkus saldo user (free credits, paid credits, diamonds, dan mata uang
+// lain di masa depan) dalam mata uang bernama yang diatur di wallet.json.
+package wallet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"telegram-ai-bot/internal/database"
+)
+
+// ID mata uang bawaan yang disimpan di kolom tabel users.
+const (
+	FreeCredits = "free_credits"
+	PaidCredits = "paid_credits"
+	Diamonds    = "diamonds"
+
+	// CreditsPool adalah pool kredit yang dipakai untuk biaya generate (free dulu, lalu paid).
+	CreditsPool = "credits"
+)
+
+// Currency adalah satu saldo yang bisa dimiliki user.
+type Currency struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name"`
+	Names  map[string]string `json:"names,omitempty"` // nama per bahasa, fallback ke Name
+	Symbol string            `json:"symbol"`
+	Hidden bool              `json:"hidden,omitempty"` // tidak ditampilkan di /profile
+}
+
+// Pool menggabungkan beberapa mata uang yang dibelanjakan bersama, sesuai urutan
+// (misal "credits" = free_credits lalu paid_credits).
+type Pool struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Names      map[string]string `json:"names,omitempty"`
+	Symbol     string            `json:"symbol"`
+	Currencies []string          `json:"currencies"`
+}
+
+// Exchange adalah satu arah penukaran: FromAmount unit From = ToAmount unit To.
+type Exchange struct {
+	ID         string `json:"id"`
+	From       string `json:"from"` // ID currency atau pool
+	To         string `json:"to"`   // ID currency (bukan pool)
+	FromAmount int    `json:"from_amount"`
+	ToAmount   int    `json:"to_amount"`
+	Enabled    bool   `json:"enabled"`
+}
+
+type Config struct {
+	Currencies []Currency `json:"currencies"`
+	Pools      []Pool     `json:"pools"`
+	Exchanges  []Exchange `json:"exchanges"`
+}
+
+// InsufficientError dikembalikan jika saldo tidak cukup.
+type InsufficientError struct {
+	Currency string
+	Required int
+	Balance  int
+}
+
+func (e *InsufficientError) Error() string {
+	return fmt.Sprintf("insufficient %s: need %d, have %d", e.Currency, e.Required, e.Balance)
+}
+
+// Load membaca wallet.json dan memvalidasi referensi antar mata uang.
+func Load(file string) *Config {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read wallet file %s: %v", file, err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		log.Fatalf("FATAL: Could not parse wallet file %s: %v", file, err)
+	}
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("FATAL: Invalid wallet file %s: %v", file, err)
+	}
+	log.Printf("INFO: Loaded %d currencies and %d exchange rates", len(cfg.Currencies), len(cfg.Exchanges))
+	return &cfg
+}
+
+func (c *Config) validate() error {
+	ids := make(map[string]bool)
+	for _, cur := range c.Currencies {
+		if cur.ID == "" || ids[cur.ID] {
+			return fmt.Errorf("currency id '%s' is empty or duplicated", cur.ID)
+		}
+		ids[cur.ID] = true
+	}
+	for _, builtin := range []string{FreeCredits, PaidCredits, Diamonds} {
+		if !ids[builtin] {
+			return fmt.Errorf("built-in currency '%s' is missing", builtin)
+		}
+	}
+	for _, pool := range c.Pools {
+		if pool.ID == "" || ids[pool.ID] {
+			return fmt.Errorf("pool id '%s' is empty or clashes with another id", pool.ID)
+		}
+		if len(pool.Currencies) == 0 {
+			return fmt.Errorf("pool '%s' has no currencies", pool.ID)
+		}
+		for _, id := range pool.Currencies {
+			if c.currency(id) == nil {
+				return fmt.Errorf("pool '%s' references unknown currency '%s'", pool.ID, id)
+			}
+		}
+		ids[pool.ID] = true
+	}
+	if c.pool(CreditsPool) == nil {
+		return fmt.Errorf("pool '%s' is missing", CreditsPool)
+	}
+	for _, ex := range c.Exchanges {
+		if !ids[ex.From] || c.currency(ex.To) == nil {
+			return fmt.Errorf("exchange '%s' references unknown currency (%s -> %s)", ex.ID, ex.From, ex.To)
+		}
+		if ex.FromAmount <= 0 || ex.ToAmount <= 0 {
+			return fmt.Errorf("exchange '%s' must have positive amounts", ex.ID)
+		}
+		if ex.From == ex.To {
+			return fmt.Errorf("exchange '%s' exchanges a currency with itself", ex.ID)
+		}
+	}
+	return nil
+}
+
+func (c *Config) currency(id string) *Currency {
+	for i := range c.Currencies {
+		if c.Currencies[i].ID == id {
+			return &c.Currencies[i]
+		}
+	}
+	return nil
+}
+
+func (c *Config) pool(id string) *Pool {
+	for i := range c.Pools {
+		if c.Pools[i].ID == id {
+			return &c.Pools[i]
+		}
+	}
+	return nil
+}
+
+// DisplayName mengembalikan nama currency/pool dalam bahasa user.
+func (c *Config) DisplayName(id, lang string) string {
+	if cur := c.currency(id); cur != nil {
+		return localized(cur.Name, cur.Names, lang)
+	}
+	if pool := c.pool(id); pool != nil {
+		return localized(pool.Name, pool.Names, lang)
+	}
+	return id
+}
+
+// Symbol mengembalikan simbol currency/pool.
+func (c *Config) Symbol(id string) string {
+	if cur := c.currency(id); cur != nil {
+		return cur.Symbol
+	}
+	if pool := c.pool(id); pool != nil {
+		return pool.Symbol
+	}
+	return ""
+}
+
+func localized(name string, names map[string]string, lang string) string {
+	if n, ok := names[lang]; ok && n != "" {
+		return n
+	}
+	return name
+}
+
+// Balance mengembalikan saldo sebuah currency atau total sebuah pool.
+func (c *Config) Balance(user *database.User, id string) int {
+	if pool := c.pool(id); pool != nil {
+		total := 0
+		for _, cur := range pool.Currencies {
+			total += balance(user, cur)
+		}
+		return total
+	}
+	return balance(user, id)
+}
+
+// EnabledExchanges mengembalikan arah penukaran yang aktif.
+func (c *Config) EnabledExchanges() []Exchange {
+	var out []Exchange
+	for _, ex := range c.Exchanges {
+		if ex.Enabled {
+			out = append(out, ex)
+		}
+	}
+	return out
+}
+
+// FindExchange mencari exchange aktif berdasarkan ID.
+func (c *Config) FindExchange(id string) *Exchange {
+	for i := range c.Exchanges {
+		if c.Exchanges[i].ID == id && c.Exchanges[i].Enabled {
+			return &c.Exchanges[i]
+		}
+	}
+	return nil
+}
+
+// Step adalah kelipatan terkecil jumlah To yang bisa diterima tanpa pembulatan.
+func (ex Exchange) Step() int {
+	a, b := ex.FromAmount, ex.ToAmount
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return ex.ToAmount / a
+}
+
+// Cost menghitung jumlah From yang dibutuhkan untuk menerima toAmount unit To.
+func (ex Exchange) Cost(toAmount int) int {
+	return toAmount * ex.FromAmount / ex.ToAmount
+}
+
+func balance(user *database.User, id string) int {
+	switch id {
+	case FreeCredits:
+		return user.FreeCredits
+	case PaidCredits:
+		return user.PaidCredits
+	case Diamonds:
+		return user.Diamonds
+	}
+	return user.Balances[id]
+}
+
+func setBalance(user *database.User, id string, value int) {
+	switch id {
+	case FreeCredits:
+		user.FreeCredits = value
+	case PaidCredits:
+		user.PaidCredits = value
+	case Diamonds:
+		user.Diamonds = value
+	default:
+		if user.Balances == nil {
+			user.Balances = make(map[string]int)
+		}
+		user.Balances[id] = value
+	}
+}
@@ -0,0 +1,9 @@
+-- Premium subscriptions paid with Telegram Stars
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS premium_expires_at timestamptz,
+    ADD COLUMN IF NOT EXISTS subscription_charge_id text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS subscription_canceled boolean NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS users_premium_expires_at_idx
+    ON users (premium_expires_at)
+    WHERE is_premium;
@@ -0,0 +1,22 @@
+-- One row per Telegram payment, keyed by telegram_payment_charge_id.
+-- The primary key is what makes crediting idempotent: a redelivered
+-- successful_payment update fails to insert and is ignored.
+CREATE TABLE IF NOT EXISTS payments (
+    charge_id          text PRIMARY KEY,
+    provider_charge_id text NOT NULL DEFAULT '',
+    telegram_id        bigint NOT NULL,
+    payload            text NOT NULL,
+    product_type       text NOT NULL,
+    currency           text NOT NULL,
+    amount             integer NOT NULL,
+    credits            integer NOT NULL DEFAULT 0,
+    status             text NOT NULL DEFAULT 'pending',
+    created_at         timestamptz NOT NULL DEFAULT now(),
+    completed_at       timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS payments_telegram_id_created_at_idx
+    ON payments (telegram_id, created_at DESC);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS is_banned boolean NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+-- Star refunds (admin /refund and automatic refunds on failed delivery)
+ALTER TABLE payments
+    ADD COLUMN IF NOT EXISTS refunded_at timestamptz,
+    ADD COLUMN IF NOT EXISTS refunded_by bigint,
+    ADD COLUMN IF NOT EXISTS refund_reason text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS credits_reversed integer NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Manual bank-transfer payments reviewed by admins
+CREATE TABLE IF NOT EXISTS manual_payments (
+    id             bigserial PRIMARY KEY,
+    telegram_id    bigint NOT NULL,
+    package_id     text NOT NULL,
+    credits        integer NOT NULL,
+    price          integer NOT NULL,
+    currency       text NOT NULL,
+    proof_file_id  text NOT NULL,
+    status         text NOT NULL DEFAULT 'pending',
+    reviewed_by    bigint,
+    reviewed_at    timestamptz,
+    created_at     timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS manual_payments_status_idx ON manual_payments (status, created_at);
+CREATE INDEX IF NOT EXISTS manual_payments_telegram_id_idx ON manual_payments (telegram_id);
@@ -0,0 +1,32 @@
+-- Admin-created promo codes and their redemptions
+CREATE TABLE IF NOT EXISTS promo_codes (
+    code            text PRIMARY KEY,
+    reward_type     text NOT NULL,            -- credits | diamonds | bonus
+    reward_amount   integer NOT NULL,         -- jumlah kredit/diamond, atau persen untuk bonus
+    max_uses        integer NOT NULL DEFAULT 0,
+    per_user_limit  integer NOT NULL DEFAULT 1,
+    uses            integer NOT NULL DEFAULT 0,
+    expires_at      timestamptz,
+    campaign        text NOT NULL DEFAULT '',
+    active          boolean NOT NULL DEFAULT true,
+    created_by      bigint NOT NULL,
+    created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS promo_codes_campaign_idx ON promo_codes (campaign);
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id              bigserial PRIMARY KEY,
+    code            text NOT NULL REFERENCES promo_codes (code),
+    telegram_id     bigint NOT NULL,
+    campaign        text NOT NULL DEFAULT '',
+    reward_type     text NOT NULL,
+    reward_amount   integer NOT NULL,
+    charge_id       text,
+    consumed_at     timestamptz,
+    redeemed_at     timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS promo_redemptions_code_user_idx ON promo_redemptions (code, telegram_id);
+CREATE INDEX IF NOT EXISTS promo_redemptions_campaign_idx ON promo_redemptions (campaign);
+CREATE INDEX IF NOT EXISTS promo_redemptions_pending_bonus_idx ON promo_redemptions (telegram_id) WHERE reward_type = 'bonus' AND consumed_at IS NULL;
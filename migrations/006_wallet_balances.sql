@@ -0,0 +1,2 @@
+-- Balances of extra wallet.json currencies that have no dedicated users column
+ALTER TABLE users ADD COLUMN IF NOT EXISTS balances jsonb NOT NULL DEFAULT '{}'::jsonb;
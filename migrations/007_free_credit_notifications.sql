@@ -0,0 +1,5 @@
+-- Opt-in notification when the scheduled job refills a user's free credits
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notify_free_credits boolean NOT NULL DEFAULT false;
+
+-- The refill job scans users whose last refill is older than the current period
+CREATE INDEX IF NOT EXISTS users_last_free_credits_reset_idx ON users (last_free_credits_reset);
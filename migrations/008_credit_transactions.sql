@@ -0,0 +1,13 @@
+-- Balance history: one row per change of a user's balance (signed amount)
+CREATE TABLE IF NOT EXISTS credit_transactions (
+    id              bigserial PRIMARY KEY,
+    telegram_id     bigint      NOT NULL,
+    kind            text        NOT NULL,
+    currency        text        NOT NULL,
+    amount          integer     NOT NULL,
+    description     text        NOT NULL DEFAULT '',
+    counterparty_id bigint,
+    created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS credit_transactions_user_idx ON credit_transactions (telegram_id, created_at DESC);
@@ -0,0 +1,4 @@
+-- Per-user spend caps, generation confirmation threshold and low-balance notification settings
+ALTER TABLE users ADD COLUMN IF NOT EXISTS spend_limits jsonb NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX IF NOT EXISTS credit_transactions_spent_idx ON credit_transactions (telegram_id, currency, created_at) WHERE amount < 0;
@@ -0,0 +1,17 @@
+-- Rewards paid by the referral program (referral.json)
+CREATE TABLE IF NOT EXISTS referral_rewards (
+    id              bigserial PRIMARY KEY,
+    beneficiary_id  bigint      NOT NULL,
+    referrer_id     bigint      NOT NULL,
+    referee_id      bigint      NOT NULL,
+    kind            text        NOT NULL,            -- signup | milestone | commission
+    reference       text        NOT NULL,            -- milestone id, payment charge id or 'signup'
+    currency        text        NOT NULL,
+    amount          integer     NOT NULL,
+    status          text        NOT NULL DEFAULT 'paid',
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (referee_id, kind, reference)
+);
+
+CREATE INDEX IF NOT EXISTS referral_rewards_referrer_idx ON referral_rewards (referrer_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS users_referrer_idx ON users (referrer_id);
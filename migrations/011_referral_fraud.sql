@@ -0,0 +1,15 @@
+-- Fraud scoring for referral rewards (held/denied/reversed statuses)
+ALTER TABLE referral_rewards
+    ADD COLUMN IF NOT EXISTS risk_score   integer NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS risk_reasons text;
+
+CREATE INDEX IF NOT EXISTS referral_rewards_status_idx ON referral_rewards (status) WHERE status = 'held';
+CREATE INDEX IF NOT EXISTS users_referrer_created_idx ON users (referrer_id, created_at);
+
+-- Referrers flagged by fraud detection, waiting for admin review
+CREATE TABLE IF NOT EXISTS referral_flags (
+    referrer_id bigint      PRIMARY KEY,
+    score       integer     NOT NULL,
+    reasons     text        NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+-- Onboarding anti-bot challenge (CAPTCHA_MODE). Existing users count as verified;
+-- new users are inserted with human_verified = false when a challenge is required.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS human_verified boolean NOT NULL DEFAULT true;
@@ -0,0 +1,4 @@
+-- Admin moderation: /ban, /unban, /suspend (is_banned already exists since 002)
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS ban_reason      text,
+    ADD COLUMN IF NOT EXISTS suspended_until timestamptz;
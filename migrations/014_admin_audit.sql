@@ -0,0 +1,13 @@
+-- Admin audit log: one row per admin action (credit adjustments, broadcasts, refunds, bans, ...)
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    id         bigserial PRIMARY KEY,
+    actor_id   bigint      NOT NULL,
+    action     text        NOT NULL,
+    target_id  bigint,
+    params     jsonb       NOT NULL DEFAULT '{}'::jsonb,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS admin_audit_log_created_idx ON admin_audit_log (created_at DESC);
+CREATE INDEX IF NOT EXISTS admin_audit_log_actor_idx ON admin_audit_log (actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS admin_audit_log_target_idx ON admin_audit_log (target_id, created_at DESC);
@@ -0,0 +1,7 @@
+-- Staff roles (owner, admin, support, finance). ADMIN_TELEGRAM_IDS are always owners and are not stored here.
+CREATE TABLE IF NOT EXISTS admin_roles (
+    telegram_id bigint PRIMARY KEY,
+    role        text        NOT NULL CHECK (role IN ('owner', 'admin', 'support', 'finance')),
+    granted_by  bigint      NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
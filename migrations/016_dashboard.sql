@@ -0,0 +1,35 @@
+-- Generation log: one row per image/video prediction (admin dashboard thumbnails and model health)
+CREATE TABLE IF NOT EXISTS generations (
+    id          bigserial PRIMARY KEY,
+    telegram_id bigint      NOT NULL,
+    model_id    text        NOT NULL,
+    kind        text        NOT NULL, -- image or video
+    prompt      text        NOT NULL DEFAULT '',
+    output_urls text[]      NOT NULL DEFAULT '{}',
+    status      text        NOT NULL, -- succeeded or failed
+    error       text        NOT NULL DEFAULT '',
+    cost        integer     NOT NULL DEFAULT 0,
+    duration_ms integer     NOT NULL DEFAULT 0,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS generations_created_idx ON generations (created_at DESC);
+CREATE INDEX IF NOT EXISTS generations_model_idx ON generations (model_id, created_at DESC);
+
+-- Broadcast jobs started with /broadcast and /broadcastgroup, updated while sending
+CREATE TABLE IF NOT EXISTS broadcast_jobs (
+    id          bigserial PRIMARY KEY,
+    kind        text        NOT NULL, -- users or groups
+    actor_id    bigint      NOT NULL,
+    text        text        NOT NULL DEFAULT '',
+    photo       text        NOT NULL DEFAULT '',
+    total       integer     NOT NULL,
+    sent        integer     NOT NULL DEFAULT 0,
+    failed      integer     NOT NULL DEFAULT 0,
+    status      text        NOT NULL DEFAULT 'running', -- running or finished
+    started_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now(),
+    finished_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS broadcast_jobs_started_idx ON broadcast_jobs (started_at DESC);
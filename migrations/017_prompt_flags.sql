@@ -0,0 +1,19 @@
+-- Prompt moderation: one row per blocked prompt (blocklist/classifier) or provider NSFW rejection.
+-- Rows with strike = true count towards the escalation ladder in safety.json unless an admin dismisses them.
+CREATE TABLE IF NOT EXISTS prompt_flags (
+    id          bigserial PRIMARY KEY,
+    telegram_id bigint      NOT NULL,
+    model_id    text        NOT NULL,
+    prompt      text        NOT NULL,
+    category    text        NOT NULL,
+    source      text        NOT NULL CHECK (source IN ('blocklist', 'classifier', 'provider')),
+    match       text        NOT NULL DEFAULT '',
+    strike      boolean     NOT NULL DEFAULT true,
+    status      text        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'dismissed')),
+    reviewed_by bigint,
+    reviewed_at timestamptz,
+    created_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS prompt_flags_user_idx ON prompt_flags (telegram_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS prompt_flags_pending_idx ON prompt_flags (created_at DESC) WHERE status = 'pending';
@@ -0,0 +1,4 @@
+-- Output moderation: generated images flagged before delivery are recorded in prompt_flags with source 'output'
+ALTER TABLE prompt_flags DROP CONSTRAINT IF EXISTS prompt_flags_source_check;
+ALTER TABLE prompt_flags ADD CONSTRAINT prompt_flags_source_check
+    CHECK (source IN ('blocklist', 'classifier', 'provider', 'output'));
@@ -0,0 +1,2 @@
+-- /deleteme: users are anonymized instead of deleted so bans and financial records stay consistent
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
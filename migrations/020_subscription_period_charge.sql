@@ -0,0 +1,17 @@
+-- Charge ID of the payment for the current subscription period. subscription_charge_id keeps the
+-- first payment (it identifies the subscription for editUserStarSubscription); only a refund of
+-- this charge revokes premium.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS subscription_period_charge_id text NOT NULL DEFAULT '';
+
+UPDATE users u
+SET subscription_period_charge_id = p.charge_id
+FROM (
+    SELECT DISTINCT ON (telegram_id) telegram_id, charge_id
+    FROM payments
+    WHERE product_type = 'subscription' AND status = 'completed'
+    ORDER BY telegram_id, created_at DESC
+) p
+WHERE u.telegram_id = p.telegram_id
+  AND u.subscription_charge_id <> ''
+  AND u.subscription_period_charge_id = '';
@@ -0,0 +1,14 @@
+-- Numbered redemption slots per user so concurrent redemptions cannot exceed per_user_limit.
+-- use_number stays NULL for codes without a per-user limit.
+ALTER TABLE promo_redemptions ADD COLUMN IF NOT EXISTS use_number integer;
+
+UPDATE promo_redemptions r
+SET use_number = n.use_number
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY code, telegram_id ORDER BY redeemed_at, id) AS use_number
+    FROM promo_redemptions
+) n
+WHERE r.id = n.id AND r.use_number IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS promo_redemptions_code_user_use_idx
+    ON promo_redemptions (code, telegram_id, use_number);